@@ -24,11 +24,18 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/c9845/fresher/version"
@@ -38,6 +45,111 @@ import (
 // DefaultConfigFileName is the typical name of the config file.
 const DefaultConfigFileName = "fresher.conf"
 
+// DefaultProfile is the name of the profile used when a profile isn't selected with
+// the -profile flag, if the profile exists. See File.Profiles.
+const DefaultProfile = "default"
+
+// Modes for handling the build errors log, see File.BuildLogMode.
+const (
+	BuildLogModeOverwrite = "overwrite"
+	BuildLogModeAppend    = "append"
+)
+
+// Log levels, from least to most verbose, see File.LogLevel.
+const (
+	LogLevelError = "error"
+	LogLevelWarn  = "warn"
+	LogLevelInfo  = "info"
+	LogLevelDebug = "debug"
+	LogLevelTrace = "trace"
+)
+
+// logLevels is the list of log levels ordered from least to most verbose. The order
+// is used to determine if a log level is enabled in IsLogLevel().
+var logLevels = []string{LogLevelError, LogLevelWarn, LogLevelInfo, LogLevelDebug, LogLevelTrace}
+
+// Actions when another fresher is running in the same directory, see
+// File.OnAlreadyRunning.
+const (
+	OnAlreadyRunningRefuse   = "refuse"
+	OnAlreadyRunningTakeover = "takeover"
+)
+
+// Backends for watching for file changes, see File.WatchBackend.
+const (
+	WatchBackendFSNotify = "fsnotify"
+	WatchBackendNative   = "native"
+)
+
+// File change event operations that can trigger a rebuild or rerun, see
+// File.EventOps.
+const (
+	EventOpWrite  = "write"
+	EventOpCreate = "create"
+	EventOpRemove = "remove"
+	EventOpRename = "rename"
+)
+
+// Modes for matching DirectoriesToIgnore, see File.IgnoreMatchMode.
+const (
+	IgnoreMatchAnchored = "anchored"
+	IgnoreMatchAnywhere = "anywhere"
+)
+
+// Formats for outputting build errors, see File.BuildErrorFormat.
+const (
+	BuildErrorFormatText = "text"
+	BuildErrorFormatJSON = "json"
+)
+
+// Actions when a build fails since modules are missing from go.mod or go.sum, see
+// File.OnMissingModules.
+const (
+	OnMissingModulesHint = "hint"
+	OnMissingModulesTidy = "tidy"
+)
+
+// How `go build` handles go.mod, see File.GoMod.
+const (
+	GoModMod      = "mod"
+	GoModReadonly = "readonly"
+	GoModVendor   = "vendor"
+)
+
+// What happens to file changes made while watching is paused, see File.PausedChanges.
+const (
+	PausedChangesQueue = "queue"
+	PausedChangesDrop  = "drop"
+)
+
+// If version control info is stamped into the binary, see File.GoBuildVCS.
+const (
+	GoBuildVCSAuto  = "auto"
+	GoBuildVCSTrue  = "true"
+	GoBuildVCSFalse = "false"
+)
+
+// When the binary is restarted after a successful build, see File.RestartPolicy.
+const (
+	RestartPolicyAlways    = "always"
+	RestartPolicyOnSuccess = "on-success"
+	RestartPolicyManual    = "manual"
+)
+
+// Commands for warming the build cache at start up, see File.WarmBuildCache.
+const (
+	WarmBuildCacheOff   = "off"
+	WarmBuildCacheBuild = "build"
+	WarmBuildCacheVet   = "vet"
+)
+
+// Tools for checking the formatting of changed files, see File.FormatCheck.
+const (
+	FormatCheckOff       = "off"
+	FormatCheckGofmt     = "gofmt"
+	FormatCheckGoimports = "goimports"
+)
+
 // File defines the list of configuration fields. The value for each field will be
 // set by a default or read from a config file. The config file is typically stored
 // in the same directory as the executable.
@@ -69,6 +181,17 @@ type File struct {
 	//Args is the list of arguments to pass to the binary when it is run.
 	Args []string `yaml:"Args"`
 
+	//Env is the environment variables set for the binary when it is run, in addition
+	//to fresher's environment.
+	Env map[string]string `yaml:"Env"`
+
+	//RunCommands are commands run, in order, each time the binary is run or rerun,
+	//before the binary. This is used for processes the binary depends on, i.e.: a
+	//database migration that must succeed before the binary is run, or a worker run
+	//alongside the binary. Long running commands are stopped, along with the binary,
+	//when the binary is rerun. See RunCommand.
+	RunCommands []RunCommand `yaml:"RunCommands"`
+
 	//TempDir is the directory off of WorkingDir where fresher will store the built
 	//binary, that will be run, and error logs.
 	TempDir string `yaml:"TempDir"`
@@ -86,10 +209,180 @@ type File struct {
 	//binary is first started.
 	NoRebuildExtensions []string `yaml:"NoRebuildExtensions"`
 
+	//EmbeddedPaths is the list of files or directories, relative to WorkingDir, that
+	//are embedded in the binary with //go:embed. A change to a matching file always
+	//rebuilds the binary, even if the file's extension is in NoRebuildExtensions or
+	//isn't in ExtensionsToWatch, since the rebuilt binary is needed to see the
+	//change. Wildcards are supported, including "**" to match any number of
+	//directories, i.e.: "web/static", "templates/*.html", or "**/*.sql".
+	EmbeddedPaths []string `yaml:"EmbeddedPaths"`
+
+	//DetectEmbeddedPaths finds the //go:embed directives in the binary's packages,
+	//after each successful build, and treats the embedded files the same as
+	//EmbeddedPaths. This saves having to keep EmbeddedPaths in sync with the code.
+	DetectEmbeddedPaths bool `yaml:"DetectEmbeddedPaths"`
+
+	//TemplateCheckPaths is the list of templates, relative to WorkingDir, that are
+	//parsed, using Go's template syntax, when they change and don't require a
+	//rebuild, i.e. an .html file in NoRebuildExtensions. If a template can't be
+	//parsed, the binary isn't restarted so that a broken template doesn't take down
+	//the running binary. Wildcards are supported the same as EmbeddedPaths, i.e.
+	//"templates/**/*.html".
+	TemplateCheckPaths []string `yaml:"TemplateCheckPaths"`
+
+	//TemplateCheckCommand is a command run when a file changes that doesn't require
+	//a rebuild, i.e. to validate templates with a project specific tool. The changed
+	//file is given in the FRESHER_FILE environment variable. If the command fails,
+	//the binary isn't restarted.
+	TemplateCheckCommand string `yaml:"TemplateCheckCommand"`
+
+	//SkipCommentOnlyChanges skips rebuilding when only comments, or whitespace,
+	//changed in the changed .go files. Directives, i.e. //go:embed, and cgo preambles
+	//are not treated as comments. The first change to each file after fresher starts
+	//always rebuilds since there is nothing to compare to.
+	//
+	//Note that line numbers, i.e. in a panic's stack trace, are not updated when a
+	//rebuild is skipped.
+	SkipCommentOnlyChanges bool `yaml:"SkipCommentOnlyChanges"`
+
+	//SkipExcludedFiles skips rebuilding when the changed .go file isn't part of the
+	//build, based on the GOOS and GOARCH the binary is built for and GoTags. For
+	//example, file_windows.go when building on Linux, a file with a //go:build tag
+	//not in GoTags, or a _test.go file.
+	SkipExcludedFiles bool `yaml:"SkipExcludedFiles"`
+
+	//SkipUnrelatedModules skips rebuilding when the changed file is in a module,
+	//found by the nearest go.mod, that the binary doesn't use. This is useful in a
+	//repo with several modules, i.e. a go.work monorepo with a module per service,
+	//where each service is run with its own fresher. Modules used via a replace
+	//directive, or the go.work file, are still rebuilt for. The modules used are
+	//found with `go list` after each successful build.
+	SkipUnrelatedModules bool `yaml:"SkipUnrelatedModules"`
+
+	//EventOps is the list of file change event operations (write, create, remove,
+	//rename) that trigger a rebuild or rerun. Removing "remove" and "rename" stops
+	//deleting or renaming a file from causing a rebuild, and removing "create" skips
+	//the noisy create events some editors send when saving.
+	EventOps []string `yaml:"EventOps"`
+
+	//Generators are commands run before rebuilding when a file matching a pattern
+	//changes, i.e.: "buf generate" when a .proto file changes. This is used for code
+	//generation so that generated code is up to date when the binary is built.
+	Generators []Generator `yaml:"Generators"`
+
+	//AssetCommands are commands run when a file matching a pattern changes, without
+	//rebuilding or restarting the binary, i.e.: running esbuild when a .ts file
+	//changes. This is used for front end assets that are bundled separately from the
+	//binary. Files matching an AssetCommand never rebuild or restart the binary.
+	AssetCommands []AssetCommand `yaml:"AssetCommands"`
+
+	//VulnCheck runs `govulncheck ./...` in the background when go.mod or go.sum
+	//changes and logs any vulnerabilities found as warnings. This doesn't block, or
+	//get blocked by, rebuilding. govulncheck must be installed, see
+	//https://pkg.go.dev/golang.org/x/vuln/cmd/govulncheck.
+	VulnCheck bool `yaml:"VulnCheck"`
+
+	//BenchmarkPackage is the package, i.e. "./internal/parser", whose benchmarks are
+	//run with `go test -bench` in the background after each successful build. The
+	//results are compared to the previous run and the change in time, and
+	//allocations, per operation is logged. This helps when iterating on the
+	//performance of hot functions. Leave blank to disable.
+	BenchmarkPackage string `yaml:"BenchmarkPackage"`
+
+	//BenchmarkPattern is the regular expression, passed to `go test -bench`, of the
+	//benchmarks in BenchmarkPackage to run.
+	BenchmarkPattern string `yaml:"BenchmarkPattern"`
+
+	//BenchmarkCount is how many times each benchmark is run, passed to `go test
+	//-count`. The results are averaged, so a higher count gives a more reliable
+	//comparison at the cost of taking longer.
+	BenchmarkCount int `yaml:"BenchmarkCount"`
+
 	//DirectoriesToIgnore is the list of directories that won't be watched for file
 	//change events. Typically directories such as .git, node_modules, etc.
 	DirectoriesToIgnore []string `yaml:"DirectoriesToIgnore"`
 
+	//IgnoreMatchMode is how DirectoriesToIgnore are matched against directories.
+	//Matching is done on whole path components, so "tmp" does not match "tmpl".
+	//With "anchored", each entry is a path relative to WorkingDir, so "web/static"
+	//only matches WorkingDir/web/static. With "anywhere", an entry matches at any
+	//depth, so "node_modules" matches both WorkingDir/node_modules and
+	//WorkingDir/web/node_modules.
+	IgnoreMatchMode string `yaml:"IgnoreMatchMode"`
+
+	//AutoIgnore ignores common build output, dependency, editor, and coverage
+	//directories (vendor, dist, bin, .idea, __pycache__, coverage, .nyc_output,
+	//htmlcov), and any directory containing a .fresherignore file, in addition to
+	//DirectoriesToIgnore. This saves having to list these directories by hand. Off by
+	//default since vendor and bin may hold code that should cause a rebuild.
+	AutoIgnore bool `yaml:"AutoIgnore"`
+
+	//WatchVendor watches the vendor directory, even though AutoIgnore ignores it,
+	//so that patching vendored dependencies, i.e. while debugging, rebuilds the
+	//binary. vendor is still ignored if listed in DirectoriesToIgnore.
+	WatchVendor bool `yaml:"WatchVendor"`
+
+	//IgnoreEditorTempFiles ignores file change events for temporary files created by
+	//editors when saving, such as vim swap and backup files (.swp, 4913, file~),
+	//JetBrains ___jb_tmp___ files, and emacs lockfiles (.#file). This check is done
+	//before ExtensionsToWatch so that a temporary file with a watched extension does
+	//not cause a rebuild.
+	IgnoreEditorTempFiles bool `yaml:"IgnoreEditorTempFiles"`
+
+	//MaxWatchDepth is how many directories deep, below WorkingDir, directories are
+	//watched. This, and MaxWatchedDirectories, prevent fresher from trying to watch
+	//the entire filesystem if accidentally run in the wrong directory, i.e.: $HOME.
+	//Set to 0 for no limit.
+	MaxWatchDepth int `yaml:"MaxWatchDepth"`
+
+	//MaxWatchedDirectories is the most directories that will be watched. A warning
+	//is shown if more directories would be watched. Set to 0 for no limit.
+	MaxWatchedDirectories int `yaml:"MaxWatchedDirectories"`
+
+	//FollowSymlinks causes symlinked directories, i.e.: a symlinked shared module, to
+	//be watched as if they were regular directories. Each directory is only watched
+	//once, based on its real path, so symlink cycles are handled. Symlinked
+	//directories are not watched by default.
+	FollowSymlinks bool `yaml:"FollowSymlinks"`
+
+	//WatchReplacedModules watches the directories of modules replaced with a local
+	//path in go.mod, i.e. "replace example.com/lib => ../lib", so that editing a
+	//local copy of a dependency rebuilds the binary. Directories within a replaced
+	//module are ignored the same as within WorkingDir.
+	WatchReplacedModules bool `yaml:"WatchReplacedModules"`
+
+	//WatchBackend is how file changes are watched for. With "fsnotify", each
+	//directory is watched separately; this works everywhere. With "native", the
+	//working directory is watched recursively using the OS's API, which is much
+	//faster to set up on huge repos. Native watching is only supported on Windows,
+	//fsnotify is used on other OSes.
+	WatchBackend string `yaml:"WatchBackend"`
+
+	//RescanIntervalSeconds is how often the directory tree is rescanned to find file
+	//changes the watcher missed. Some environments, such as WSL2 accessing files
+	//under /mnt or SMB shares, drop file change events. Rescanning also finds new
+	//directories to watch. Set to 0 to disable.
+	RescanIntervalSeconds int `yaml:"RescanIntervalSeconds"`
+
+	//WatchGit watches the repo's .git directory for git operations that change many
+	//files at once, i.e. switching branches or rebasing. Instead of rebuilding for
+	//each changed file, fresher waits for the operation to complete, watches any new
+	//directories, and rebuilds once.
+	//
+	//Overridden by the -watch-git flag.
+	WatchGit bool `yaml:"WatchGit"`
+
+	//SkipInitialRun causes the binary to not be built and run when fresher starts,
+	//only once a file changes. This is useful when the binary is already running
+	//elsewhere or only rebuild-on-change is wanted.
+	SkipInitialRun bool `yaml:"SkipInitialRun"`
+
+	//SkipInitialBuild causes the binary already in TempDir, i.e. built by a previous
+	//run of fresher, to be run when fresher starts rather than building an identical
+	//binary. The binary is rebuilt once a file changes. If the binary doesn't exist,
+	//it is built.
+	SkipInitialBuild bool `yaml:"SkipInitialBuild"`
+
 	//BuildDelayMilliseconds is the delay between a file change event occuring and
 	//`go build` being run. This delay is helpful to prevent unnecessary buildng when
 	//multiple file change events occur in quick succession.
@@ -99,6 +392,36 @@ type File struct {
 	//change event occurs.
 	BuildDelayMilliseconds int64 `yaml:"BuildDelayMilliseconds"`
 
+	//BulkChangeEvents is the number of file change events, received in quick
+	//succession, that are treated as a bulk change, i.e. a formatter rewriting every
+	//file or checking out a branch. Once exceeded, fresher waits until no events are
+	//received for BulkDelayMilliseconds and then rebuilds once, rather than
+	//repeatedly starting and killing builds while files are still changing.
+	BulkChangeEvents int `yaml:"BulkChangeEvents"`
+
+	//BulkDelayMilliseconds is how long to wait for more events once a bulk change is
+	//detected, see BulkChangeEvents. Set to 0 to disable bulk change detection.
+	BulkDelayMilliseconds int64 `yaml:"BulkDelayMilliseconds"`
+
+	//PausedChanges is what happens to file changes made while watching is paused,
+	//i.e. during a git rebase or a large refactor. Watching is paused, and resumed,
+	//by typing "p" followed by enter or via the control API. With "queue", the
+	//binary is rebuilt once when watching is resumed if any files changed. With
+	//"drop", changes made while paused are ignored.
+	PausedChanges string `yaml:"PausedChanges"`
+
+	//ConfirmRebuildFiles is the number of files that, when changed at once, i.e. by
+	//a mass find and replace or a script, cause fresher to ask before rebuilding.
+	//Type "y" followed by enter to rebuild, anything else skips the rebuild. This
+	//protects against long builds triggered by accident. Set to 0 to always
+	//rebuild without asking.
+	ConfirmRebuildFiles int `yaml:"ConfirmRebuildFiles"`
+
+	//BuildTimeoutSeconds is how long `go build` can run before it is killed, i.e. a
+	//hung cgo compile or module download. A build that times out is treated as a
+	//failed build, so the previous binary keeps running. Set to 0 to disable.
+	BuildTimeoutSeconds int `yaml:"BuildTimeoutSeconds"`
+
 	//BuildName is the name of the binary output by `go build` and saved to TempDir.
 	BuildName string `yaml:"BuildName"`
 
@@ -107,6 +430,108 @@ type File struct {
 	//analyzing errors rather then looking at output in terminal.
 	BuildLogFilename string `yaml:"BuildLogFilename"`
 
+	//BuildLogMode determines how build errors are saved to BuildLogFilename. With
+	//"overwrite", only the errors from the latest build are kept. With "append", each
+	//failure is added to the end of the file with a timestamped header noting the
+	//file change that triggered the build, giving a history of failures.
+	BuildLogMode string `yaml:"BuildLogMode"`
+
+	//BuildLogMaxSizeKB is the size BuildLogFilename can grow to when BuildLogMode is
+	//"append". The oldest failures are removed once this size is reached. Set to -1
+	//to never remove old failures.
+	BuildLogMaxSizeKB int `yaml:"BuildLogMaxSizeKB"`
+
+	//BuildErrorFormat is how errors from a failed build are output. With "text",
+	//each error is output as file:line:col: message, which most editors and
+	//terminals can jump to, followed by a count of errors. With "json", each error is
+	//output as a line of JSON for consumption by other tools.
+	BuildErrorFormat string `yaml:"BuildErrorFormat"`
+
+	//OnMissingModules is what happens when a build fails since a package is imported
+	//from a module missing from go.mod or go.sum, which often happens after pulling
+	//a teammate's branch. With "hint", the command to fix go.mod is logged. With
+	//"tidy", `go mod tidy` is run and the binary is rebuilt.
+	OnMissingModules string `yaml:"OnMissingModules"`
+
+	//BinaryGrowthWarnKB is how much the built binary can grow, compared to the
+	//previous successful build, before a warning is logged. This helps catch
+	//accidentally embedding a huge file. Set to 0 to disable the warning; the
+	//binary's size is always logged.
+	BinaryGrowthWarnKB int64 `yaml:"BinaryGrowthWarnKB"`
+
+	//OnBuildErrorOpenEditor is a command, as a text/template, that is run with the
+	//first error from a failed build to open the file in an editor. The template is
+	//given the error's File, Line, Column, and Message. For example, to use VS Code:
+	//`code -g {{.File}}:{{.Line}}:{{.Column}}`. Leave blank to disable.
+	OnBuildErrorOpenEditor string `yaml:"OnBuildErrorOpenEditor"`
+
+	//NotifyOnBuildResult shows a desktop notification when a build fails and when a
+	//build succeeds after a failure. This is useful when fresher is running in a
+	//background terminal. Uses notify-send on Linux, osascript on macOS, and
+	//PowerShell on Windows.
+	NotifyOnBuildResult bool `yaml:"NotifyOnBuildResult"`
+
+	//BellOnError rings the terminal bell when a build fails. This is useful when
+	//fresher is running on a second monitor and you may not see the build fail.
+	BellOnError bool `yaml:"BellOnError"`
+
+	//BellOnRecovery rings the bell when a build succeeds after a failure. This is
+	//only used when BellOnError is enabled.
+	BellOnRecovery bool `yaml:"BellOnRecovery"`
+
+	//BellCommand is a command run in place of writing the terminal bell character,
+	//for example to play a sound file. Leave blank to use the terminal bell.
+	BellCommand string `yaml:"BellCommand"`
+
+	//Colors configures the colors used for fresher's logging. Colors are also
+	//disabled when the NO_COLOR environmental variable is set.
+	//See https://no-color.org/.
+	Colors Colors `yaml:"Colors"`
+
+	//OutputPrefix is added to the start of each line of output from the running
+	//binary so that the binary's output can be told apart from fresher's logging.
+	//For example, "app". Leave blank to output the binary's output as-is.
+	OutputPrefix string `yaml:"OutputPrefix"`
+
+	//OutputTimestamps adds a timestamp to the start of each line of output from the
+	//running binary. This is useful for correlating the binary's logging with file
+	//change events if the binary doesn't add timestamps itself.
+	OutputTimestamps bool `yaml:"OutputTimestamps"`
+
+	//OutputLineBuffered causes output from the running binary to be written one
+	//whole line at a time so that partial lines from stdout and stderr don't get
+	//jumbled together. Output is always line buffered when OutputPrefix or
+	//OutputTimestamps are set.
+	OutputLineBuffered bool `yaml:"OutputLineBuffered"`
+
+	//RunHeader is the line, as a text/template, logged after the binary is run to
+	//separate fresher's logging from the binary's output. The template is given the
+	//Build number, number of Restarts, the File and Op that caused the rebuild, the
+	//Time, and the git Branch. Leave blank to log a line of dashes. For example:
+	//
+	//	--- #{{.Build}} {{.File}} ({{.Branch}}) {{.Time.Format "15:04:05"}} ---
+	RunHeader string `yaml:"RunHeader"`
+
+	//ClearScreenOnRebuild clears the terminal, and its scrollback, when a file change
+	//is detected, before the binary is rebuilt and rerun, so that output from
+	//previous runs of the binary isn't confused with output from the new run. The
+	//terminal isn't cleared when fresher's output isn't a terminal.
+	ClearScreenOnRebuild bool `yaml:"ClearScreenOnRebuild"`
+
+	//HighlightPanics detects a panic in the running binary's stderr and frames and
+	//colors the panic and stack trace so that crashes stand out from the binary's
+	//other logging. The file and line the panic occurred at is also logged. stderr is
+	//line buffered when this is set.
+	HighlightPanics bool `yaml:"HighlightPanics"`
+
+	//OnPanicOpenEditor opens the file and line a panic occurred at using the
+	//OnBuildErrorOpenEditor command. This is only used when HighlightPanics is set.
+	OnPanicOpenEditor bool `yaml:"OnPanicOpenEditor"`
+
+	//OutputFilters hides lines of output from the running binary, for example noisy
+	//access logs, without having to modify the binary's logging.
+	OutputFilters OutputFilters `yaml:"OutputFilters"`
+
 	//GoTags is anything provided to `go run` or `go build` -tags flag.
 	//
 	//Any tags provided in the config, from file or defaults, are overridden by
@@ -124,11 +549,278 @@ type File struct {
 	//See https://pkg.go.dev/cmd/go#:~:text=but%20still%20recognized.)%0A%2D-,trimpath,-remove%20all%20file.
 	GoTrimpath bool `yaml:"GoTrimpath"`
 
+	//GoBuildVCS is provided to `go build` -buildvcs flag: "true" or "false" to
+	//always, or never, stamp the binary with version control info. With "auto",
+	//-buildvcs=false is provided if WorkingDir isn't in a git repo or git isn't
+	//installed, i.e. in a container, since stamping fails or is slow, otherwise Go's
+	//default is used.
+	GoBuildVCS string `yaml:"GoBuildVCS"`
+
+	//GoCache is the GOCACHE used for builds fresher runs, i.e. a ramdisk or a
+	//project local directory, to keep development builds separate from other builds
+	//and to speed up builds on slow disks. A directory within TempDir, which isn't
+	//watched, works well; the first build with an empty cache is slow though. Leave
+	//blank to use Go's default, or GOCACHE.
+	GoCache string `yaml:"GoCache"`
+
+	//GoTmpDir is the GOTMPDIR, where Go writes temporary files while building, used
+	//for builds fresher runs, i.e. a ramdisk. Leave blank to use Go's default, or
+	//GOTMPDIR.
+	GoTmpDir string `yaml:"GoTmpDir"`
+
+	//BuildConfigs are named sets of GoTags, GoLdflags, and Env, i.e. "sqlite" and
+	//"postgres" builds, that can be switched between while fresher is running by
+	//typing b, to switch to the next build config, or b and a name, then enter, or
+	//with the control API. The binary is rebuilt after switching. See BuildConfig.
+	BuildConfigs []BuildConfig `yaml:"BuildConfigs"`
+
+	//BuildConfig is the name of the build config, from BuildConfigs, used when
+	//fresher starts. Defaults to the first build config.
+	BuildConfig string `yaml:"BuildConfig"`
+
+	//GoMod is provided to `go build` -mod flag: "mod" to update go.mod as needed,
+	//"readonly" to fail if go.mod needs updating, or "vendor" to build using the
+	//vendor directory. Leave blank to use Go's default, or the -mod set in GOFLAGS.
+	GoMod string `yaml:"GoMod"`
+
+	//DownloadModules runs `go mod download` when fresher starts, before the first
+	//build, so that the first build isn't slowed down by downloading modules.
+	DownloadModules bool `yaml:"DownloadModules"`
+
+	//BuildVerbose passes the -v and -x flags to `go build` and shows the output as
+	//the binary is built. This shows which packages are being recompiled, and the
+	//commands run to do so, which helps diagnose build cache misses causing slow
+	//builds. This is also enabled when LogLevel is "trace".
+	BuildVerbose bool `yaml:"BuildVerbose"`
+
+	//FormatCheck checks if changed .go files are formatted when they trigger a build,
+	//using "gofmt" or "goimports", and logs a warning naming each file that isn't.
+	//Files are never modified. This catches formatting drift before code review.
+	//Set to "off" to disable.
+	FormatCheck string `yaml:"FormatCheck"`
+
+	//BuildParallelism is the number of packages `go build` compiles at once, passed
+	//as the -p flag. Lower this so that building doesn't use every CPU and slow down
+	//the running binary, your editor, etc. Set to 0 to use Go's default, the number
+	//of CPUs.
+	BuildParallelism int `yaml:"BuildParallelism"`
+
+	//BuildLowPriority runs `go build` at a lower OS priority so that other programs
+	//stay responsive while building. On Linux/macOS the build is niced, and on Linux
+	//its disk access is also lowered. On Windows, the build is run with the below
+	//normal priority class.
+	BuildLowPriority bool `yaml:"BuildLowPriority"`
+
+	//MinimumGoVersion is the oldest version of Go, i.e. "1.21", the binary can be
+	//built with. fresher stops when starting if the `go` found on the PATH is older,
+	//rather than failing with a confusing error on each build. Leave blank to build
+	//with any version.
+	MinimumGoVersion string `yaml:"MinimumGoVersion"`
+
+	//WarmBuildCache runs `go build ./...` ("build") or `go vet ./...` ("vet") in the
+	//background once the binary is first built, so that every package in the module
+	//is in Go's build cache and the first build after a change to any package is
+	//fast. Warming is stopped if a build starts since the build warms the cache for
+	//the packages it needs anyway. Set to "off" to disable.
+	WarmBuildCache string `yaml:"WarmBuildCache"`
+
+	//CrossCheckTargets are other platforms, as GOOS or GOOS/GOARCH, i.e. "windows"
+	//or "linux/arm64", the binary is compiled for, but not run, in the background
+	//after each successful build. Failures are logged so that platform specific
+	//breakage is noticed while developing rather than in CI. GOARCH defaults to the
+	//current GOARCH, or wasm for js and wasip1.
+	CrossCheckTargets []string `yaml:"CrossCheckTargets"`
+
 	//Verbose causes fresher to output more logging. Use for diagnostics when
 	//determining which files/directories/extensions are being watched and when file
 	//change events are occuring.
+	//
+	//Deprecated: use LogLevel "debug" instead. This is kept so that older config
+	//files still work; if LogLevel is not set and Verbose is true, LogLevel is set
+	//to "debug".
 	Verbose bool `yaml:"Verbose"`
 
+	//LogLevel is how much logging fresher outputs. From least to most verbose:
+	//  - error: only errors, near-silent.
+	//  - warn: errors and warnings.
+	//  - info: the default, file changes, builds, and runs.
+	//  - debug: build commands and more details about builds and runs.
+	//  - trace: every file change event and directory added to the watcher.
+	LogLevel string `yaml:"LogLevel"`
+
+	//MetricsAddress is the host:port an HTTP server will listen on to expose build
+	//statistics in Prometheus format at /metrics. Leave blank to disable.
+	MetricsAddress string `yaml:"MetricsAddress"`
+
+	//ControlAddress is where a server listens for requests to rebuild or restart the
+	//binary, query fresher's status, and stream logs. This is useful for editor
+	//plugins and status lines. Use a host:port, i.e. "localhost:9101", or a Unix
+	//socket prefixed with "unix:", i.e. "unix:tmp/fresher.sock". Leave blank to
+	//disable.
+	ControlAddress string `yaml:"ControlAddress"`
+
+	//StaticAddress is the host:port a development file server listens on to serve
+	//the files in StaticDirectory. This lets front end work be done without the
+	//binary running. Responses are not cached and directories are listed. Leave
+	//blank to disable.
+	StaticAddress string `yaml:"StaticAddress"`
+
+	//StaticDirectory is the directory, relative to WorkingDir, served at the root of
+	//StaticAddress.
+	StaticDirectory string `yaml:"StaticDirectory"`
+
+	//DebugAddress is the host:port an HTTP server will listen on to expose profiling
+	//data, via net/http/pprof, and runtime stats for fresher itself, not the binary.
+	//This is used to diagnose fresher using too much CPU, memory, goroutines, or file
+	//handles. Use a localhost address since profiles expose fresher's internals.
+	//Leave blank to disable.
+	DebugAddress string `yaml:"DebugAddress"`
+
+	//EventStream is where newline-delimited JSON events describing file changes,
+	//builds, and runs are written. This is designed for editor plugins that show
+	//fresher's status inline. Use "fd:N" for a file descriptor, "unix:/path" or
+	//"tcp:host:port" to connect to a socket, or a path to a file. Leave blank to
+	//disable.
+	//
+	//Overridden by the -event-stream flag.
+	EventStream string `yaml:"EventStream"`
+
+	//TriggerFile is a path, relative to WorkingDir, to a file that forces a rebuild
+	//when it is created or modified (i.e. `touch tmp/fresher-trigger`). This lets
+	//other tools, such as git hooks or code generators, request a rebuild without
+	//faking a file save. A rebuild can also be requested with SIGUSR1 on non-Windows
+	//OSes. Leave blank to disable.
+	TriggerFile string `yaml:"TriggerFile"`
+
+	//PIDFile is the name of a file saved in TempDir that stores the PIDs of fresher
+	//and the running binary. This is used to make sure only one fresher runs per
+	//directory and to stop a binary left running by a fresher that crashed or was
+	//killed. A PID saved in the file is only stopped if the process is still running
+	//the same executable, since PIDs are reused by the OS. Leave blank to disable.
+	PIDFile string `yaml:"PIDFile"`
+
+	//StatusFile is the name of a file saved in TempDir that is kept up to date with
+	//fresher's state, as JSON, i.e. building, running, or failed, plus the result and
+	//time of the last build. This lets tools such as tmux, Polybar, or an editor's
+	//status line show fresher's state without using the control API. The file is
+	//deleted when fresher exits. Leave blank to disable.
+	StatusFile string `yaml:"StatusFile"`
+
+	//OnAlreadyRunning is what happens when fresher is started in a directory where
+	//another fresher is already running. With "refuse", the new fresher exits with
+	//an error. With "takeover", the running fresher and its binary are stopped.
+	OnAlreadyRunning string `yaml:"OnAlreadyRunning"`
+
+	//KillPortConflicts is a list of ports that, when the binary fails to start since
+	//the port is already in use, the process using the port is stopped and the binary
+	//is rerun. Only list ports used for development! Whether or not a port is listed,
+	//the process using the port is logged.
+	KillPortConflicts []int `yaml:"KillPortConflicts"`
+
+	//RunDelayMilliseconds is the delay between the old binary exiting and the
+	//rebuilt binary being run. This is useful for binaries that need a moment to
+	//release resources, i.e. a port or lock file, once they exit.
+	RunDelayMilliseconds int64 `yaml:"RunDelayMilliseconds"`
+
+	//WaitForPorts is a list of ports that must be released by the old binary before
+	//the rebuilt binary is run. This prevents the rebuilt binary failing to start
+	//with "address already in use" since the OS hasn't released the port yet.
+	WaitForPorts []int `yaml:"WaitForPorts"`
+
+	//AutoRestart reruns the binary when it exits with an error. A delay, that
+	//doubles with each crash, is used between restarts so that the binary isn't
+	//rerun in a tight loop.
+	AutoRestart bool `yaml:"AutoRestart"`
+
+	//RestartPolicy is when the binary is restarted after a successful build, or when
+	//a file that doesn't require a rebuild changes. With "always", the binary is
+	//restarted each time. With "on-success", the binary is only restarted if the
+	//previous run has exited. With "manual", the binary is only restarted when "r"
+	//is typed followed by enter, or via the control API, which is useful when
+	//stepping through the binary with a debugger.
+	RestartPolicy string `yaml:"RestartPolicy"`
+
+	//CrashLoopSeconds is how soon after starting the binary must exit with an error
+	//to count as a crash when detecting a crash loop.
+	CrashLoopSeconds int `yaml:"CrashLoopSeconds"`
+
+	//CrashLoopLimit is the number of crashes in a row, each within CrashLoopSeconds
+	//of starting, after which AutoRestart stops rerunning the binary until a file
+	//changes. The last output from the binary is shown since it most likely explains
+	//the crash.
+	CrashLoopLimit int `yaml:"CrashLoopLimit"`
+
+	//MaxOpenFiles is the limit on open file descriptors fresher raises itself to
+	//when starting. A high limit is needed for watching a HUGE amount of files. If
+	//the limit cannot be raised this high, the highest limit allowed is used and a
+	//warning is shown. Set to -1 to leave the limit as-is. Not used on Windows.
+	MaxOpenFiles int `yaml:"MaxOpenFiles"`
+
+	//WASMAddress is the host:port a server listens on to serve a Go WebAssembly
+	//frontend. When set, the binary is built with GOOS=js and GOARCH=wasm and is
+	//served, along with Go's wasm_exec.js and an index page, instead of being run.
+	//The page reloads after each successful build. Leave blank to build and run the
+	//binary as usual.
+	WASMAddress string `yaml:"WASMAddress"`
+
+	//Hooks are commands run at points in fresher's lifecycle, i.e.: before each
+	//build. This allows integrating with other tools without fresher needing to
+	//support each tool. See Hooks.
+	Hooks Hooks `yaml:"Hooks"`
+
+	//DockerService is the name of a Docker Compose service to run the binary in,
+	//instead of running the binary on the host. This is used when the binary needs
+	//the compose network or other services to work. After each successful build, the
+	//binary is copied into the service's container (see DockerBinaryPath) and the
+	//container is restarted. The binary is built for Linux. Leave blank to run the
+	//binary on the host.
+	DockerService string `yaml:"DockerService"`
+
+	//DockerComposeFile is the path to the compose file, if not the default found by
+	//`docker compose`.
+	DockerComposeFile string `yaml:"DockerComposeFile"`
+
+	//DockerBinaryPath is the path in the container the binary is copied to. Leave
+	//blank if TempDir is bind-mounted into the container instead, in which case the
+	//container is just restarted.
+	DockerBinaryPath string `yaml:"DockerBinaryPath"`
+
+	//LogFile is the name of a file saved in TempDir that fresher's logging will be
+	//copied to. This is useful for inspecting logs after the terminal's scrollback
+	//has been lost. Leave blank to disable.
+	LogFile string `yaml:"LogFile"`
+
+	//LogFileMaxSizeMB is the size the LogFile can grow to before it is rotated. One
+	//rotated file is kept with a ".1" suffix. Set to -1 to never rotate.
+	LogFileMaxSizeMB int `yaml:"LogFileMaxSizeMB"`
+
+	//LogFileIncludeOutput determines if the output from the running binary is also
+	//copied to LogFile. This is helpful for capturing stack traces on crashes.
+	LogFileIncludeOutput bool `yaml:"LogFileIncludeOutput"`
+
+	//RunLogsToKeep is the number of runs of the binary whose output is saved, each
+	//run to its own file in TempDir named run-0001.log, run-0002.log, etc. This is
+	//useful for comparing the binary's behavior before and after a change. Older
+	//files are deleted. Set to 0 to disable.
+	RunLogsToKeep int `yaml:"RunLogsToKeep"`
+
+	//CheckForUpdates checks GitHub, at most once a day, for a newer release of
+	//fresher when starting and logs a notice if one is available. Use the -upgrade
+	//flag to install the newer release.
+	CheckForUpdates bool `yaml:"CheckForUpdates"`
+
+	//Extends is the path to another config file whose fields are used as the base for
+	//this config file, i.e. a shared "../fresher.base.conf" in a monorepo. Fields set
+	//in this config file override the base's fields. The path is relative to this
+	//config file's directory. A base config file can extend another config file.
+	Extends string `yaml:"Extends,omitempty"`
+
+	//Profiles are named sets of fields that override the fields above, i.e. a
+	//"debug" profile that sets GoTags and Env. A profile is selected with the
+	//-profile flag. The "default" profile, if it exists, is used when -profile is not
+	//provided. Fields not set in a profile are left as-is.
+	Profiles map[string]yaml.MapSlice `yaml:"Profiles,omitempty"`
+
 	//usingBuiltInDefaults is set to true only when File isn't actually read from a
 	//file and we are using the built in defaults instead. This is used to reduce
 	//diagnostic output (i.e.: path to config file) when a config file wasn't used
@@ -136,11 +828,187 @@ type File struct {
 	usingBuiltInDefaults bool `yaml:"-"`
 }
 
+// Colors defines the colors used for each type of logging fresher outputs. Valid
+// colors are listed in validColors.
+type Colors struct {
+	//Disabled turns off colorizing of fresher's logging.
+	Disabled bool `yaml:"Disabled"`
+
+	//Events is the color used for logging file changes, builds, and runs.
+	Events string `yaml:"Events"`
+
+	//Warnings is the color used for verbose logging and warnings.
+	Warnings string `yaml:"Warnings"`
+
+	//Errors is the color used for logging errors.
+	Errors string `yaml:"Errors"`
+
+	//Output is the color of the OutputPrefix for the binary's stdout.
+	Output string `yaml:"Output"`
+
+	//OutputErrors is the color of the OutputPrefix for the binary's stderr.
+	OutputErrors string `yaml:"OutputErrors"`
+}
+
+// OutputFilters defines regular expressions, in Go's regexp syntax, used to hide
+// lines of output from the running binary.
+type OutputFilters struct {
+	//Include, if any patterns are given, causes only lines matching at least one
+	//pattern to be shown.
+	Include []string `yaml:"Include"`
+
+	//Exclude causes lines matching any pattern to be hidden.
+	Exclude []string `yaml:"Exclude"`
+}
+
+// Generator defines a command run before rebuilding when a file matching Pattern
+// changes.
+type Generator struct {
+	//Pattern is matched against changed files using filepath.Match syntax. A
+	//pattern without a path separator, i.e.: "*.proto", is matched against the file's
+	//name. A pattern with a path separator, i.e.: "api/*.proto", is matched against
+	//the file's path relative to WorkingDir.
+	Pattern string `yaml:"Pattern"`
+
+	//Command is run in WorkingDir, i.e.: "buf generate". The command is split on
+	//whitespace to get the command and its arguments; quoting is not supported.
+	Command string `yaml:"Command"`
+}
+
+// Hooks defines commands run at points in fresher's lifecycle. Each command is split
+// on whitespace to get the command and its arguments; quoting is not supported.
+// Commands are run in WorkingDir, in order, and fresher waits for each to complete.
+// A failed command is logged but does not stop fresher.
+//
+// Environment variables describing the event are set for each command, see the
+// README.
+type Hooks struct {
+	//PreWatch commands are run before directories are watched.
+	PreWatch []string `yaml:"PreWatch"`
+
+	//PreBuild commands are run before each build.
+	PreBuild []string `yaml:"PreBuild"`
+
+	//PostBuildSuccess commands are run after each successful build.
+	PostBuildSuccess []string `yaml:"PostBuildSuccess"`
+
+	//PostBuildFailure commands are run after each failed build.
+	PostBuildFailure []string `yaml:"PostBuildFailure"`
+
+	//PreRun commands are run before the binary is run or rerun.
+	PreRun []string `yaml:"PreRun"`
+
+	//PostStop commands are run after the binary stops, either because it exited or
+	//because it was stopped to be rerun.
+	PostStop []string `yaml:"PostStop"`
+}
+
+// AssetCommand defines a command run, without rebuilding or restarting the binary,
+// when a file matching Pattern changes. Pattern and Command work the same as for a
+// Generator.
+type AssetCommand struct {
+	Pattern string `yaml:"Pattern"`
+	Command string `yaml:"Command"`
+}
+
+// RunCommand defines a command run each time the binary is run or rerun.
+type RunCommand struct {
+	//Name is shown before each line of the command's output. Defaults to the name of
+	//the command's executable.
+	Name string `yaml:"Name"`
+
+	//Command is run in WorkingDir, i.e.: "go run ./cmd/worker". The command is split
+	//on whitespace to get the command and its arguments; quoting is not supported.
+	Command string `yaml:"Command"`
+
+	//Env is the environment variables set for the command, in addition to fresher's
+	//environment and the binary's Env.
+	Env map[string]string `yaml:"Env"`
+
+	//Once runs the command to completion before the next command, or the binary, is
+	//run. If the command fails, the following commands and the binary are not run.
+	//Without Once, the command is started and left running alongside the binary.
+	Once bool `yaml:"Once"`
+
+	//ReadyAddress is an address, i.e.: "localhost:9000", the command listens on once
+	//it is ready. The next command, or the binary, isn't run until a connection to
+	//the address succeeds so that the binary doesn't start before a service it calls
+	//is up. Not used with Once.
+	//
+	//This only orders the RunCommands of this fresher. There is no DependsOn between
+	//services each run by their own fresher, a service isn't waited on, and its
+	//dependents aren't restarted when it is rebuilt.
+	ReadyAddress string `yaml:"ReadyAddress"`
+}
+
+// BuildConfig defines a named set of build settings, see BuildConfigs.
+type BuildConfig struct {
+	//Name is used to switch to the build config, i.e. "postgres".
+	Name string `yaml:"Name"`
+
+	//GoTags is used in place of the GoTags field, even if blank, so that a build
+	//config can build without any tags. The -tags flag is ignored when using build
+	//configs.
+	GoTags string `yaml:"GoTags"`
+
+	//GoLdflags is used in place of the GoLdflags field, if set.
+	GoLdflags string `yaml:"GoLdflags"`
+
+	//Env is the environment variables set for `go build` and the binary, in addition
+	//to the Env field, i.e. CGO_ENABLED=1 to build with sqlite.
+	Env map[string]string `yaml:"Env"`
+}
+
+// autoIgnoreDirectories is the list of directory names ignored when AutoIgnore is
+// enabled. These are common build output, dependency, editor, and coverage
+// directories that rarely hold source files for the binary.
+var autoIgnoreDirectories = []string{"vendor", "dist", "bin", ".idea", "__pycache__", "coverage", ".nyc_output", "htmlcov"}
+
+// IgnoreMarkerFile is the name of a file that, when it exists in a directory, causes
+// the directory to be ignored when AutoIgnore is enabled.
+const IgnoreMarkerFile = ".fresherignore"
+
+// editorTempFilePatterns is the list of patterns, in filepath.Match syntax, matching
+// the names of temporary files editors create when saving. These are ignored when
+// IgnoreEditorTempFiles is enabled.
+var editorTempFilePatterns = []string{
+	"*.swp",         //vim swap files.
+	"*.swo",         //vim swap files.
+	"*.swx",         //vim swap files.
+	"4913",          //vim checks if a directory is writable with this file.
+	"*~",            //vim and emacs backup files.
+	"*___jb_tmp___", //JetBrains "safe write" files.
+	"*___jb_old___", //JetBrains "safe write" files.
+	".#*",           //emacs lockfiles.
+	"#*#",           //emacs auto-save files.
+}
+
+// goVersionPattern matches a Go version, without the "go" prefix, i.e. 1.21 or 1.21.3.
+var goVersionPattern = regexp.MustCompile(`^1(\.\d+){1,2}$`)
+
+// crossCheckTargetPattern matches a CrossCheckTargets entry, GOOS or GOOS/GOARCH, i.e.
+// windows or linux/arm64.
+var crossCheckTargetPattern = regexp.MustCompile(`^[a-z0-9]+(/[a-z0-9]+)?$`)
+
+// validColors is the list of colors that can be used in Colors.
+var validColors = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
 // parsedConfig is the data parsed from the config file. This data is stored so that
 // we don't need to reparse the config file each time we need a piece of data from it.
 // This is not exported so that changes cannot be made to the parsed data as easily.
 // Use the Data() func to get the data for use elsewhere.
-var parsedConfig File
+//
+// The stored config is never modified, Update() and Reload() store a new config
+// instead, so that the config can be read from any goroutine without locking.
+var parsedConfig atomic.Pointer[File]
+
+// Used to reread the config and reapply updates, see Reload(). source returns the
+// config as set by Read() or Use().
+var (
+	updateMu sync.Mutex
+	source   func() (*File, error)
+	updates  []func(*File) error
+)
 
 // newDefaultConfig returns a File with default values set for each field.
 func newDefaultConfig() (f *File) {
@@ -154,17 +1022,129 @@ func newDefaultConfig() (f *File) {
 	f = &File{
 		WorkingDir:             workingDir,
 		EntryPoint:             ".",
+		Env:                    map[string]string{},
+		RunCommands:            []RunCommand{},
 		TempDir:                filepath.Join(workingDir, "tmp"),
 		ExtensionsToWatch:      []string{".go", ".html"},
 		NoRebuildExtensions:    []string{".html"},
+		EmbeddedPaths:          []string{},
+		DetectEmbeddedPaths:    true, //embedded files are always rebuilt.
+		TemplateCheckPaths:     []string{},
+		TemplateCheckCommand:   "",
+		EventOps:               []string{EventOpWrite, EventOpCreate, EventOpRemove, EventOpRename},
 		DirectoriesToIgnore:    []string{"tmp", "node_modules", ".git", ".vscode"},
+		IgnoreMatchMode:        IgnoreMatchAnchored,        //same as how DirectoriesToIgnore has always been matched.
+		AutoIgnore:             false,                      //vendor and bin may hold code that should cause a rebuild.
+		WatchVendor:            false,                      //vendored code rarely changes and can be huge.
+		IgnoreEditorTempFiles:  true,                       //editors save via temp files which would cause extra rebuilds.
+		SkipCommentOnlyChanges: false,                      //line numbers in stack traces would be wrong.
+		SkipExcludedFiles:      true,                       //excluded files don't change the binary.
+		SkipUnrelatedModules:   false,                      //most repos have a single module.
+		MaxWatchDepth:          20,                         //deeper than any reasonable repo.
+		MaxWatchedDirectories:  20000,                      //more than most repos, less than most home directories.
+		FollowSymlinks:         false,                      //symlinks usually point outside of the repo.
+		WatchReplacedModules:   true,                       //a local replace is usually being edited.
+		WatchBackend:           WatchBackendFSNotify,       //works on every OS.
+		RescanIntervalSeconds:  0,                          //watcher doesn't miss events in most environments.
+		WatchGit:               false,                      //not every project uses git.
+		SkipInitialRun:         false,                      //most users want the binary running right away.
+		SkipInitialBuild:       false,                      //the binary may be stale.
 		BuildDelayMilliseconds: 100,                        //100 is "instant" enough but helps catch CTRL+S being hit rapidly.
+		BulkChangeEvents:       20,                         //more files than a human saves at once.
+		BulkDelayMilliseconds:  500,                        //long enough for a formatter to move between files.
+		PausedChanges:          PausedChangesQueue,         //the binary should include the latest changes once resumed.
+		ConfirmRebuildFiles:    0,                          //most users expect every change to rebuild.
+		BuildTimeoutSeconds:    0,                          //big projects can take minutes to build from scratch.
 		BuildName:              "fresher-build",            //could really be anything.
 		BuildLogFilename:       "fresher-build-errors.log", //could really be anything.
+		BuildLogMode:           BuildLogModeOverwrite,      //only the latest errors are usually useful.
+		BuildLogMaxSizeKB:      1024,                       //only used when appending.
+		BuildErrorFormat:       BuildErrorFormatText,       //easiest for a human to read.
+		OnMissingModules:       OnMissingModulesHint,       //go.mod shouldn't be modified unless asked to.
+		BinaryGrowthWarnKB:     0,                          //binaries grow as code is added, user must opt in.
+		OnBuildErrorOpenEditor: "",                         //disabled by default since this is editor specific.
+		NotifyOnBuildResult:    false,                      //most users watch the terminal.
+		BellOnError:            false,                      //most users watch the terminal.
+		BellOnRecovery:         false,                      //only used when BellOnError is true.
+		BellCommand:            "",                         //terminal bell is used when blank.
 		GoTags:                 "",                         //will be overriden by flag to fresher.
 		GoLdflags:              "-s -w",                    //probably unnecessary since the built binary shouldn't be used for production or distribution.
 		GoTrimpath:             true,                       //probably unnecessary since the built binary shouldn't be used for production or distribution.
+		GoBuildVCS:             GoBuildVCSAuto,             //stamping fails without git.
+		GoCache:                "",                         //sharing Go's cache makes the first build fast.
+		GoTmpDir:               "",                         //the OS's temp directory is usually fine.
+		BuildConfigs:           []BuildConfig{},            //most apps are only built one way.
+		BuildConfig:            "",                         //the first build config is used.
+		GoMod:                  "",                         //Go's default, or GOFLAGS, is what users expect.
+		DownloadModules:        false,                      //modules are usually already downloaded.
+		BuildVerbose:           false,                      //very noisy, only needed when diagnosing slow builds.
+		FormatCheck:            FormatCheckOff,             //most editors format on save.
+		BuildParallelism:       0,                          //Go's default is fastest when nothing else needs the CPU.
+		BuildLowPriority:       false,                      //builds are fastest at normal priority.
+		MinimumGoVersion:       "",                         //go.mod's go directive is usually enough.
+		WarmBuildCache:         WarmBuildCacheOff,          //uses CPU at start up that most users won't want.
+		CrossCheckTargets:      []string{},                 //most binaries only target one platform.
 		Verbose:                false,                      //will be overriden by flag to fresher.
+		LogLevel:               LogLevelInfo,               //will be overriden by flag to fresher.
+		MetricsAddress:         "",                         //disabled by default, most users won't need this.
+		LogFile:                "",                         //disabled by default, terminal output is usually enough.
+		LogFileMaxSizeMB:       10,                         //only used when LogFile is set.
+		LogFileIncludeOutput:   false,                      //only used when LogFile is set.
+		RunLogsToKeep:          0,                          //disabled by default, terminal output is usually enough.
+		CheckForUpdates:        false,                      //don't make network requests unless asked to.
+		OutputPrefix:           "",                         //binary's output is not modified by default.
+		OutputTimestamps:       false,                      //most apps log with their own timestamps.
+		OutputLineBuffered:     false,                      //prompts without a newline would be delayed.
+		RunHeader:              "",                         //a line of dashes.
+		ClearScreenOnRebuild:   false,                      //output from previous runs is usually useful.
+		HighlightPanics:        false,                      //binary's output is not modified by default.
+		OnPanicOpenEditor:      false,                      //only used when HighlightPanics is set.
+		ControlAddress:         "",                         //disabled by default, most users won't need this.
+		StaticAddress:          "",                         //disabled by default, most users won't need this.
+		StaticDirectory:        "static",                   //common name for a directory of assets.
+		DebugAddress:           "",                         //disabled by default, only needed when diagnosing fresher.
+		EventStream:            "",                         //will be overriden by flag to fresher.
+		TriggerFile:            "",                         //disabled by default, most users won't need this.
+		PIDFile:                "",                         //disabled by default since stopping processes on start up can be surprising.
+		StatusFile:             "",                         //disabled by default, most users won't need this.
+		OnAlreadyRunning:       OnAlreadyRunningRefuse,     //safest, user has to decide which fresher to stop.
+		KillPortConflicts:      []int{},                    //user must opt in to killing processes.
+		RunDelayMilliseconds:   0,                          //most binaries release resources when they exit.
+		WaitForPorts:           []int{},                    //user must list the ports the binary uses.
+		AutoRestart:            false,                      //a crashing binary usually needs a code change.
+		RestartPolicy:          RestartPolicyAlways,        //the binary should include the latest changes.
+		CrashLoopSeconds:       5,                          //only used when AutoRestart is true.
+		CrashLoopLimit:         3,                          //only used when AutoRestart is true.
+		MaxOpenFiles:           10000,                      //enough for most repos.
+		WASMAddress:            "",                         //binary is run on the host by default.
+		DockerService:          "",                         //binary is run on the host by default.
+		DockerComposeFile:      "",                         //docker compose finds the file by default.
+		DockerBinaryPath:       "",                         //only used when DockerService is set.
+		Generators:             []Generator{},              //code generation is project specific.
+		AssetCommands:          []AssetCommand{},           //asset bundling is project specific.
+		VulnCheck:              false,                      //govulncheck must be installed.
+		BenchmarkPackage:       "",                         //benchmarks are slow and package specific.
+		BenchmarkPattern:       ".",                        //every benchmark in the package.
+		BenchmarkCount:         1,                          //fastest, raise for a more reliable comparison.
+
+		Hooks: Hooks{
+			PreWatch:         []string{},
+			PreBuild:         []string{},
+			PostBuildSuccess: []string{},
+			PostBuildFailure: []string{},
+			PreRun:           []string{},
+			PostStop:         []string{},
+		},
+
+		Colors: Colors{
+			Disabled: false,
+			Events:   "blue",
+			Warnings: "yellow",
+			Errors:   "red",
+
+			Output:       "cyan",
+			OutputErrors: "magenta",
+		},
 
 		usingBuiltInDefaults: true,
 	}
@@ -206,7 +1186,84 @@ func CreateDefaultConfig() (err error) {
 //
 // If a config file is not found at the given path, a warning is shown and the
 // built-in default config is used instead. Use -init to create a default config file.
-func Read(path string, print bool) (err error) {
+//
+// The profile is the name of the profile, from the config file's Profiles, to apply.
+// If blank, the DefaultProfile is applied if it exists.
+func Read(path, profile string, print bool) (err error) {
+	loaded, err := load(path, profile, print)
+	if err != nil {
+		return
+	}
+
+	//Save the config to this package for use elsewhere in the app.
+	store(loaded, func() (*File, error) {
+		return load(path, profile, false)
+	})
+
+	//Print the config, if needed, as it was sanitized and validated. This logs out
+	//the config as it was understood by the app and some changes may have been made
+	//(for example, user provided an invalid value for a field and a default value
+	//was used instead). This also prints out the config if it was created or if the
+	//config path was blank and a default config was used instead.
+	//Always exit at this point since printing config is just for diagnostics.
+	if print {
+		log.Println("***PRINTING CONFIG AS UNDERSTOOD BY FRESHER***")
+		Data().print(path)
+		os.Exit(0)
+		return
+	}
+
+	return
+}
+
+// Defaults returns the built-in default config. This is useful for building a config
+// to provide to Use() without a config file.
+func Defaults() File {
+	return *newDefaultConfig()
+}
+
+// UseDefaults saves the built-in default config for use elsewhere in the app, the
+// same as Read() does when no config file exists. This is useful for tests and when
+// using fresher as a library.
+func UseDefaults() {
+	store(newDefaultConfig(), func() (*File, error) {
+		return newDefaultConfig(), nil
+	})
+}
+
+// Use validates the provided config and saves it for use elsewhere in the app, in
+// place of reading a config file. Start with Defaults() and change the fields needed.
+// The config is sanitized and validated the same as a config file.
+func Use(cfg File) (err error) {
+	validated := func() (*File, error) {
+		c := cfg
+		err := c.validate()
+		return &c, err
+	}
+
+	loaded, err := validated()
+	if err != nil {
+		return
+	}
+
+	store(loaded, validated)
+	return
+}
+
+// store saves the config and how to get it again on Reload(). Anything updated on the
+// previously saved config is discarded.
+func store(cfg *File, from func() (*File, error)) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	source = from
+	updates = nil
+	parsedConfig.Store(cfg)
+}
+
+// load reads, parses, and validates the config file at the provided path, or returns
+// the default config if there is no config file. See Read().
+func load(path, profile string, print bool) (loaded *File, err error) {
 	// log.Println("Provided config file path:", path, print)
 
 	//Handle path to config file.
@@ -220,8 +1277,7 @@ func Read(path string, print bool) (err error) {
 		//Get default config.
 		cfg := newDefaultConfig()
 
-		//Save the config to this package for use elsewhere in the app.
-		parsedConfig = *cfg
+		loaded = cfg
 
 	} else if _, err = os.Stat(path); os.IsNotExist(err) {
 		// log.Printf("WARNING! (config) Config file not found at %s, use -init flag to create it, using built-in defaults.", path)
@@ -229,26 +1285,29 @@ func Read(path string, print bool) (err error) {
 		//Get default config.
 		cfg := newDefaultConfig()
 
-		//Save the config to this package for use elsewhere in the app.
-		parsedConfig = *cfg
+		loaded = cfg
 
 		//Unset the file not found error.
 		err = nil
 
+		//A profile can't be applied without a config file to read it from.
+		if profile != "" {
+			return nil, fmt.Errorf("config: profile %s not found, config file %s does not exist", profile, path)
+		}
+
 	} else {
 		// log.Println("Using config from file:", path)
 
-		//Read the file at the path.
-		f, innerErr := os.ReadFile(path)
+		//Read and parse the file at the path, and any config files it extends.
+		cfg, innerErr := readFile(path, map[string]bool{})
 		if innerErr != nil {
-			return innerErr
+			return nil, innerErr
 		}
 
-		//Parse the file as yaml.
-		var cfg File
-		innerErr = yaml.Unmarshal(f, &cfg)
+		//Apply the profile's fields over the fields parsed from the file.
+		innerErr = cfg.applyProfile(profile)
 		if innerErr != nil {
-			return innerErr
+			return nil, innerErr
 		}
 
 		//Print the config, if needed, as it was parsed from the file. This logs
@@ -261,26 +1320,102 @@ func Read(path string, print bool) (err error) {
 		//Validate & sanitize the data since it could have been edited by a human.
 		innerErr = cfg.validate()
 		if innerErr != nil {
-			return innerErr
+			return nil, innerErr
 		}
 
-		//Save the config to this package for use elsewhere in the app.
-		parsedConfig = cfg
+		loaded = &cfg
 	}
 
-	//Print the config, if needed, as it was sanitized and validated. This logs out
-	//the config as it was understood by the app and some changes may have been made
-	//(for example, user provided an invalid value for a field and a default value
-	//was used instead). This also prints out the config if it was created or if the
-	//config path was blank and a default config was used instead.
-	//Always exit at this point since printing config is just for diagnostics.
-	if print {
-		log.Println("***PRINTING CONFIG AS UNDERSTOOD BY FRESHER***")
-		parsedConfig.print(path)
-		os.Exit(0)
+	return
+}
+
+// readFile reads and parses the config file at the given path. If the config file
+// sets Extends, the extended config file is read first and this config file's fields
+// are parsed on top of it. Therefore, fields not set in this config file are
+// inherited, lists are replaced, and maps (i.e.: Env) are merged.
+//
+// seen is the absolute path to each config file already read, used to detect config
+// files that extend each other in a loop.
+func readFile(path string, seen map[string]bool) (cfg File, err error) {
+	pathAbs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	if seen[pathAbs] {
+		return cfg, fmt.Errorf("config: %s extends itself, check Extends in each config file", path)
+	}
+	seen[pathAbs] = true
+
+	f, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	//Get the config file this file extends, if any, before parsing the whole file
+	//since the extended file must be parsed first.
+	var extends struct {
+		Extends string `yaml:"Extends"`
+	}
+	err = yaml.Unmarshal(f, &extends)
+	if err != nil {
+		return
+	}
+
+	if base := strings.TrimSpace(extends.Extends); base != "" {
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(path), base)
+		}
+
+		cfg, err = readFile(base, seen)
+		if err != nil {
+			return cfg, fmt.Errorf("config: could not read %s extended by %s %w", base, path, err)
+		}
+	}
+
+	err = yaml.Unmarshal(f, &cfg)
+	return
+}
+
+// applyProfile overrides the config's fields with the fields set in a profile. If
+// name is blank, the DefaultProfile is applied if it exists. An error is returned if
+// the named profile does not exist.
+//
+// The profile's fields are applied by parsing the profile as if it were a config file
+// on top of the already parsed config file. Therefore, fields not set in the profile
+// are left as-is, lists are replaced, and maps (i.e.: Env) are merged.
+func (conf *File) applyProfile(name string) (err error) {
+	if name == "" {
+		name = DefaultProfile
+		if _, ok := conf.Profiles[name]; !ok {
+			return
+		}
+	}
+
+	fields, ok := conf.Profiles[name]
+	if !ok {
+		available := []string{}
+		for p := range conf.Profiles {
+			available = append(available, p)
+		}
+		sort.Strings(available)
+
+		return fmt.Errorf("config: profile %s not found, available profiles are %s", name, available)
+	}
+
+	y, err := yaml.Marshal(fields)
+	if err != nil {
 		return
 	}
 
+	//Profiles can't define other profiles.
+	profiles := conf.Profiles
+	err = yaml.Unmarshal(y, conf)
+	conf.Profiles = profiles
+	if err != nil {
+		return fmt.Errorf("config: invalid profile %s %w", name, err)
+	}
+
+	log.Printf("(config) Using profile %s.", name)
 	return
 }
 
@@ -387,12 +1522,108 @@ func (conf *File) validate() (err error) {
 	}
 	conf.NoRebuildExtensions = validNoRebuildExtensionss
 
+	//Remove invalid and duplicate embedded and template paths.
+	conf.EmbeddedPaths = conf.validatePaths("EmbeddedPaths", conf.EmbeddedPaths)
+	conf.TemplateCheckPaths = conf.validatePaths("TemplateCheckPaths", conf.TemplateCheckPaths)
+	conf.TemplateCheckCommand = strings.TrimSpace(conf.TemplateCheckCommand)
+
+	//Remove invalid and duplicate event ops.
+	validEventOps := []string{}
+	for _, op := range conf.EventOps {
+		op = strings.ToLower(strings.TrimSpace(op))
+
+		if !isStringInSlice(defaults.EventOps, op) {
+			log.Printf("WARNING! (config) EventOps %s invalid, ignored. Valid values are %s.", op, defaults.EventOps)
+			continue
+		}
+
+		if isStringInSlice(validEventOps, op) {
+			log.Println("WARNING! (config) EventOps duplicate " + op + ", ignored.")
+			continue
+		}
+
+		validEventOps = append(validEventOps, op)
+	}
+	conf.EventOps = validEventOps
+
+	if len(conf.EventOps) == 0 {
+		conf.EventOps = defaults.EventOps
+		log.Printf("WARNING! (config) EventOps not provided, defaulting to %s.", conf.EventOps)
+	}
+
+	//Make sure each generator and asset command has a valid pattern and a command,
+	//and that files matching the pattern are watched.
+	validGenerators := []Generator{}
+	for _, g := range conf.Generators {
+		g.Pattern, g.Command = strings.TrimSpace(g.Pattern), strings.TrimSpace(g.Command)
+		if conf.validatePatternCommand("Generators", g.Pattern, g.Command) {
+			g.Pattern = filepath.FromSlash(g.Pattern)
+			validGenerators = append(validGenerators, g)
+		}
+	}
+	conf.Generators = validGenerators
+
+	validAssetCommands := []AssetCommand{}
+	for _, a := range conf.AssetCommands {
+		a.Pattern, a.Command = strings.TrimSpace(a.Pattern), strings.TrimSpace(a.Command)
+		if conf.validatePatternCommand("AssetCommands", a.Pattern, a.Command) {
+			a.Pattern = filepath.FromSlash(a.Pattern)
+			validAssetCommands = append(validAssetCommands, a)
+		}
+	}
+	conf.AssetCommands = validAssetCommands
+
+	//Make sure each run command has a command and a name to show before its output.
+	validRunCommands := []RunCommand{}
+	for _, r := range conf.RunCommands {
+		r.Name, r.Command = strings.TrimSpace(r.Name), strings.TrimSpace(r.Command)
+		if r.Command == "" {
+			log.Printf("WARNING! (config) RunCommands %q command missing, ignored.", r.Name)
+			continue
+		}
+		if r.Name == "" {
+			r.Name = strings.TrimSuffix(filepath.Base(strings.Fields(r.Command)[0]), ".exe")
+		}
+
+		r.ReadyAddress = strings.TrimSpace(r.ReadyAddress)
+		if r.ReadyAddress != "" && r.Once {
+			log.Printf("WARNING! (config) RunCommands %s ReadyAddress not used with Once, ignored.", r.Name)
+			r.ReadyAddress = ""
+		}
+		if _, _, err := net.SplitHostPort(r.ReadyAddress); r.ReadyAddress != "" && err != nil {
+			log.Printf("WARNING! (config) RunCommands %s ReadyAddress %s invalid, ignored.", r.Name, r.ReadyAddress)
+			r.ReadyAddress = ""
+		}
+
+		validRunCommands = append(validRunCommands, r)
+	}
+	conf.RunCommands = validRunCommands
+
 	//Remove duplicate directories to ignore and sanitize each.
 	validDirectoriesToIgnore := []string{}
 	for _, dir := range conf.DirectoriesToIgnore {
-		//Sanitize.
+		//Sanitize. A leading "!" negates the entry and is handled separately so
+		//the path can be cleaned.
 		dir = strings.TrimSpace(dir)
-		dir = filepath.Clean(dir)
+		negate := strings.HasPrefix(dir, "!")
+		dir = filepath.Clean(filepath.FromSlash(strings.TrimPrefix(dir, "!")))
+
+		//Absolute paths are converted to be relative to the WorkingDir since the
+		//paths being matched against are relative to the WorkingDir.
+		if filepath.IsAbs(dir) {
+			dir = conf.relativeToWorkingDir(dir)
+		}
+
+		//Make sure wildcards are valid.
+		_, err := filepath.Match(dir, "")
+		if err != nil {
+			log.Println("WARNING! (config) DirectoriesToIgnore " + dir + " invalid pattern, ignored.")
+			continue
+		}
+
+		if negate {
+			dir = "!" + dir
+		}
 
 		//We don't check if a directory actually exists. Who cares if a directory
 		//listed in the config file doesn't actually exists in the repo.
@@ -405,6 +1636,23 @@ func (conf *File) validate() (err error) {
 		validDirectoriesToIgnore = append(validDirectoriesToIgnore, dir)
 	}
 	conf.DirectoriesToIgnore = validDirectoriesToIgnore
+	conf.IgnoreMatchMode = validateOption("IgnoreMatchMode", conf.IgnoreMatchMode, defaults.IgnoreMatchMode, []string{IgnoreMatchAnchored, IgnoreMatchAnywhere})
+
+	conf.WatchBackend = validateOption("WatchBackend", conf.WatchBackend, defaults.WatchBackend, []string{WatchBackendFSNotify, WatchBackendNative})
+
+	if conf.MaxWatchDepth < 0 {
+		conf.MaxWatchDepth = defaults.MaxWatchDepth
+		log.Printf("WARNING! (config) MaxWatchDepth must be 0 or greater, defaulting to %d.", conf.MaxWatchDepth)
+	}
+	if conf.MaxWatchedDirectories < 0 {
+		conf.MaxWatchedDirectories = defaults.MaxWatchedDirectories
+		log.Printf("WARNING! (config) MaxWatchedDirectories must be 0 or greater, defaulting to %d.", conf.MaxWatchedDirectories)
+	}
+
+	if conf.RescanIntervalSeconds < 0 {
+		conf.RescanIntervalSeconds = defaults.RescanIntervalSeconds
+		log.Printf("WARNING! (config) RescanIntervalSeconds must be 0 or greater, defaulting to %d.", conf.RescanIntervalSeconds)
+	}
 
 	//Validate some other stuff.
 	if conf.BuildDelayMilliseconds < 0 {
@@ -412,6 +1660,25 @@ func (conf *File) validate() (err error) {
 		log.Printf("WARNING! (config) BuildDelayMilliseconds must be greater then 0, defaulting to %d.", conf.BuildDelayMilliseconds)
 	}
 
+	if conf.BuildTimeoutSeconds < 0 {
+		conf.BuildTimeoutSeconds = defaults.BuildTimeoutSeconds
+		log.Printf("WARNING! (config) BuildTimeoutSeconds must be 0 or greater, defaulting to %d.", conf.BuildTimeoutSeconds)
+	}
+
+	if conf.BulkDelayMilliseconds < 0 {
+		conf.BulkDelayMilliseconds = defaults.BulkDelayMilliseconds
+		log.Printf("WARNING! (config) BulkDelayMilliseconds must be 0 or greater, defaulting to %d.", conf.BulkDelayMilliseconds)
+	}
+	if conf.BulkDelayMilliseconds > 0 && conf.BulkChangeEvents < 1 {
+		conf.BulkChangeEvents = defaults.BulkChangeEvents
+		log.Printf("WARNING! (config) BulkChangeEvents must be greater than 0, defaulting to %d.", conf.BulkChangeEvents)
+	}
+
+	if conf.ConfirmRebuildFiles < 0 {
+		conf.ConfirmRebuildFiles = defaults.ConfirmRebuildFiles
+		log.Printf("WARNING! (config) ConfirmRebuildFiles must be 0 or greater, defaulting to %d.", conf.ConfirmRebuildFiles)
+	}
+
 	if strings.TrimSpace(conf.BuildName) == "" {
 		conf.BuildName = defaults.BuildName
 		log.Println("WARNING! (config) BuildName was not given, defaulting to " + conf.BuildName + ".")
@@ -422,41 +1689,469 @@ func (conf *File) validate() (err error) {
 		log.Println("WARNING! (config) BuildLogFilename was not given, defaulting to " + conf.BuildLogFilename + ".")
 	}
 
-	return
-}
-
-// print logs out the configuration file. This is used for diagnostic purposes.
-// This will show all fields from the File struct, even fields that the provided
-// config file omitted (except nonPublishedFields).
-func (conf File) print(path string) {
-	//Don't print paths when the default built-in config is in use. There aren't any
-	//paths since config wasn't read from file!
-	if !conf.usingBuiltInDefaults {
-		//Full path to the config file, so if file is in same directory as the
-		//executable and -config flag was not provided we still get the complete path.
-		pathAbs, _ := filepath.Abs(path)
+	conf.BuildLogMode = validateOption("BuildLogMode", conf.BuildLogMode, defaults.BuildLogMode, []string{BuildLogModeOverwrite, BuildLogModeAppend})
+	conf.BuildErrorFormat = validateOption("BuildErrorFormat", conf.BuildErrorFormat, defaults.BuildErrorFormat, []string{BuildErrorFormatText, BuildErrorFormatJSON})
+	conf.OnMissingModules = validateOption("OnMissingModules", conf.OnMissingModules, defaults.OnMissingModules, []string{OnMissingModulesHint, OnMissingModulesTidy})
+	conf.GoCache = strings.TrimSpace(conf.GoCache)
+	conf.GoTmpDir = strings.TrimSpace(conf.GoTmpDir)
+
+	//Make sure each build config has a unique name to switch to it by, and that the
+	//build config to use exists.
+	validBuildConfigs := []BuildConfig{}
+	buildConfigNames := map[string]bool{}
+	for _, b := range conf.BuildConfigs {
+		b.Name = strings.TrimSpace(b.Name)
+		b.GoTags, b.GoLdflags = strings.TrimSpace(b.GoTags), strings.TrimSpace(b.GoLdflags)
+		if b.Name == "" {
+			log.Printf("WARNING! (config) BuildConfigs name missing, ignored.")
+			continue
+		}
+		if buildConfigNames[b.Name] {
+			log.Printf("WARNING! (config) BuildConfigs %s given more than once, ignored.", b.Name)
+			continue
+		}
 
-		log.Println("Path to config file (flag):", path)
-		log.Println("Path to config file (absolute):", pathAbs)
+		buildConfigNames[b.Name] = true
+		validBuildConfigs = append(validBuildConfigs, b)
 	}
+	conf.BuildConfigs = validBuildConfigs
 
-	//Print out config file stuff (actually from parsed struct).
-	x := reflect.ValueOf(&conf).Elem()
-	typeOf := x.Type()
-	for i := 0; i < x.NumField(); i++ {
-		if typeOf.Field(i).IsExported() {
-			fieldName := typeOf.Field(i).Name
-			value := x.Field(i).Interface()
-			log.Println(fieldName+":", value)
+	conf.BuildConfig = strings.TrimSpace(conf.BuildConfig)
+	if len(conf.BuildConfigs) > 0 && conf.FindBuildConfig(conf.BuildConfig) == nil {
+		if conf.BuildConfig != "" {
+			log.Printf("WARNING! (config) BuildConfig %s not found in BuildConfigs, defaulting to %s.", conf.BuildConfig, conf.BuildConfigs[0].Name)
 		}
+		conf.BuildConfig = conf.BuildConfigs[0].Name
 	}
-}
+	conf.GoBuildVCS = validateOption("GoBuildVCS", conf.GoBuildVCS, defaults.GoBuildVCS, []string{GoBuildVCSAuto, GoBuildVCSTrue, GoBuildVCSFalse})
+	conf.GoMod = validateOption("GoMod", conf.GoMod, defaults.GoMod, []string{GoModMod, GoModReadonly, GoModVendor})
+	conf.PausedChanges = validateOption("PausedChanges", conf.PausedChanges, defaults.PausedChanges, []string{PausedChangesQueue, PausedChangesDrop})
+	conf.RestartPolicy = validateOption("RestartPolicy", conf.RestartPolicy, defaults.RestartPolicy, []string{RestartPolicyAlways, RestartPolicyOnSuccess, RestartPolicyManual})
+	conf.WarmBuildCache = validateOption("WarmBuildCache", conf.WarmBuildCache, defaults.WarmBuildCache, []string{WarmBuildCacheOff, WarmBuildCacheBuild, WarmBuildCacheVet})
+	conf.FormatCheck = validateOption("FormatCheck", conf.FormatCheck, defaults.FormatCheck, []string{FormatCheckOff, FormatCheckGofmt, FormatCheckGoimports})
+
+	//Remove invalid and duplicate cross check targets.
+	validCrossCheckTargets := []string{}
+	for _, target := range conf.CrossCheckTargets {
+		target = strings.ToLower(strings.TrimSpace(target))
+
+		if !crossCheckTargetPattern.MatchString(target) {
+			log.Printf("WARNING! (config) CrossCheckTargets %s invalid, ignored. Targets must be GOOS or GOOS/GOARCH, i.e. windows or linux/arm64.", target)
+			continue
+		}
 
-// Data returns the package level saved config. This is used in other packages to
-// access the parsed config file.
-func Data() *File {
-	return &parsedConfig
-}
+		if isStringInSlice(validCrossCheckTargets, target) {
+			log.Println("WARNING! (config) CrossCheckTargets duplicate " + target + ", ignored.")
+			continue
+		}
+
+		validCrossCheckTargets = append(validCrossCheckTargets, target)
+	}
+	conf.CrossCheckTargets = validCrossCheckTargets
+
+	conf.BenchmarkPackage = strings.TrimSpace(conf.BenchmarkPackage)
+	conf.BenchmarkPattern = strings.TrimSpace(conf.BenchmarkPattern)
+	if conf.BenchmarkPattern == "" {
+		conf.BenchmarkPattern = defaults.BenchmarkPattern
+	}
+	if _, err := regexp.Compile(conf.BenchmarkPattern); err != nil {
+		log.Printf("WARNING! (config) BenchmarkPattern %s invalid, defaulting to %s. %s", conf.BenchmarkPattern, defaults.BenchmarkPattern, err)
+		conf.BenchmarkPattern = defaults.BenchmarkPattern
+	}
+	if conf.BenchmarkCount < 1 {
+		conf.BenchmarkCount = defaults.BenchmarkCount
+	}
+
+	if conf.BuildParallelism < 0 {
+		conf.BuildParallelism = defaults.BuildParallelism
+		log.Printf("WARNING! (config) BuildParallelism must be 0 or greater, defaulting to %d.", conf.BuildParallelism)
+	}
+
+	conf.MinimumGoVersion = strings.TrimPrefix(strings.TrimSpace(conf.MinimumGoVersion), "go")
+	if conf.MinimumGoVersion != "" && !goVersionPattern.MatchString(conf.MinimumGoVersion) {
+		log.Printf("WARNING! (config) MinimumGoVersion %s invalid, ignored. Use a version like 1.21.", conf.MinimumGoVersion)
+		conf.MinimumGoVersion = defaults.MinimumGoVersion
+	}
+
+	if conf.RunLogsToKeep < 0 {
+		conf.RunLogsToKeep = defaults.RunLogsToKeep
+		log.Printf("WARNING! (config) RunLogsToKeep must be 0 or greater, defaulting to %d.", conf.RunLogsToKeep)
+	}
+
+	if conf.BinaryGrowthWarnKB < 0 {
+		conf.BinaryGrowthWarnKB = defaults.BinaryGrowthWarnKB
+		log.Printf("WARNING! (config) BinaryGrowthWarnKB must be 0 or greater, defaulting to %d.", conf.BinaryGrowthWarnKB)
+	}
+
+	conf.OnBuildErrorOpenEditor = strings.TrimSpace(conf.OnBuildErrorOpenEditor)
+	conf.BellCommand = strings.TrimSpace(conf.BellCommand)
+
+	//Make sure each color is one we know how to output.
+	conf.Colors.Events = validateOption("Colors.Events", conf.Colors.Events, defaults.Colors.Events, validColors)
+	conf.Colors.Warnings = validateOption("Colors.Warnings", conf.Colors.Warnings, defaults.Colors.Warnings, validColors)
+	conf.Colors.Errors = validateOption("Colors.Errors", conf.Colors.Errors, defaults.Colors.Errors, validColors)
+	conf.Colors.Output = validateOption("Colors.Output", conf.Colors.Output, defaults.Colors.Output, validColors)
+	conf.Colors.OutputErrors = validateOption("Colors.OutputErrors", conf.Colors.OutputErrors, defaults.Colors.OutputErrors, validColors)
+
+	conf.OutputPrefix = strings.TrimSpace(conf.OutputPrefix)
+	conf.RunHeader = strings.TrimSpace(conf.RunHeader)
+	if conf.RunHeader != "" {
+		_, err := template.New("RunHeader").Parse(conf.RunHeader)
+		if err != nil {
+			log.Printf("WARNING! (config) RunHeader is not a valid template, %s, defaulting to a line of dashes.", err)
+			conf.RunHeader = defaults.RunHeader
+		}
+	}
+
+	//Make sure each output filter is a valid regular expression. Invalid patterns
+	//are ignored rather than returning an error since filtering is just cosmetic.
+	conf.OutputFilters.Include = validateRegexps("OutputFilters.Include", conf.OutputFilters.Include)
+	conf.OutputFilters.Exclude = validateRegexps("OutputFilters.Exclude", conf.OutputFilters.Exclude)
+
+	if conf.BuildLogMaxSizeKB == 0 {
+		//Older config files don't have this field, use the default so the log isn't
+		//left to grow endlessly.
+		conf.BuildLogMaxSizeKB = defaults.BuildLogMaxSizeKB
+	} else if conf.BuildLogMaxSizeKB < -1 {
+		conf.BuildLogMaxSizeKB = defaults.BuildLogMaxSizeKB
+		log.Printf("WARNING! (config) BuildLogMaxSizeKB must be greater than 0, or -1 to never remove old failures, defaulting to %d.", conf.BuildLogMaxSizeKB)
+	}
+
+	//Handle the deprecated Verbose field for older config files that don't have a
+	//LogLevel set.
+	if strings.TrimSpace(conf.LogLevel) == "" && conf.Verbose {
+		conf.LogLevel = LogLevelDebug
+	}
+	conf.LogLevel = validateOption("LogLevel", conf.LogLevel, defaults.LogLevel, logLevels)
+
+	conf.MetricsAddress = strings.TrimSpace(conf.MetricsAddress)
+	conf.ControlAddress = strings.TrimSpace(conf.ControlAddress)
+	conf.StaticAddress = strings.TrimSpace(conf.StaticAddress)
+	conf.StaticDirectory = strings.TrimSpace(conf.StaticDirectory)
+	if conf.StaticDirectory == "" {
+		conf.StaticDirectory = defaults.StaticDirectory
+	}
+	conf.DebugAddress = strings.TrimSpace(conf.DebugAddress)
+	if addr := conf.ControlAddress; addr != "" && !strings.HasPrefix(addr, "unix:") && !isLoopbackAddress(addr) {
+		log.Printf("WARNING! (config) ControlAddress %s is not a localhost address, other computers can rebuild and restart the binary.", addr)
+	}
+	if conf.DebugAddress != "" && !isLoopbackAddress(conf.DebugAddress) {
+		log.Printf("WARNING! (config) DebugAddress %s is not a localhost address, fresher's profiling data can be read from other computers.", conf.DebugAddress)
+	}
+	conf.EventStream = strings.TrimSpace(conf.EventStream)
+	conf.TriggerFile = filepath.FromSlash(strings.TrimSpace(conf.TriggerFile))
+	conf.PIDFile = strings.TrimSpace(conf.PIDFile)
+	conf.StatusFile = strings.TrimSpace(conf.StatusFile)
+
+	//Older config files don't have these fields, use the defaults without warning.
+	if conf.CrashLoopSeconds == 0 {
+		conf.CrashLoopSeconds = defaults.CrashLoopSeconds
+	} else if conf.CrashLoopSeconds < 0 {
+		conf.CrashLoopSeconds = defaults.CrashLoopSeconds
+		log.Printf("WARNING! (config) CrashLoopSeconds must be greater than 0, defaulting to %d.", conf.CrashLoopSeconds)
+	}
+	if conf.CrashLoopLimit == 0 {
+		conf.CrashLoopLimit = defaults.CrashLoopLimit
+	} else if conf.CrashLoopLimit < 0 {
+		conf.CrashLoopLimit = defaults.CrashLoopLimit
+		log.Printf("WARNING! (config) CrashLoopLimit must be greater than 0, defaulting to %d.", conf.CrashLoopLimit)
+	}
+
+	if conf.MaxOpenFiles == 0 {
+		//Older config files don't have this field, use the default so the limit is
+		//still raised as it always was.
+		conf.MaxOpenFiles = defaults.MaxOpenFiles
+	} else if conf.MaxOpenFiles < -1 {
+		conf.MaxOpenFiles = defaults.MaxOpenFiles
+		log.Printf("WARNING! (config) MaxOpenFiles must be greater than 0, or -1 to leave the limit as-is, defaulting to %d.", conf.MaxOpenFiles)
+	}
+
+	validKillPortConflicts := []int{}
+	for _, port := range conf.KillPortConflicts {
+		if port < 1 || port > 65535 {
+			log.Printf("WARNING! (config) KillPortConflicts port %d invalid, ignored.", port)
+			continue
+		}
+
+		validKillPortConflicts = append(validKillPortConflicts, port)
+	}
+	conf.KillPortConflicts = validKillPortConflicts
+
+	if conf.RunDelayMilliseconds < 0 {
+		conf.RunDelayMilliseconds = defaults.RunDelayMilliseconds
+		log.Printf("WARNING! (config) RunDelayMilliseconds must be 0 or greater, defaulting to %d.", conf.RunDelayMilliseconds)
+	}
+
+	validWaitForPorts := []int{}
+	for _, port := range conf.WaitForPorts {
+		if port < 1 || port > 65535 {
+			log.Printf("WARNING! (config) WaitForPorts port %d invalid, ignored.", port)
+			continue
+		}
+
+		validWaitForPorts = append(validWaitForPorts, port)
+	}
+	conf.WaitForPorts = validWaitForPorts
+	conf.OnAlreadyRunning = validateOption("OnAlreadyRunning", conf.OnAlreadyRunning, defaults.OnAlreadyRunning, []string{OnAlreadyRunningRefuse, OnAlreadyRunningTakeover})
+
+	conf.Hooks.PreWatch = validateCommands("Hooks.PreWatch", conf.Hooks.PreWatch)
+	conf.Hooks.PreBuild = validateCommands("Hooks.PreBuild", conf.Hooks.PreBuild)
+	conf.Hooks.PostBuildSuccess = validateCommands("Hooks.PostBuildSuccess", conf.Hooks.PostBuildSuccess)
+	conf.Hooks.PostBuildFailure = validateCommands("Hooks.PostBuildFailure", conf.Hooks.PostBuildFailure)
+	conf.Hooks.PreRun = validateCommands("Hooks.PreRun", conf.Hooks.PreRun)
+	conf.Hooks.PostStop = validateCommands("Hooks.PostStop", conf.Hooks.PostStop)
+
+	conf.WASMAddress = strings.TrimSpace(conf.WASMAddress)
+	conf.DockerService = strings.TrimSpace(conf.DockerService)
+	if conf.WASMAddress != "" && conf.DockerService != "" {
+		log.Println("WARNING! (config) WASMAddress and DockerService cannot both be used, DockerService ignored.")
+		conf.DockerService = ""
+	}
+
+	conf.DockerComposeFile = strings.TrimSpace(conf.DockerComposeFile)
+	conf.DockerBinaryPath = strings.TrimSpace(conf.DockerBinaryPath)
+	if conf.DockerBinaryPath != "" && !strings.HasPrefix(conf.DockerBinaryPath, "/") {
+		log.Println("WARNING! (config) DockerBinaryPath " + conf.DockerBinaryPath + " must be an absolute path in the container, leading slash added.")
+		conf.DockerBinaryPath = "/" + conf.DockerBinaryPath
+	}
+
+	conf.LogFile = strings.TrimSpace(conf.LogFile)
+	if conf.LogFileMaxSizeMB == 0 {
+		//Older config files don't have this field, use the default so the log file
+		//is still rotated.
+		conf.LogFileMaxSizeMB = defaults.LogFileMaxSizeMB
+	} else if conf.LogFileMaxSizeMB < -1 {
+		conf.LogFileMaxSizeMB = defaults.LogFileMaxSizeMB
+		log.Printf("WARNING! (config) LogFileMaxSizeMB must be greater than 0, or -1 to never rotate, defaulting to %d.", conf.LogFileMaxSizeMB)
+	}
+
+	return
+}
+
+// isLoopbackAddress returns true if the host of a host:port address is localhost or
+// a loopback IP. A blank host means all interfaces, so false is returned.
+func isLoopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validatePaths returns the paths, or patterns, for the named field with blank,
+// invalid, and duplicate paths removed. Paths are cleaned and absolute paths are
+// converted to be relative to the WorkingDir since the paths being matched against
+// are relative to the WorkingDir.
+func (conf *File) validatePaths(name string, paths []string) []string {
+	valid := []string{}
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		path = filepath.Clean(filepath.FromSlash(path))
+
+		if filepath.IsAbs(path) {
+			path = conf.relativeToWorkingDir(path)
+		}
+
+		_, err := filepath.Match(path, "")
+		if err != nil {
+			log.Println("WARNING! (config) " + name + " " + path + " invalid pattern, ignored.")
+			continue
+		}
+
+		if isStringInSlice(valid, path) {
+			log.Println("WARNING! (config) " + name + " duplicate " + path + ", ignored.")
+			continue
+		}
+
+		valid = append(valid, path)
+	}
+
+	return valid
+}
+
+// validateOption sanitizes a field that must be one of a list of valid values and
+// returns the default value if the field is blank or invalid. A blank value is not
+// warned about since many of these fields were added after the config file format
+// was first created and older config files won't have them.
+func validateOption(name, value, defaultValue string, valid []string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return defaultValue
+	}
+
+	if !isStringInSlice(valid, value) {
+		log.Printf("WARNING! (config) %s %s invalid, defaulting to %s. Valid values are %s.", name, value, defaultValue, valid)
+		return defaultValue
+	}
+
+	return value
+}
+
+// validatePatternCommand returns true if a Generator or AssetCommand has a valid
+// pattern and a command. The pattern's extension is added to ExtensionsToWatch, if
+// needed, so that changes to matching files aren't ignored.
+func (conf *File) validatePatternCommand(name, pattern, command string) bool {
+	if pattern == "" || command == "" {
+		log.Printf("WARNING! (config) %s pattern %q or command %q missing, ignored.", name, pattern, command)
+		return false
+	}
+
+	_, err := filepath.Match(pattern, "")
+	if err != nil {
+		log.Printf("WARNING! (config) %s pattern %s invalid, ignored.", name, pattern)
+		return false
+	}
+
+	extension := filepath.Ext(pattern)
+	if extension != "" && !strings.ContainsAny(extension, "*?[\\") && !isStringInSlice(conf.ExtensionsToWatch, extension) {
+		log.Printf("WARNING! (config) %s extension %s not included in ExtensionsToWatch, added.", name, extension)
+		conf.ExtensionsToWatch = append(conf.ExtensionsToWatch, extension)
+	}
+
+	return true
+}
+
+// validateCommands returns the commands with whitespace trimmed. Blank commands are
+// removed.
+func validateCommands(name string, commands []string) (valid []string) {
+	valid = []string{}
+	for _, command := range commands {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			log.Printf("WARNING! (config) %s blank command, ignored.", name)
+			continue
+		}
+
+		valid = append(valid, command)
+	}
+
+	return
+}
+
+// validateRegexps returns the patterns that compile as regular expressions. Blank
+// and invalid patterns are removed.
+func validateRegexps(name string, patterns []string) (valid []string) {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+
+		_, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("WARNING! (config) %s pattern %s invalid, ignored. %s", name, pattern, err)
+			continue
+		}
+
+		valid = append(valid, pattern)
+	}
+
+	return
+}
+
+// print logs out the configuration file. This is used for diagnostic purposes.
+// This will show all fields from the File struct, even fields that the provided
+// config file omitted (except nonPublishedFields).
+func (conf File) print(path string) {
+	//Don't print paths when the default built-in config is in use. There aren't any
+	//paths since config wasn't read from file!
+	if !conf.usingBuiltInDefaults {
+		//Full path to the config file, so if file is in same directory as the
+		//executable and -config flag was not provided we still get the complete path.
+		pathAbs, _ := filepath.Abs(path)
+
+		log.Println("Path to config file (flag):", path)
+		log.Println("Path to config file (absolute):", pathAbs)
+	}
+
+	//Print out config file stuff (actually from parsed struct).
+	x := reflect.ValueOf(&conf).Elem()
+	typeOf := x.Type()
+	for i := 0; i < x.NumField(); i++ {
+		if typeOf.Field(i).IsExported() {
+			fieldName := typeOf.Field(i).Name
+			value := x.Field(i).Interface()
+			log.Println(fieldName+":", value)
+		}
+	}
+}
+
+// Data returns the package level saved config. This is used in other packages to
+// access the parsed config file.
+//
+// The returned config is a snapshot shared with every other caller, it must not be
+// modified, including its slice and map fields, since that would race with other
+// goroutines reading it. Use Update() to change the config instead. Callers that need
+// a few fields to agree with each other should call Data() once and use the returned
+// config, or be passed it, since a Reload() can occur between calls.
+func Data() *File {
+	if cfg := parsedConfig.Load(); cfg != nil {
+		return cfg
+	}
+
+	//Config hasn't been read yet, i.e. in tests.
+	return &File{}
+}
+
+// Update changes the saved config by calling fn with a copy of the config and saving
+// the copy. This is used to override fields with flags provided to fresher. fn is
+// called again, in order with other updates, each time the config is reloaded so that
+// the overrides are kept.
+//
+// fn must replace, not modify, slice and map fields since they are shared with the
+// previous config.
+func Update(fn func(cfg *File) error) (err error) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	cfg := *Data()
+	err = fn(&cfg)
+	if err != nil {
+		return
+	}
+
+	updates = append(updates, fn)
+	parsedConfig.Store(&cfg)
+	return
+}
+
+// Reload rereads the config file, from the same path and with the same profile as
+// Read(), reapplies Update()s, and saves the new config. The saved config is only
+// replaced if the config file is valid. If the config was provided with Use() or
+// UseDefaults(), the same config is used again.
+//
+// Fields used when fresher starts, i.e. WorkingDir or DirectoriesToIgnore, don't
+// take effect until fresher is restarted.
+func Reload() (err error) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+
+	if source == nil {
+		return errors.New("config: config not read yet, call Read() first")
+	}
+
+	cfg, err := source()
+	if err != nil {
+		return
+	}
+
+	for _, fn := range updates {
+		err = fn(cfg)
+		if err != nil {
+			return
+		}
+	}
+
+	parsedConfig.Store(cfg)
+	return
+}
 
 // IsTempDir returns true if the given path represents the same directory as TempDir.
 // We use absolute paths here since we want to be certain if the path given matches
@@ -478,11 +2173,285 @@ func (conf *File) IsTempDir(path string) (yes bool, err error) {
 	return false, nil
 }
 
-// IsDirectoryToIgnore returns true if the given path is in the DirectoriesToIgnore.
+// FindBuildConfig returns the build config, from BuildConfigs, with the given name or
+// nil if no build config has the name.
+func (conf *File) FindBuildConfig(name string) *BuildConfig {
+	for i := range conf.BuildConfigs {
+		if conf.BuildConfigs[i].Name == name {
+			return &conf.BuildConfigs[i]
+		}
+	}
+
+	return nil
+}
+
+// BuildConfigNames returns the name of each build config, in the order given in
+// BuildConfigs.
+func (conf *File) BuildConfigNames() (names []string) {
+	for _, b := range conf.BuildConfigs {
+		names = append(names, b.Name)
+	}
+
+	return
+}
+
+// BuildTags returns the tags to build with, the GoTags of the build config in use, see
+// BuildConfig, or GoTags if BuildConfigs aren't used.
+func (conf *File) BuildTags() string {
+	if b := conf.FindBuildConfig(conf.BuildConfig); b != nil {
+		return b.GoTags
+	}
+
+	return conf.GoTags
+}
+
+// BuildLdflags returns the ldflags to build with, the GoLdflags of the build config in
+// use, see BuildConfig, if set, otherwise GoLdflags.
+func (conf *File) BuildLdflags() string {
+	if b := conf.FindBuildConfig(conf.BuildConfig); b != nil && b.GoLdflags != "" {
+		return b.GoLdflags
+	}
+
+	return conf.GoLdflags
+}
+
+// BuildConfigEnv returns the Env of the build config in use, see BuildConfig, or nil
+// if BuildConfigs aren't used.
+func (conf *File) BuildConfigEnv() map[string]string {
+	if b := conf.FindBuildConfig(conf.BuildConfig); b != nil {
+		return b.Env
+	}
+
+	return nil
+}
+
+// IsGoCacheDir returns true if the given path is the GoCache or GoTmpDir. These are
+// never watched since Go writes to them constantly while building.
+func (conf *File) IsGoCacheDir(path string) bool {
+	pathAbs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	for _, dir := range []string{conf.GoCache, conf.GoTmpDir} {
+		if dir == "" {
+			continue
+		}
+		if dirAbs, err := filepath.Abs(dir); err == nil && dirAbs == pathAbs {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsDirectoryToIgnore returns true if the given path is in, or is a subdirectory of,
+// a directory in DirectoriesToIgnore. The path can be relative to the WorkingDir or
+// absolute.
+//
+// Paths are compared by whole path components, not as strings, so that ignoring
+// "tmp" does not also ignore "tmpl" or "tmp-data". See IgnoreMatchMode for how
+// entries are matched at different depths.
+//
+// Entries can use wildcards: "*" matches within a single path component and "**"
+// matches any number of path components, i.e.: "web/*/static" or "web/static/**".
+// Entries starting with "!" un-ignore a directory, i.e.: "!web/static/critical",
+// to keep watching a subdirectory of an ignored directory. Like .gitignore, the
+// last entry that matches the path wins.
 func (conf *File) IsDirectoryToIgnore(path string) bool {
-	//not using isStringInSlice because of extra HasPrefix.
+	pathParts := splitPath(conf.relativeToWorkingDir(path))
+
+	ignored := false
 	for _, d := range conf.DirectoriesToIgnore {
-		if strings.HasPrefix(path, d) {
+		negate := strings.HasPrefix(d, "!")
+		pattern := conf.ignorePattern(d)
+		if len(pattern) == 0 {
+			continue
+		}
+
+		if matchesPathPrefix(pattern, pathParts) {
+			ignored = !negate
+		}
+	}
+
+	return ignored
+}
+
+// HasUnignoredSubdirectories returns true if a "!" entry in DirectoriesToIgnore could
+// un-ignore a subdirectory of the given path. This is used to determine if an ignored
+// directory must still be walked to find the subdirectories that are watched.
+func (conf *File) HasUnignoredSubdirectories(path string) bool {
+	pathParts := splitPath(conf.relativeToWorkingDir(path))
+
+	for _, d := range conf.DirectoriesToIgnore {
+		if !strings.HasPrefix(d, "!") {
+			continue
+		}
+
+		if couldMatchBelow(conf.ignorePattern(d), pathParts) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ignorePattern returns the path components of an entry in DirectoriesToIgnore, with
+// any leading "!" removed. When matching anywhere, "**" is added to the start so that
+// the pattern can match at any depth.
+func (conf *File) ignorePattern(entry string) []string {
+	pattern := splitPath(strings.TrimPrefix(entry, "!"))
+	if len(pattern) > 0 && conf.IgnoreMatchMode == IgnoreMatchAnywhere {
+		pattern = append([]string{"**"}, pattern...)
+	}
+
+	return pattern
+}
+
+// relativeToWorkingDir returns the path relative to the WorkingDir. Relative paths
+// are assumed to already be relative to the directory fresher is running in, the
+// same as WorkingDir. If the path cannot be made relative, it is returned cleaned.
+func (conf *File) relativeToWorkingDir(path string) string {
+	workingDir := conf.WorkingDir
+
+	//filepath.Rel requires both paths to be absolute or both relative.
+	if filepath.IsAbs(path) != filepath.IsAbs(workingDir) {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return filepath.Clean(path)
+		}
+		absWorkingDir, err := filepath.Abs(workingDir)
+		if err != nil {
+			return filepath.Clean(path)
+		}
+		path, workingDir = absPath, absWorkingDir
+	}
+
+	rel, err := filepath.Rel(workingDir, path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	return rel
+}
+
+// splitPath splits a path into its components. "." results in no components.
+func splitPath(path string) []string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == "." {
+		return nil
+	}
+
+	return strings.Split(path, "/")
+}
+
+// matchesPathPrefix returns true if the pattern matches the path components or any of
+// the path's parent directories. This way, ignoring a directory also ignores all of
+// its subdirectories.
+func matchesPathPrefix(pattern, parts []string) bool {
+	for n := len(parts); n > 0; n-- {
+		if matchParts(pattern, parts[:n]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchParts returns true if the pattern matches all of the path components. Each
+// pattern component is matched with filepath.Match, except "**" which matches any
+// number of components, including none.
+func matchParts(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchParts(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	matched, err := filepath.Match(pattern[0], parts[0])
+	if err != nil || !matched {
+		return false
+	}
+
+	return matchParts(pattern[1:], parts[1:])
+}
+
+// couldMatchBelow returns true if the pattern could match a subdirectory of the path.
+// This is true if the path matches the start of the pattern and the pattern has more
+// components, or if a "**" is reached since that could match anything below.
+func couldMatchBelow(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return false
+	}
+
+	if pattern[0] == "**" {
+		return true
+	}
+
+	if len(parts) == 0 {
+		return true
+	}
+
+	matched, err := filepath.Match(pattern[0], parts[0])
+	if err != nil || !matched {
+		return false
+	}
+
+	return couldMatchBelow(pattern[1:], parts[1:])
+}
+
+// ignoreMarkers caches if each directory, by path, contains an IgnoreMarkerFile so
+// that the file isn't checked for each file change event.
+var ignoreMarkers sync.Map
+
+// IsAutoIgnoredDirectory returns true if the given path is a directory that is
+// ignored when AutoIgnore is enabled: either the directory's name is in
+// autoIgnoreDirectories or the directory contains an IgnoreMarkerFile. The
+// WorkingDir itself is never ignored. The vendor directory isn't ignored by name if
+// WatchVendor is enabled.
+func (conf *File) IsAutoIgnoredDirectory(path string) bool {
+	if !conf.AutoIgnore || filepath.Clean(path) == filepath.Clean(conf.WorkingDir) {
+		return false
+	}
+
+	name := filepath.Base(path)
+	if isStringInSlice(autoIgnoreDirectories, name) && !(conf.WatchVendor && name == "vendor") {
+		return true
+	}
+
+	//The marker file is only checked once per directory, fresher must be restarted
+	//after adding or removing a marker file.
+	path = filepath.Clean(path)
+	if marked, ok := ignoreMarkers.Load(path); ok {
+		return marked.(bool)
+	}
+
+	_, err := os.Stat(filepath.Join(path, IgnoreMarkerFile))
+	ignoreMarkers.Store(path, err == nil)
+	return err == nil
+}
+
+// IsEditorTempFile returns true if the given path is a temporary file created by an
+// editor, see editorTempFilePatterns, and IgnoreEditorTempFiles is enabled.
+func (conf *File) IsEditorTempFile(path string) bool {
+	if !conf.IgnoreEditorTempFiles {
+		return false
+	}
+
+	name := filepath.Base(path)
+	for _, pattern := range editorTempFilePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
 			return true
 		}
 	}
@@ -496,6 +2465,89 @@ func (conf *File) IsRebuildExtension(extension string) bool {
 	return !isStringInSlice(conf.NoRebuildExtensions, extension)
 }
 
+// IsEmbeddedPath returns true if the given path matches, or is within a directory
+// matching, one of the EmbeddedPaths. The path can be relative to the WorkingDir or
+// absolute.
+func (conf *File) IsEmbeddedPath(path string) bool {
+	if len(conf.EmbeddedPaths) == 0 {
+		return false
+	}
+
+	for _, p := range conf.EmbeddedPaths {
+		if conf.MatchesPath(p, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsTemplateCheckPath returns true if the given path matches, or is within a directory
+// matching, one of the TemplateCheckPaths. The path can be relative to the WorkingDir
+// or absolute.
+func (conf *File) IsTemplateCheckPath(path string) bool {
+	for _, p := range conf.TemplateCheckPaths {
+		if conf.MatchesPath(p, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchesPath returns true if the given path matches the pattern, or is within a
+// directory matching the pattern. The pattern is relative to the WorkingDir and is
+// matched the same as EmbeddedPaths. The path can be relative to the WorkingDir or
+// absolute.
+func (conf *File) MatchesPath(pattern, path string) bool {
+	return matchesPathPrefix(splitPath(pattern), splitPath(conf.relativeToWorkingDir(path)))
+}
+
+// IsEventOpToWatch returns true if file change events with the given operation, one
+// of the EventOp constants, should trigger a rebuild or rerun.
+func (conf *File) IsEventOpToWatch(op string) bool {
+	return isStringInSlice(conf.EventOps, op)
+}
+
+// GeneratorsFor returns the generators whose Pattern matches the given path. The path
+// can be relative to the WorkingDir or absolute.
+func (conf *File) GeneratorsFor(path string) (matches []Generator) {
+	rel := conf.relativeToWorkingDir(path)
+	for _, g := range conf.Generators {
+		if matchFilePattern(g.Pattern, rel) {
+			matches = append(matches, g)
+		}
+	}
+
+	return
+}
+
+// AssetCommandsFor returns the asset commands whose Pattern matches the given path.
+// The path can be relative to the WorkingDir or absolute.
+func (conf *File) AssetCommandsFor(path string) (matches []AssetCommand) {
+	rel := conf.relativeToWorkingDir(path)
+	for _, a := range conf.AssetCommands {
+		if matchFilePattern(a.Pattern, rel) {
+			matches = append(matches, a)
+		}
+	}
+
+	return
+}
+
+// matchFilePattern returns true if a Generator or AssetCommand pattern matches a path
+// relative to the WorkingDir. A pattern without a path separator is matched against
+// the file's name, otherwise against the whole path.
+func matchFilePattern(pattern, rel string) bool {
+	name := filepath.Base(rel)
+	if strings.ContainsRune(pattern, filepath.Separator) {
+		name = rel
+	}
+
+	matched, _ := filepath.Match(pattern, name)
+	return matched
+}
+
 // IsExtensionToWatch returns true if the given path contains an extension we should
 // watch for changes.
 func (conf *File) IsExtensionToWatch(extension string) bool {
@@ -507,6 +2559,67 @@ func (conf *File) UsingDefaults() bool {
 	return conf.usingBuiltInDefaults
 }
 
+// FindService returns the Go module root directory for a service's "main" package
+// directory, and the EntryPoint to build the service from the module root. This is
+// used when fresher is run from a monorepo's root with a path to a service, i.e.:
+// `fresher ./cmd/api`.
+//
+// The module root is the closest directory, from the service's directory up to the
+// current directory, with a go.mod file. If none is found, the current directory is
+// used since the module root may be above the current directory.
+func FindService(service string) (moduleRoot, entryPoint string, err error) {
+	fi, err := os.Stat(service)
+	if err != nil {
+		return
+	}
+	if !fi.IsDir() {
+		return "", "", fmt.Errorf("config: service %s is not a directory", service)
+	}
+
+	serviceAbs, err := filepath.Abs(service)
+	if err != nil {
+		return
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+
+	moduleRoot = cwd
+	for dir := serviceAbs; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			moduleRoot = dir
+			break
+		}
+
+		//Stop at the current directory or the root of the filesystem.
+		if dir == cwd || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	//`go build` requires a leading "./" to treat the EntryPoint as a directory rather
+	//than an import path.
+	rel, err := filepath.Rel(moduleRoot, serviceAbs)
+	if err != nil {
+		return
+	}
+	entryPoint = "."
+	if rel != "." {
+		entryPoint = "./" + filepath.ToSlash(rel)
+	}
+
+	return
+}
+
+// OverrideEntryPoint sets the EntryPoint field to e, and WorkingDir to the current
+// directory. This is used when a service path is given as an argument to fresher, see
+// FindService(), after changing to the service's module root directory.
+func (conf *File) OverrideEntryPoint(e string) {
+	conf.EntryPoint = e
+	conf.WorkingDir = "."
+}
+
 // OverrideTags sets the Tags field to t. This is used when the -tags flag was provided
 // and overrides the value stored in parsedConfig's Tags field. This is useful for
 // changing tags without having to edit the config file (if it exists) each time.
@@ -514,13 +2627,81 @@ func (conf *File) OverrideTags(t string) {
 	conf.GoTags = strings.TrimSpace(t)
 }
 
+// OverrideWarmBuildCache sets the WarmBuildCache field to w. This is used when the
+// -skip-warm flag was provided to skip warming the build cache without editing the
+// config file.
+func (conf *File) OverrideWarmBuildCache(w string) {
+	conf.WarmBuildCache = w
+}
+
 // OverrideVerbose sets the Verbose field to v. This is used when the -verbose
 // flag was provided and overrides the value stored in teh parsedConfig's Verbose
 // field. This is useful for when (1) you aren't using a config file (i.e.: the default
 // running method of fresher), or (2) you have a config file and just want some extra
 // logging on a case-by-case basis.
+//
+// Since Verbose is deprecated, this also sets LogLevel to "debug".
 func (conf *File) OverrideVerbose(v bool) {
 	conf.Verbose = v
+	if v {
+		conf.LogLevel = LogLevelDebug
+	}
+}
+
+// OverrideEventStream sets the EventStream field to e. This is used when the
+// -event-stream flag was provided, typically by an editor plugin starting fresher,
+// so that the plugin doesn't need to modify the config file.
+func (conf *File) OverrideEventStream(e string) {
+	conf.EventStream = strings.TrimSpace(e)
+}
+
+// OverrideWatchGit sets the WatchGit field to w. This is used when the -watch-git
+// flag was provided to handle branch switches without editing the config file.
+func (conf *File) OverrideWatchGit(w bool) {
+	conf.WatchGit = w
+}
+
+// OverrideLogLevel sets the LogLevel field to l. This is used when the -log-level
+// flag was provided and overrides the value stored in the parsedConfig's LogLevel
+// field. An error is returned if l is not a valid log level.
+func (conf *File) OverrideLogLevel(l string) error {
+	l = strings.ToLower(strings.TrimSpace(l))
+	if !isStringInSlice(logLevels, l) {
+		return fmt.Errorf("config: invalid log level %s, must be one of %s", l, logLevels)
+	}
+
+	conf.LogLevel = l
+	return nil
+}
+
+// IsLogLevel returns true if logging at the given level should be output based on
+// the LogLevel field. For example, if LogLevel is "debug", IsLogLevel returns true
+// for "error", "warn", "info", and "debug", but false for "trace".
+func (conf *File) IsLogLevel(level string) bool {
+	return logLevelIndex(level) <= logLevelIndex(conf.LogLevel)
+}
+
+// logLevelIndex returns the position of the level in logLevels. Unknown levels are
+// treated as "info", the default.
+func logLevelIndex(level string) int {
+	for i, l := range logLevels {
+		if l == level {
+			return i
+		}
+	}
+
+	return logLevelIndex(LogLevelInfo)
+}
+
+// UseColors returns true if fresher's logging should be colorized. Colors are not
+// used if they are disabled in the config or if the NO_COLOR environmental variable
+// is set to any non-blank value.
+func (conf *File) UseColors() bool {
+	if conf.Colors.Disabled {
+		return false
+	}
+
+	return os.Getenv("NO_COLOR") == ""
 }
 
 // isStringInSlice checks if needle is in haystack.
@@ -1,6 +1,15 @@
 package config
 
-import "testing"
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
 
 func TestValidate(t *testing.T) {
 	//Get a default config to work from.
@@ -54,86 +63,688 @@ func TestValidate(t *testing.T) {
 		return
 	}
 
-	cfg.BuildDelayMilliseconds = -100
-	err = cfg.validate()
+	cfg.EventOps = []string{"WRITE", "write", "chmod"}
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(cfg.EventOps) != 1 || cfg.EventOps[0] != EventOpWrite {
+		t.Fatal("Invalid and duplicate EventOps should have been removed.", cfg.EventOps)
+		return
+	}
+
+	cfg.EventOps = []string{}
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(cfg.EventOps) != len(newDefaultConfig().EventOps) {
+		t.Fatal("Default value not set for EventOps.", cfg.EventOps, newDefaultConfig().EventOps)
+		return
+	}
+
+	cfg.CrossCheckTargets = []string{"Windows", "windows", "linux/arm64", "GOOS=js"}
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(cfg.CrossCheckTargets) != 2 || cfg.CrossCheckTargets[0] != "windows" || cfg.CrossCheckTargets[1] != "linux/arm64" {
+		t.Fatal("Invalid and duplicate CrossCheckTargets should have been removed.", cfg.CrossCheckTargets)
+		return
+	}
+
+	cfg.Hooks.PreBuild = []string{" echo pre-build ", ""}
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(cfg.Hooks.PreBuild) != 1 || cfg.Hooks.PreBuild[0] != "echo pre-build" {
+		t.Fatal("Blank hook commands should have been removed.", cfg.Hooks.PreBuild)
+		return
+	}
+
+	cfg.BuildDelayMilliseconds = -100
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildDelayMilliseconds != newDefaultConfig().BuildDelayMilliseconds {
+		t.Fatal("Default value not set for BuildDelayMilliseconds.", cfg.BuildDelayMilliseconds, newDefaultConfig().BuildDelayMilliseconds)
+		return
+	}
+
+	cfg.RunDelayMilliseconds = -100
+	cfg.WaitForPorts = []int{8080, 0, 70000}
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.RunDelayMilliseconds != newDefaultConfig().RunDelayMilliseconds {
+		t.Fatal("Default value not set for RunDelayMilliseconds.", cfg.RunDelayMilliseconds, newDefaultConfig().RunDelayMilliseconds)
+		return
+	}
+	if len(cfg.WaitForPorts) != 1 || cfg.WaitForPorts[0] != 8080 {
+		t.Fatal("Invalid WaitForPorts should have been removed.", cfg.WaitForPorts)
+		return
+	}
+
+	cfg.BuildName = ""
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildName != newDefaultConfig().BuildName {
+		t.Fatal("Default value not set for BuildName.")
+		return
+	}
+
+	cfg.BuildLogFilename = ""
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildLogFilename != newDefaultConfig().BuildLogFilename {
+		t.Fatal("Default value not set for BuildLogFilename.")
+		return
+	}
+
+	cfg.BuildLogMode = "sideways"
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildLogMode != newDefaultConfig().BuildLogMode {
+		t.Fatal("Default value not set for BuildLogMode.")
+		return
+	}
+
+	cfg.BuildErrorFormat = "xml"
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildErrorFormat != newDefaultConfig().BuildErrorFormat {
+		t.Fatal("Default value not set for BuildErrorFormat.")
+		return
+	}
+
+	cfg.BuildParallelism = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildParallelism != newDefaultConfig().BuildParallelism {
+		t.Fatal("Default value not set for BuildParallelism.")
+		return
+	}
+
+	cfg.BinaryGrowthWarnKB = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BinaryGrowthWarnKB != newDefaultConfig().BinaryGrowthWarnKB {
+		t.Fatal("Default value not set for BinaryGrowthWarnKB.")
+		return
+	}
+
+	cfg.FormatCheck = "prettier"
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.FormatCheck != newDefaultConfig().FormatCheck {
+		t.Fatal("Default value not set for FormatCheck.")
+		return
+	}
+
+	cfg.MinimumGoVersion = "latest"
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.MinimumGoVersion != newDefaultConfig().MinimumGoVersion {
+		t.Fatal("Default value not set for MinimumGoVersion.")
+		return
+	}
+
+	cfg.MinimumGoVersion = " go1.21 "
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.MinimumGoVersion != "1.21" {
+		t.Fatal("MinimumGoVersion not sanitized.", cfg.MinimumGoVersion)
+		return
+	}
+
+	cfg.WarmBuildCache = "test"
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.WarmBuildCache != newDefaultConfig().WarmBuildCache {
+		t.Fatal("Default value not set for WarmBuildCache.")
+		return
+	}
+
+	cfg.Colors.Errors = "orange"
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.Colors.Errors != newDefaultConfig().Colors.Errors {
+		t.Fatal("Default value not set for Colors.Errors.")
+		return
+	}
+
+	cfg.OutputFilters.Exclude = []string{"GET /", "(unclosed"}
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(cfg.OutputFilters.Exclude) != 1 {
+		t.Fatal("Invalid OutputFilters.Exclude pattern not removed.", cfg.OutputFilters.Exclude)
+		return
+	}
+
+	cfg.LogLevel = ""
+	cfg.Verbose = true
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.LogLevel != LogLevelDebug {
+		t.Fatal("LogLevel not set to debug for deprecated Verbose.", cfg.LogLevel)
+		return
+	}
+	cfg.Verbose = false
+
+	//Missing from older config files, the default should be used without a
+	//warning.
+	cfg.CrashLoopLimit = 0
+	cfg.CrashLoopSeconds = 0
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	err = cfg.validate()
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.CrashLoopLimit != newDefaultConfig().CrashLoopLimit || cfg.CrashLoopSeconds != newDefaultConfig().CrashLoopSeconds {
+		t.Fatal("Default value not set for CrashLoopLimit or CrashLoopSeconds.")
+		return
+	}
+	if strings.Contains(logged.String(), "CrashLoop") {
+		t.Fatal("Missing CrashLoop fields should not cause a warning.", logged.String())
+		return
+	}
+
+	cfg.CrashLoopLimit = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.CrashLoopLimit != newDefaultConfig().CrashLoopLimit {
+		t.Fatal("Default value not set for CrashLoopLimit.")
+		return
+	}
+
+	cfg.CrashLoopSeconds = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.CrashLoopSeconds != newDefaultConfig().CrashLoopSeconds {
+		t.Fatal("Default value not set for CrashLoopSeconds.")
+		return
+	}
+
+	cfg.MaxWatchedDirectories = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.MaxWatchedDirectories != newDefaultConfig().MaxWatchedDirectories {
+		t.Fatal("Default value not set for MaxWatchedDirectories.")
+		return
+	}
+
+	cfg.MaxWatchDepth = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.MaxWatchDepth != newDefaultConfig().MaxWatchDepth {
+		t.Fatal("Default value not set for MaxWatchDepth.")
+		return
+	}
+
+	cfg.RunHeader = "--- {{.Build ---"
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.RunHeader != newDefaultConfig().RunHeader {
+		t.Fatal("Default value not set for invalid RunHeader.")
+		return
+	}
+
+	cfg.RunHeader = "--- #{{.Build}} ---"
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.RunHeader != "--- #{{.Build}} ---" {
+		t.Fatal("Valid RunHeader should be kept.", cfg.RunHeader)
+		return
+	}
+
+	cfg.RescanIntervalSeconds = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.RescanIntervalSeconds != newDefaultConfig().RescanIntervalSeconds {
+		t.Fatal("Default value not set for RescanIntervalSeconds.")
+		return
+	}
+
+	cfg.MaxOpenFiles = -2
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.MaxOpenFiles != newDefaultConfig().MaxOpenFiles {
+		t.Fatal("Default value not set for MaxOpenFiles.")
+		return
+	}
+
+	//Missing from older config files.
+	cfg.MaxOpenFiles = 0
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.MaxOpenFiles != newDefaultConfig().MaxOpenFiles {
+		t.Fatal("Default value not set for missing MaxOpenFiles.")
+		return
+	}
+
+	cfg.MaxOpenFiles = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.MaxOpenFiles != -1 {
+		t.Fatal("-1 should be kept to leave the open files limit as-is.", cfg.MaxOpenFiles)
+		return
+	}
+
+	cfg.BuildLogMaxSizeKB = -2
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildLogMaxSizeKB != newDefaultConfig().BuildLogMaxSizeKB {
+		t.Fatal("Default value not set for BuildLogMaxSizeKB.")
+		return
+	}
+
+	//Missing from older config files.
+	cfg.BuildLogMaxSizeKB = 0
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildLogMaxSizeKB != newDefaultConfig().BuildLogMaxSizeKB {
+		t.Fatal("Default value not set for missing BuildLogMaxSizeKB.")
+		return
+	}
+
+	cfg.BuildLogMaxSizeKB = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildLogMaxSizeKB != -1 {
+		t.Fatal("-1 should be kept to never remove old failures.", cfg.BuildLogMaxSizeKB)
+		return
+	}
+
+	cfg.LogFileMaxSizeMB = -2
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.LogFileMaxSizeMB != newDefaultConfig().LogFileMaxSizeMB {
+		t.Fatal("Default value not set for LogFileMaxSizeMB.")
+		return
+	}
+
+	//Missing from older config files.
+	cfg.LogFileMaxSizeMB = 0
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.LogFileMaxSizeMB != newDefaultConfig().LogFileMaxSizeMB {
+		t.Fatal("Default value not set for missing LogFileMaxSizeMB.")
+		return
+	}
+
+	cfg.LogFileMaxSizeMB = -1
+	err = cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.LogFileMaxSizeMB != -1 {
+		t.Fatal("-1 should be kept to never rotate LogFile.", cfg.LogFileMaxSizeMB)
+		return
+	}
+}
+
+func TestIsTempDir(t *testing.T) {
+	//Get a default config to work from.
+	cfg := newDefaultConfig()
+
+	//Test with known matching dir.
+	p := "./tmp"
+	yes, err := cfg.IsTempDir(p)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !yes {
+		t.Fatal("TempDir path matches, IsTempDir should have returned true.")
+		return
+	}
+
+	//Test with known non-matching dir.
+	p = "./temporary"
+	yes, err = cfg.IsTempDir(p)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if yes {
+		t.Fatal("TempDir path does not match, IsTempDir should have returned false.")
+		return
+	}
+}
+
+func TestIsDirectoryToIgnore(t *testing.T) {
+	//Get a default config to work from.
+	cfg := newDefaultConfig()
+
+	//Test with known matching dir.
+	p := "node_modules"
+	yes := cfg.IsDirectoryToIgnore(p)
+	if !yes {
+		t.Fatal("DirectoryiesToIgnore path matches, IsDirectoryToIgnore should have returned true.")
+		return
+	}
+
+	//Test with known non-matching dir.
+	p = "some_source_dir"
+	yes = cfg.IsDirectoryToIgnore(p)
+	if yes {
+		t.Fatal("DirectoryiesToIgnore path matches, IsDirectoryToIgnore should have returned true.")
+		return
+	}
+
+	//Test with subdirectory of a matching dir.
+	p = filepath.Join("node_modules", "pkg")
+	if !cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Subdirectory of ignored directory, IsDirectoryToIgnore should have returned true.")
+		return
+	}
+
+	//Test with dir that only shares a prefix, "tmp" should not match "tmpl".
+	p = "tmpl"
+	if cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Directory only shares a prefix, IsDirectoryToIgnore should have returned false.")
+		return
+	}
+
+	//Test with absolute path.
+	abs, err := filepath.Abs("node_modules")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !cfg.IsDirectoryToIgnore(abs) {
+		t.Fatal("Absolute path to ignored directory, IsDirectoryToIgnore should have returned true.")
+		return
+	}
+
+	//Test with nested dir, only matches when matching anywhere.
+	p = filepath.Join("web", "node_modules")
+	if cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Nested directory with anchored matching, IsDirectoryToIgnore should have returned false.")
+		return
+	}
+	cfg.IgnoreMatchMode = IgnoreMatchAnywhere
+	if !cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Nested directory with anywhere matching, IsDirectoryToIgnore should have returned true.")
+		return
+	}
+}
+
+func TestIsDirectoryToIgnoreGlob(t *testing.T) {
+	//Get a default config to work from.
+	cfg := newDefaultConfig()
+	cfg.DirectoriesToIgnore = []string{"web/static/**", "!web/static/critical", "build/*/cache"}
+	err := cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Test with directory matching a "**" entry, and the directory itself.
+	p := filepath.Join("web", "static", "img")
+	if !cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Directory matches \"**\" entry, IsDirectoryToIgnore should have returned true.")
+		return
+	}
+	p = filepath.Join("web", "static")
+	if !cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Directory matches \"**\" entry, IsDirectoryToIgnore should have returned true.")
+		return
+	}
+
+	//Test with negated directory, and a subdirectory of it.
+	p = filepath.Join("web", "static", "critical")
+	if cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Directory is negated, IsDirectoryToIgnore should have returned false.")
+		return
+	}
+	p = filepath.Join("web", "static", "critical", "css")
+	if cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Subdirectory of negated directory, IsDirectoryToIgnore should have returned false.")
+		return
+	}
+
+	//Test with "*" entry.
+	p = filepath.Join("build", "linux", "cache")
+	if !cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Directory matches \"*\" entry, IsDirectoryToIgnore should have returned true.")
+		return
+	}
+	p = filepath.Join("build", "linux", "out")
+	if cfg.IsDirectoryToIgnore(p) {
+		t.Fatal("Directory does not match \"*\" entry, IsDirectoryToIgnore should have returned false.")
+		return
+	}
+
+	//Test that ignored directories leading to a negated directory are walked.
+	if !cfg.HasUnignoredSubdirectories(filepath.Join("web", "static")) {
+		t.Fatal("HasUnignoredSubdirectories should have returned true for parent of negated directory.")
+		return
+	}
+	if cfg.HasUnignoredSubdirectories(filepath.Join("web", "static", "img")) {
+		t.Fatal("HasUnignoredSubdirectories should have returned false for sibling of negated directory.")
+		return
+	}
+}
+
+func TestIsEditorTempFile(t *testing.T) {
+	//Get a default config to work from.
+	cfg := newDefaultConfig()
+
+	//Test with known editor temp files.
+	for _, p := range []string{".main.go.swp", "4913", "main.go~", "main.go___jb_tmp___", ".#main.go"} {
+		if !cfg.IsEditorTempFile(filepath.Join("web", p)) {
+			t.Fatal("IsEditorTempFile should have returned true for " + p)
+			return
+		}
+	}
+
+	//Test with a source file.
+	if cfg.IsEditorTempFile("main.go") {
+		t.Fatal("IsEditorTempFile should have returned false for main.go")
+		return
+	}
+
+	//Test with heuristics disabled.
+	cfg.IgnoreEditorTempFiles = false
+	if cfg.IsEditorTempFile("main.go~") {
+		t.Fatal("IsEditorTempFile should have returned false when IgnoreEditorTempFiles is disabled.")
+		return
+	}
+}
+
+func TestGeneratorsFor(t *testing.T) {
+	//Get a default config to work from.
+	cfg := newDefaultConfig()
+	cfg.Generators = []Generator{
+		{Pattern: "*.proto", Command: "buf generate"},
+		{Pattern: "db/*.sql", Command: "sqlc generate"},
+	}
+	err := cfg.validate()
 	if err != nil {
 		t.Fatal(err)
 		return
 	}
-	if cfg.BuildDelayMilliseconds != newDefaultConfig().BuildDelayMilliseconds {
-		t.Fatal("Default value not set for BuildDelayMilliseconds.", cfg.BuildDelayMilliseconds, newDefaultConfig().BuildDelayMilliseconds)
+
+	//Test that the pattern's extension is watched.
+	if !cfg.IsExtensionToWatch(".proto") {
+		t.Fatal("Generators extension should have been added to ExtensionsToWatch.")
 		return
 	}
 
-	cfg.BuildName = ""
+	//Test with a file name pattern.
+	g := cfg.GeneratorsFor(filepath.Join("api", "user.proto"))
+	if len(g) != 1 || g[0].Command != "buf generate" {
+		t.Fatal("GeneratorsFor should have matched *.proto.", g)
+		return
+	}
+
+	//Test with an asset command.
+	cfg.AssetCommands = []AssetCommand{{Pattern: "web/*.ts", Command: "esbuild"}}
 	err = cfg.validate()
 	if err != nil {
 		t.Fatal(err)
 		return
 	}
-	if cfg.BuildName != newDefaultConfig().BuildName {
-		t.Fatal("Default value not set for BuildName.")
+	if len(cfg.AssetCommandsFor(filepath.Join("web", "app.ts"))) != 1 {
+		t.Fatal("AssetCommandsFor should have matched web/*.ts.")
 		return
 	}
 
-	cfg.BuildLogFilename = ""
-	err = cfg.validate()
-	if err != nil {
-		t.Fatal(err)
+	//Test with a path pattern.
+	if len(cfg.GeneratorsFor(filepath.Join("db", "query.sql"))) != 1 {
+		t.Fatal("GeneratorsFor should have matched db/*.sql.")
 		return
 	}
-	if cfg.BuildLogFilename != newDefaultConfig().BuildLogFilename {
-		t.Fatal("Default value not set for BuildLogFilename.")
+	if len(cfg.GeneratorsFor(filepath.Join("other", "query.sql"))) != 0 {
+		t.Fatal("GeneratorsFor should not have matched db/*.sql.")
 		return
 	}
 }
 
-func TestIsTempDir(t *testing.T) {
+func TestIsAutoIgnoredDirectory(t *testing.T) {
 	//Get a default config to work from.
 	cfg := newDefaultConfig()
+	cfg.AutoIgnore = true
 
-	//Test with known matching dir.
-	p := "./tmp"
-	yes, err := cfg.IsTempDir(p)
+	//Test with known auto ignored dir.
+	p := filepath.Join("web", "dist")
+	if !cfg.IsAutoIgnoredDirectory(p) {
+		t.Fatal("IsAutoIgnoredDirectory should have returned true for dist.")
+		return
+	}
+
+	//Test with a dir containing the marker file.
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, IgnoreMarkerFile), nil, 0644)
 	if err != nil {
 		t.Fatal(err)
 		return
 	}
-	if !yes {
-		t.Fatal("TempDir path matches, IsTempDir should have returned true.")
+	if !cfg.IsAutoIgnoredDirectory(dir) {
+		t.Fatal("IsAutoIgnoredDirectory should have returned true for directory with marker file.")
 		return
 	}
 
 	//Test with known non-matching dir.
-	p = "./temporary"
-	yes, err = cfg.IsTempDir(p)
-	if err != nil {
-		t.Fatal(err)
+	p = "distribution"
+	if cfg.IsAutoIgnoredDirectory(p) {
+		t.Fatal("IsAutoIgnoredDirectory should have returned false.")
 		return
 	}
-	if yes {
-		t.Fatal("TempDir path does not match, IsTempDir should have returned false.")
+
+	//Test with watching vendor enabled.
+	if !cfg.IsAutoIgnoredDirectory("vendor") {
+		t.Fatal("IsAutoIgnoredDirectory should have returned true for vendor.")
 		return
 	}
-}
-
-func TestIsDirectoryToIgnore(t *testing.T) {
-	//Get a default config to work from.
-	cfg := newDefaultConfig()
-
-	//Test with known matching dir.
-	p := "node_modules"
-	yes := cfg.IsDirectoryToIgnore(p)
-	if !yes {
-		t.Fatal("DirectoryiesToIgnore path matches, IsDirectoryToIgnore should have returned true.")
+	cfg.WatchVendor = true
+	if cfg.IsAutoIgnoredDirectory("vendor") {
+		t.Fatal("IsAutoIgnoredDirectory should have returned false for vendor when WatchVendor is enabled.")
 		return
 	}
 
-	//Test with known non-matching dir.
-	p = "some_source_dir"
-	yes = cfg.IsDirectoryToIgnore(p)
-	if yes {
-		t.Fatal("DirectoryiesToIgnore path matches, IsDirectoryToIgnore should have returned true.")
+	//Test with auto ignoring disabled.
+	cfg.AutoIgnore = false
+	if cfg.IsAutoIgnoredDirectory(dir) {
+		t.Fatal("IsAutoIgnoredDirectory should have returned false when AutoIgnore is disabled.")
 		return
 	}
 }
@@ -159,6 +770,31 @@ func TestIsRebuildExtension(t *testing.T) {
 	}
 }
 
+func TestIsEmbeddedPath(t *testing.T) {
+	//Get a default config to work from.
+	cfg := newDefaultConfig()
+	cfg.EmbeddedPaths = []string{"web/static", "templates/*.tmpl", "**/*.sql"}
+
+	tests := []struct {
+		path     string
+		embedded bool
+	}{
+		{filepath.Join("web", "static", "app.css"), true},
+		{filepath.Join("web", "static", "img", "logo.png"), true},
+		{filepath.Join("web", "other.css"), false},
+		{filepath.Join("templates", "index.tmpl"), true},
+		{filepath.Join("templates", "index.html"), false},
+		{filepath.Join("db", "migrations", "001.sql"), true},
+		{"main.go", false},
+	}
+	for _, tt := range tests {
+		if embedded := cfg.IsEmbeddedPath(tt.path); embedded != tt.embedded {
+			t.Fatal("Unexpected result for", tt.path, embedded)
+			return
+		}
+	}
+}
+
 func TestIsExtensionToWatch(t *testing.T) {
 	//Get a default config to work from.
 	cfg := newDefaultConfig()
@@ -220,6 +856,74 @@ func TestOverrideVerbose(t *testing.T) {
 		t.Fatal("Verbose not overridden correctly.")
 		return
 	}
+	if cfg.LogLevel != LogLevelDebug {
+		t.Fatal("LogLevel not set to debug when Verbose was overridden.")
+		return
+	}
+}
+
+func TestOverrideLogLevel(t *testing.T) {
+	//Get a default config to work from.
+	cfg := newDefaultConfig()
+
+	err := cfg.OverrideLogLevel("TRACE")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.LogLevel != LogLevelTrace {
+		t.Fatal("LogLevel not overridden correctly.", cfg.LogLevel)
+		return
+	}
+
+	err = cfg.OverrideLogLevel("loud")
+	if err == nil {
+		t.Fatal("Error about invalid log level should have been returned.")
+		return
+	}
+}
+
+func TestIsLogLevel(t *testing.T) {
+	//Get a default config to work from.
+	cfg := newDefaultConfig()
+	cfg.LogLevel = LogLevelWarn
+
+	if !cfg.IsLogLevel(LogLevelError) {
+		t.Fatal("IsLogLevel should have returned true for a less verbose level.")
+		return
+	}
+	if !cfg.IsLogLevel(LogLevelWarn) {
+		t.Fatal("IsLogLevel should have returned true for the same level.")
+		return
+	}
+	if cfg.IsLogLevel(LogLevelDebug) {
+		t.Fatal("IsLogLevel should have returned false for a more verbose level.")
+		return
+	}
+}
+
+func TestUseColors(t *testing.T) {
+	//Get a default config to work from.
+	cfg := newDefaultConfig()
+
+	t.Setenv("NO_COLOR", "")
+	if !cfg.UseColors() {
+		t.Fatal("UseColors should have returned true.")
+		return
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if cfg.UseColors() {
+		t.Fatal("UseColors should have returned false when NO_COLOR is set.")
+		return
+	}
+
+	t.Setenv("NO_COLOR", "")
+	cfg.Colors.Disabled = true
+	if cfg.UseColors() {
+		t.Fatal("UseColors should have returned false when colors are disabled.")
+		return
+	}
 }
 
 func TestIsStringInSlice(t *testing.T) {
@@ -237,3 +941,288 @@ func TestIsStringInSlice(t *testing.T) {
 		return
 	}
 }
+
+func TestApplyProfile(t *testing.T) {
+	//Parse a config with profiles, the same as Read() does.
+	y := []byte(`
+GoTags: base
+Env:
+  PORT: "8080"
+Profiles:
+  default:
+    GoTags: dev
+  debug:
+    GoTags: debug
+    Env:
+      LOG_LEVEL: debug
+`)
+	var cfg File
+	err := yaml.Unmarshal(y, &cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Test with the default profile being used when no profile is given.
+	dflt := cfg
+	err = dflt.applyProfile("")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if dflt.GoTags != "dev" {
+		t.Fatal("Default profile should have been applied.", dflt.GoTags)
+		return
+	}
+
+	//Test with a named profile, maps should be merged.
+	err = cfg.applyProfile("debug")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.GoTags != "debug" || cfg.Env["LOG_LEVEL"] != "debug" || cfg.Env["PORT"] != "8080" {
+		t.Fatal("Profile not applied correctly.", cfg.GoTags, cfg.Env)
+		return
+	}
+
+	//Test with a profile that doesn't exist.
+	err = cfg.applyProfile("missing")
+	if err == nil {
+		t.Fatal("Error about missing profile should have been returned.")
+		return
+	}
+}
+
+func TestReadFileExtends(t *testing.T) {
+	//Create a base config file and a config file extending it.
+	dir := t.TempDir()
+	base := filepath.Join(dir, "fresher.base.conf")
+	err := os.WriteFile(base, []byte("BuildName: base\nDirectoriesToIgnore: [tmp, web]\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	child := filepath.Join(dir, "service", "fresher.conf")
+	err = os.MkdirAll(filepath.Dir(child), 0755)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = os.WriteFile(child, []byte("Extends: ../fresher.base.conf\nBuildName: service\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Test that fields are inherited and overridden.
+	cfg, err := readFile(child, map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cfg.BuildName != "service" {
+		t.Fatal("BuildName should have been overridden.", cfg.BuildName)
+		return
+	}
+	if len(cfg.DirectoriesToIgnore) != 2 {
+		t.Fatal("DirectoriesToIgnore should have been inherited.", cfg.DirectoriesToIgnore)
+		return
+	}
+
+	//Test with a config file extending itself.
+	err = os.WriteFile(base, []byte("Extends: fresher.base.conf\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	_, err = readFile(child, map[string]bool{})
+	if err == nil {
+		t.Fatal("Error about config file extending itself should have been returned.")
+		return
+	}
+}
+
+func TestUpdateAndReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultConfigFileName)
+	err := os.WriteFile(path, []byte("WorkingDir: .\nEntryPoint: .\nGoTags: file\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	err = Read(path, "", false)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	before := Data()
+
+	//Updating should save a new config, not modify the config already in use.
+	err = Update(func(cfg *File) error {
+		cfg.OverrideTags("flag")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if Data().GoTags != "flag" {
+		t.Fatal("Update not applied.", Data().GoTags)
+		return
+	}
+	if before.GoTags != "file" {
+		t.Fatal("Config in use should not have been modified.", before.GoTags)
+		return
+	}
+
+	//Reloading should read changes to the file and keep updates.
+	err = os.WriteFile(path, []byte("WorkingDir: .\nEntryPoint: .\nGoTags: file\nBuildName: reloaded\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = Reload()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if Data().BuildName != "reloaded" || Data().GoTags != "flag" {
+		t.Fatal("Reload should read the file and reapply updates.", Data().BuildName, Data().GoTags)
+		return
+	}
+
+	//An invalid file should keep the config in use.
+	err = os.WriteFile(path, []byte("WorkingDir: [\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = Reload()
+	if err == nil {
+		t.Fatal("Error about invalid config file should have been returned.")
+		return
+	}
+	if Data().BuildName != "reloaded" {
+		t.Fatal("Config in use should have been kept.", Data().BuildName)
+		return
+	}
+}
+
+func TestUse(t *testing.T) {
+	UseDefaults()
+	if Data().EntryPoint != newDefaultConfig().EntryPoint {
+		t.Fatal("Default config not used.", Data().EntryPoint)
+		return
+	}
+
+	cfg := Defaults()
+	cfg.GoTags = "memory"
+	cfg.BuildDelayMilliseconds = -1
+	err := Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if Data().GoTags != "memory" || Data().BuildDelayMilliseconds != newDefaultConfig().BuildDelayMilliseconds {
+		t.Fatal("Provided config should have been validated.", Data().GoTags, Data().BuildDelayMilliseconds)
+		return
+	}
+
+	//Reloading should use the provided config again.
+	err = Update(func(cfg *File) error {
+		cfg.BuildName = "updated"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = Reload()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if Data().GoTags != "memory" || Data().BuildName != "updated" {
+		t.Fatal("Reload should use the provided config and reapply updates.", Data().GoTags, Data().BuildName)
+		return
+	}
+
+	//An invalid config should not be used.
+	cfg.EntryPoint = ""
+	err = Use(cfg)
+	if err == nil {
+		t.Fatal("Error about missing EntryPoint should have been returned.")
+		return
+	}
+	if Data().GoTags != "memory" {
+		t.Fatal("Config in use should have been kept.")
+		return
+	}
+}
+
+func TestIsLoopbackAddress(t *testing.T) {
+	tests := map[string]bool{
+		"localhost:9103": true,
+		"127.0.0.1:9103": true,
+		"[::1]:9103":     true,
+		":9103":          false,
+		"0.0.0.0:9103":   false,
+		"example.com:80": false,
+		"localhost":      false,
+	}
+
+	for addr, expected := range tests {
+		if isLoopbackAddress(addr) != expected {
+			t.Fatal("Unexpected result.", addr, expected)
+			return
+		}
+	}
+}
+
+func TestValidateBuildConfigs(t *testing.T) {
+	cfg := newDefaultConfig()
+	cfg.GoTags = "dev"
+	cfg.GoLdflags = "-s -w"
+	cfg.BuildConfig = "missing"
+	cfg.BuildConfigs = []BuildConfig{
+		{Name: " sqlite ", GoTags: " sqlite "},
+		{Name: ""},
+		{Name: "sqlite", GoTags: "duplicate"},
+		{Name: "none"},
+	}
+	err := cfg.validate()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if names := cfg.BuildConfigNames(); len(names) != 2 || names[0] != "sqlite" || names[1] != "none" {
+		t.Fatal("Unexpected build configs.", names)
+		return
+	}
+	if cfg.BuildConfig != "sqlite" {
+		t.Fatal("Missing BuildConfig should default to the first build config.", cfg.BuildConfig)
+		return
+	}
+	if cfg.BuildTags() != "sqlite" || cfg.BuildLdflags() != "-s -w" {
+		t.Fatal("Unexpected build flags.", cfg.BuildTags(), cfg.BuildLdflags())
+		return
+	}
+
+	//A blank GoTags in a build config builds without tags.
+	cfg.BuildConfig = "none"
+	if cfg.BuildTags() != "" {
+		t.Fatal("Tags should be blank.", cfg.BuildTags())
+		return
+	}
+
+	//Without build configs, the fields are used as-is.
+	cfg.BuildConfigs = nil
+	if cfg.BuildTags() != "dev" || cfg.BuildConfigEnv() != nil {
+		t.Fatal("GoTags should be used without build configs.", cfg.BuildTags())
+		return
+	}
+}
@@ -5,10 +5,7 @@ go 1.19
 require (
 	github.com/fsnotify/fsnotify v1.6.0
 	github.com/mattn/go-colorable v0.1.13
+	github.com/mattn/go-isatty v0.0.16
+	golang.org/x/sys v0.3.0
 	gopkg.in/yaml.v2 v2.4.0
 )
-
-require (
-	github.com/mattn/go-isatty v0.0.16 // indirect
-	golang.org/x/sys v0.3.0 // indirect
-)
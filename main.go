@@ -1,15 +1,16 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/c9845/fresher/config"
 	"github.com/c9845/fresher/runner3"
+	"github.com/c9845/fresher/update"
 	"github.com/c9845/fresher/version"
 )
 
@@ -20,9 +21,29 @@ func main() {
 	printConfig := flag.Bool("print-config", false, "Print the config file this app has loaded.")
 	showVersion := flag.Bool("version", false, "Shows the version of the app.")
 	tags := flag.String("tags", "", "Anything provided to 'go run' or 'go build' -tags.")
-	verbose := flag.Bool("verbose", false, "Verbose logging.")
+	verbose := flag.Bool("verbose", false, "Verbose logging, same as -log-level=debug.")
+	logLevel := flag.String("log-level", "", "Logging level: error, warn, info, debug, or trace.")
+	eventStream := flag.String("event-stream", "", "Write JSON events to fd:N, unix:/path, tcp:host:port, or a file.")
+	dryRun := flag.Bool("dry-run", false, "Print the directories that would be watched or ignored and the build and run commands, then exit.")
+	once := flag.Bool("once", false, "Build and run the binary once, without watching, and exit with the binary's exit code.")
+	profile := flag.String("profile", "", "The profile, from the config file's Profiles, to use.")
+	skipWarm := flag.Bool("skip-warm", false, "Skip warming the build cache, see WarmBuildCache in the config file.")
+	tui := flag.Bool("tui", false, "Show a status bar at the bottom of the terminal with the current state, last build, and last changed file.")
+	upgrade := flag.Bool("upgrade", false, "Replace this executable with the latest release of fresher.")
+	watchGit := flag.Bool("watch-git", false, "Rebuild once after git operations, i.e. switching branches, instead of for each changed file.")
+	chdir := flag.String("chdir", "", "Change to this directory before doing anything else.")
 	flag.Parse()
 
+	//Change directory, if needed, before anything else so that all paths, including
+	//the config file path, are relative to the new directory.
+	if *chdir != "" {
+		err := os.Chdir(*chdir)
+		if err != nil {
+			log.Fatalln("Could not change directory.", err)
+			return
+		}
+	}
+
 	//If user just wants to see app version, print it and exit.
 	//Not using log.Println() so that a timestamp isn't printed.
 	if *showVersion {
@@ -31,6 +52,18 @@ func main() {
 		return
 	}
 
+	//Check if user wants to upgrade to the latest release.
+	if *upgrade {
+		err := upgradeFresher()
+		if err != nil {
+			log.Fatalln("Could not upgrade.", err)
+			return
+		}
+
+		os.Exit(0)
+		return
+	}
+
 	//Check if user wants to create a default config file.
 	if *createConfig {
 		err := config.CreateDefaultConfig()
@@ -50,33 +83,170 @@ func main() {
 	// - If the --config flag has a path set, look for a file at the provided path.
 	//    - If a file is found, parse it as config file and handle any errors.
 	//    - If a file cannot be found, create a default config and save it to the path provided.
-	err := config.Read(*configFilePath, *printConfig)
+	//
+	//When a path to a service is given, i.e. `fresher ./cmd/api` in a monorepo, a
+	//config file next to the service is used, if one exists and -config wasn't
+	//provided, before falling back to the config file in the current directory.
+	//fresher then runs from the service's module root directory.
+	service := flag.Arg(0)
+	var serviceEntryPoint string
+	if service != "" {
+		configFlagSet := false
+		flag.Visit(func(f *flag.Flag) {
+			if f.Name == "config" {
+				configFlagSet = true
+			}
+		})
+		if serviceConfig := filepath.Join(service, config.DefaultConfigFileName); !configFlagSet {
+			if _, err := os.Stat(serviceConfig); err == nil {
+				*configFilePath = serviceConfig
+			}
+		}
+
+		moduleRoot, entryPoint, err := config.FindService(service)
+		if err != nil {
+			log.Fatalln("Could not find service.", err)
+			return
+		}
+		serviceEntryPoint = entryPoint
+
+		//The config file path is made absolute since it is relative to the
+		//current directory, not the module root.
+		if p, err := filepath.Abs(*configFilePath); err == nil {
+			*configFilePath = p
+		}
+		err = os.Chdir(moduleRoot)
+		if err != nil {
+			log.Fatalln("Could not change to service's module directory.", err)
+			return
+		}
+	}
+
+	err := config.Read(*configFilePath, strings.TrimSpace(*profile), *printConfig)
 	if err != nil {
-		log.Fatalln("Could not parse config file.", errors.Unwrap(err))
+		log.Fatalln("Could not parse config file.", err)
 		return
 	}
 
-	//Handle overriding config with flags.
-	if len(strings.TrimSpace(*tags)) > 0 {
-		if !config.Data().UsingDefaults() {
-			log.Println("WARNING! (main) Overriding Tags with provided -tags.")
+	//Handle overriding config with flags. The overrides are kept if the config
+	//file is reloaded.
+	if len(strings.TrimSpace(*tags)) > 0 && !config.Data().UsingDefaults() {
+		log.Println("WARNING! (main) Overriding Tags with provided -tags.")
+	}
+	err = config.Update(func(cfg *config.File) error {
+		if serviceEntryPoint != "" {
+			cfg.OverrideEntryPoint(serviceEntryPoint)
+		}
+		if len(strings.TrimSpace(*tags)) > 0 {
+			cfg.OverrideTags(*tags)
 		}
-		config.Data().OverrideTags(*tags)
+		if *verbose {
+			cfg.OverrideVerbose(*verbose)
+		}
+		if *skipWarm {
+			cfg.OverrideWarmBuildCache(config.WarmBuildCacheOff)
+		}
+		if *watchGit {
+			cfg.OverrideWatchGit(*watchGit)
+		}
+		if len(strings.TrimSpace(*eventStream)) > 0 {
+			cfg.OverrideEventStream(*eventStream)
+		}
+		if len(strings.TrimSpace(*logLevel)) > 0 {
+			return cfg.OverrideLogLevel(*logLevel)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalln("Could not override config with flags.", err)
+		return
 	}
-	if *verbose {
-		config.Data().OverrideVerbose(*verbose)
+
+	//Print what would be watched and built, if needed. This is done before
+	//configuring since nothing should be started or created.
+	if *dryRun {
+		err = runner3.DryRun()
+		if err != nil {
+			log.Fatalln("Error with dry run.", err)
+			return
+		}
+
+		os.Exit(0)
+		return
+	}
+
+	//Let the user know if a newer release is available. This is done in the
+	//background so that starting isn't slowed down by a slow network.
+	if config.Data().CheckForUpdates {
+		go func() {
+			r, err := update.CheckDaily()
+			if err != nil {
+				log.Println("WARNING! (main) Could not check for a newer release.", err)
+				return
+			}
+			if r != nil {
+				log.Printf("(main) fresher %s is available, you have %s. Run fresher -upgrade to install it.", r.Version, version.V)
+			}
+		}()
 	}
 
 	//Configure.
 	err = runner3.Configure()
 	if err != nil {
-		log.Fatal("Error with configure", err)
+		log.Fatalln("Error with configure.", err)
+		return
+	}
+
+	//Build and run the binary a single time, if needed. This exits fresher.
+	if *once {
+		runner3.Once()
 		return
 	}
 
-	//Watch for changes to files.
-	runner3.Watch()
+	//Show the status bar, if needed. Plain logging is used if the status bar can't
+	//be shown, i.e. output is redirected to a file.
+	if *tui {
+		err = runner3.EnableTUI()
+		if err != nil {
+			log.Println("WARNING! (main) Could not show status bar, using plain logging.", err)
+		}
+	}
+
+	//Watch for changes to files and run. This blocks until fresher is stopped.
+	err = runner3.Start()
+	if err != nil {
+		log.Fatalln("Error with watching.", err)
+		return
+	}
+}
+
+// upgradeFresher replaces the running executable with the latest release, if it is
+// newer than the running version.
+func upgradeFresher() (err error) {
+	r, err := update.Latest()
+	if err != nil {
+		return
+	}
+	if !r.IsNewer() {
+		log.Printf("(main) fresher %s is the latest release.", version.V)
+		return
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return
+	}
+
+	log.Printf("(main) Upgrading fresher from %s to %s...", version.V, r.Version)
+	err = r.Upgrade(path)
+	if err != nil {
+		return
+	}
 
-	//Run.
-	runner3.Start()
+	log.Printf("(main) Upgraded fresher to %s.", r.Version)
+	return
 }
@@ -0,0 +1,110 @@
+package runner3
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// assetRunner runs AssetCommands when matching files change. Asset commands run
+// separately from building and running the binary, in their own goroutine, so that
+// bundling front end assets never delays, or is delayed by, `go build`.
+type assetRunner struct {
+	mu sync.Mutex
+
+	//commands are the asset commands to run, keyed by command so that each command
+	//is only run once regardless of how many matching files changed.
+	commands map[string]bool
+
+	//trigger is sent on when commands are queued. This is buffered so that queuing
+	//never blocks the watcher.
+	trigger chan struct{}
+}
+
+// assets is the package level asset runner.
+var assets = &assetRunner{commands: map[string]bool{}, trigger: make(chan struct{}, 1)}
+
+// startAssets starts running asset commands as they are queued. This does nothing if
+// no AssetCommands are set in the config file.
+func startAssets() {
+	if len(config.Data().AssetCommands) == 0 {
+		return
+	}
+
+	go func() {
+		for range assets.trigger {
+			//Wait a short while to catch other files changed at the same time, i.e.
+			//a "save all" in an editor, so commands are only run once.
+			time.Sleep(50 * time.Millisecond)
+			assets.run()
+		}
+	}()
+}
+
+// queue notes the asset commands matching a changed file so they are run. False is
+// returned if no asset commands match, meaning the file should be handled as usual.
+func (a *assetRunner) queue(path string) bool {
+	matches := config.Data().AssetCommandsFor(path)
+	if len(matches) == 0 {
+		return false
+	}
+
+	a.mu.Lock()
+	for _, m := range matches {
+		a.commands[m.Command] = true
+	}
+	a.mu.Unlock()
+
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+		//Already triggered, the queued commands will be picked up.
+	}
+
+	return true
+}
+
+// run runs the queued asset commands, in the order they are listed in the config
+// file, and clears the queue.
+func (a *assetRunner) run() {
+	a.mu.Lock()
+	queued := a.commands
+	a.commands = map[string]bool{}
+	a.mu.Unlock()
+
+	for _, ac := range config.Data().AssetCommands {
+		if !queued[ac.Command] {
+			continue
+		}
+
+		events.Printf("Running asset command... %s", ac.Command)
+		start := time.Now()
+		err := runCommand(ac.Command)
+		if err != nil {
+			errs.Printf("Asset command %s failed %s", ac.Command, err)
+			emit(streamAssetsFailed, streamEvent{Command: ac.Command, Error: err.Error()})
+			continue
+		}
+
+		//Tools that reload the browser can listen for this event on the event
+		//stream.
+		emit(streamAssetsBuilt, streamEvent{Command: ac.Command, DurationSeconds: time.Since(start).Seconds()})
+	}
+}
+
+// runCommand runs a Generator or AssetCommand command in the WorkingDir with output
+// shown in the terminal. The command is split on whitespace to get the command and
+// its arguments.
+func runCommand(command string) error {
+	fields := strings.Fields(command)
+
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Dir = config.Data().WorkingDir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
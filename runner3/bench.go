@@ -0,0 +1,291 @@
+package runner3
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// benchmarkRunner runs the benchmarks in BenchmarkPackage after each successful build
+// and compares the results to the previous run. Benchmarks run in their own goroutine
+// since they can take a while and shouldn't delay rebuilding.
+type benchmarkRunner struct {
+	//trigger is sent on after a successful build. This is buffered so that queuing
+	//never blocks building and builds while benchmarks are running cause only one
+	//more run.
+	trigger chan struct{}
+
+	//last is the results of the previous run, compared against the next run.
+	last *benchmarkResults
+
+	//cmd is the running `go test`, nil once it exits. stopped is set when cmd is
+	//stopped, by stop(), since a build started.
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stopped bool
+}
+
+// benchmarks is the package level benchmark runner.
+var benchmarks = &benchmarkRunner{trigger: make(chan struct{}, 1)}
+
+// benchmarkResults is the parsed output of `go test -bench`. Each benchmark's values
+// are averaged across runs when BenchmarkCount is more than 1.
+type benchmarkResults struct {
+	//names is the benchmarks in the order they were output.
+	names []string
+
+	//units is, for each benchmark, the units in the order they were output, i.e.
+	//ns/op, B/op, allocs/op.
+	units map[string][]string
+
+	//values is the average value for each benchmark and unit.
+	values map[string]map[string]float64
+}
+
+// startBenchmarks starts running benchmarks as builds succeed. This does nothing if
+// BenchmarkPackage is not set in the config file.
+func startBenchmarks() {
+	if config.Data().BenchmarkPackage == "" {
+		return
+	}
+
+	go func() {
+		for range benchmarks.trigger {
+			benchmarks.run()
+		}
+	}()
+}
+
+// queue causes the benchmarks to be run, if BenchmarkPackage is set.
+func (b *benchmarkRunner) queue() {
+	if config.Data().BenchmarkPackage == "" {
+		return
+	}
+
+	select {
+	case b.trigger <- struct{}{}:
+	default:
+		//Already triggered.
+	}
+}
+
+// stop stops the benchmarks, if running, so that they don't compete with building the
+// binary and aren't run against out of date code. A queued run is discarded too since
+// the benchmarks are queued again once the build succeeds.
+func (b *benchmarkRunner) stop() {
+	select {
+	case <-b.trigger:
+	default:
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cmd != nil {
+		b.stopped = true
+		killProcessTree(b.cmd.Process)
+	}
+}
+
+// getBenchmarkArgs returns the arguments passed to "go" to run the benchmarks. Tests
+// are skipped with -run so that only benchmarks are run.
+func getBenchmarkArgs() []string {
+	cfg := config.Data()
+	args := []string{"test", "-run", "^$", "-bench", cfg.BenchmarkPattern, "-benchmem", "-count", strconv.Itoa(cfg.BenchmarkCount)}
+	if tags := cfg.BuildTags(); len(tags) > 0 {
+		args = append(args, "-tags", tags)
+	}
+	if mod := cfg.GoMod; mod != "" {
+		args = append(args, "-mod="+mod)
+	}
+
+	return append(args, cfg.BenchmarkPackage)
+}
+
+// run runs the benchmarks and logs the change from the previous run.
+func (b *benchmarkRunner) run() {
+	args := getBenchmarkArgs()
+	events.Printf("Running benchmarks... %s", formatCommand("go", args))
+	start := time.Now()
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = config.Data().WorkingDir
+	cmd.Env = getBuildEnv(config.Data())
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stdout
+
+	//The benchmarks are run in a process group so that stopping `go test` stops
+	//the test binary it runs too.
+	setProcessGroup(cmd)
+	err := b.start(cmd)
+	if err != nil {
+		errs.Printf("Could not run benchmarks %s", err)
+		return
+	}
+
+	stopped, err := b.wait(cmd)
+	if stopped {
+		events.Verbosef("Running benchmarks...stopped, building.")
+		return
+	}
+	if err != nil {
+		errs.Printf("Benchmarks failed %s: %s", err, firstLine(stdout.String()))
+		return
+	}
+
+	results := parseBenchmarks(stdout.String())
+	if len(results.names) == 0 {
+		warn.Printf("No benchmarks matching %q found in %s.", config.Data().BenchmarkPattern, config.Data().BenchmarkPackage)
+		return
+	}
+
+	events.Printf("Benchmarks took %s.", time.Since(start).Round(time.Millisecond))
+	for _, line := range compareBenchmarks(b.last, results) {
+		events.Printf("  %s", line)
+	}
+	b.last = results
+}
+
+// start starts the `go test` command and notes it as running so that it can be
+// stopped by stop().
+func (b *benchmarkRunner) start(cmd *exec.Cmd) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	b.cmd = cmd
+	b.stopped = false
+	return nil
+}
+
+// wait waits for the `go test` command to exit. True is returned if it was stopped
+// by stop().
+func (b *benchmarkRunner) wait(cmd *exec.Cmd) (stopped bool, err error) {
+	err = cmd.Wait()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	stopped = b.stopped
+	b.cmd = nil
+	b.stopped = false
+	return
+}
+
+// parseBenchmarks parses the output of `go test -bench`. Lines that aren't benchmark
+// results, i.e. "goos: linux" or "PASS", are ignored. A result line is the
+// benchmark's name, the number of iterations, and then pairs of values and units:
+//
+//	BenchmarkParse-8   	  500000	      2345 ns/op	     128 B/op	       3 allocs/op
+func parseBenchmarks(out string) *benchmarkResults {
+	r := &benchmarkResults{
+		units:  map[string][]string{},
+		values: map[string]map[string]float64{},
+	}
+	counts := map[string]map[string]int{}
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+
+		name := fields[0]
+		if _, ok := r.values[name]; !ok {
+			r.names = append(r.names, name)
+			r.values[name] = map[string]float64{}
+			counts[name] = map[string]int{}
+		}
+
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			unit := fields[i+1]
+
+			if counts[name][unit] == 0 {
+				r.units[name] = append(r.units[name], unit)
+			}
+			counts[name][unit]++
+
+			//Keep a running average.
+			n := float64(counts[name][unit])
+			r.values[name][unit] += (v - r.values[name][unit]) / n
+		}
+	}
+
+	return r
+}
+
+// compareBenchmarks returns a line for each benchmark in current noting its results
+// and, if the benchmark was in the previous run, the change, i.e.:
+//
+//	BenchmarkParse-8: 2345 -> 2100 ns/op (-10.45%), 3 -> 2 allocs/op (-33.33%)
+//
+// B/op is only shown if it changed since it usually changes along with allocs/op.
+func compareBenchmarks(previous, current *benchmarkResults) (lines []string) {
+	for _, name := range current.names {
+		var parts []string
+		for _, unit := range current.units[name] {
+			v := current.values[name][unit]
+
+			var old float64
+			found := false
+			if previous != nil {
+				old, found = previous.values[name][unit]
+			}
+			if !found {
+				parts = append(parts, fmt.Sprintf("%s %s", formatBenchmarkValue(v), unit))
+				continue
+			}
+			if unit == "B/op" && old == v {
+				continue
+			}
+
+			parts = append(parts, fmt.Sprintf("%s -> %s %s (%s)", formatBenchmarkValue(old), formatBenchmarkValue(v), unit, formatBenchmarkDelta(old, v)))
+		}
+
+		lines = append(lines, fmt.Sprintf("%s: %s", name, strings.Join(parts, ", ")))
+	}
+
+	return
+}
+
+// formatBenchmarkValue returns a benchmark value without needless decimals, i.e. 2345
+// rather than 2345.000000, while keeping precision for small values.
+func formatBenchmarkValue(v float64) string {
+	if math.Abs(v) >= 100 {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+
+	return strconv.FormatFloat(v, 'g', 3, 64)
+}
+
+// formatBenchmarkDelta returns the change from old to cur as a percentage, or "~" if
+// there was no change.
+func formatBenchmarkDelta(old, cur float64) string {
+	if old == cur {
+		return "~"
+	}
+	if old == 0 {
+		return "was 0"
+	}
+
+	return fmt.Sprintf("%+.2f%%", (cur-old)/old*100)
+}
@@ -0,0 +1,85 @@
+package runner3
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBenchmarks(t *testing.T) {
+	out := `goos: linux
+goarch: amd64
+pkg: example.com/app/parser
+BenchmarkParse-8   	  500000	      2000 ns/op	     128 B/op	       3 allocs/op
+BenchmarkParse-8   	  500000	      3000 ns/op	     128 B/op	       3 allocs/op
+BenchmarkLex-8     	 1000000	      12.5 ns/op	       0 B/op	       0 allocs/op
+PASS
+ok  	example.com/app/parser	3.456s
+`
+	r := parseBenchmarks(out)
+	if !reflect.DeepEqual(r.names, []string{"BenchmarkParse-8", "BenchmarkLex-8"}) {
+		t.Fatal("Unexpected benchmarks.", r.names)
+		return
+	}
+	if !reflect.DeepEqual(r.units["BenchmarkParse-8"], []string{"ns/op", "B/op", "allocs/op"}) {
+		t.Fatal("Unexpected units.", r.units)
+		return
+	}
+	if v := r.values["BenchmarkParse-8"]["ns/op"]; v != 2500 {
+		t.Fatal("Runs should be averaged.", v)
+		return
+	}
+
+	lines := compareBenchmarks(nil, r)
+	if len(lines) != 2 || lines[1] != "BenchmarkLex-8: 12.5 ns/op, 0 B/op, 0 allocs/op" {
+		t.Fatal("Unexpected first run.", lines)
+		return
+	}
+
+	next := parseBenchmarks("BenchmarkParse-8 500000 2250 ns/op 128 B/op 2 allocs/op\n")
+	lines = compareBenchmarks(r, next)
+	if len(lines) != 1 || lines[0] != "BenchmarkParse-8: 2500 -> 2250 ns/op (-10.00%), 3 -> 2 allocs/op (-33.33%)" {
+		t.Fatal("Unexpected comparison.", lines)
+		return
+	}
+}
+
+func TestBenchmarkRunnerStop(t *testing.T) {
+	b := &benchmarkRunner{trigger: make(chan struct{}, 1)}
+
+	//Stopping when not running should do nothing.
+	b.stop()
+
+	cmd := helperCommand("sleep")
+	err := b.start(cmd)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	b.trigger <- struct{}{}
+
+	b.stop()
+
+	done := make(chan bool)
+	go func() {
+		stopped, _ := b.wait(cmd)
+		done <- stopped
+	}()
+	select {
+	case stopped := <-done:
+		if !stopped {
+			t.Fatal("Benchmarks should be noted as stopped.")
+			return
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Benchmarks were not stopped.")
+		return
+	}
+
+	select {
+	case <-b.trigger:
+		t.Fatal("Queued run should have been discarded.")
+		return
+	default:
+	}
+}
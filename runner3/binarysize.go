@@ -0,0 +1,57 @@
+package runner3
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/c9845/fresher/config"
+)
+
+// reportBinarySize logs the size of the built binary and the change in size from the
+// previous successful build. A warning is logged if the binary grew by more than
+// BinaryGrowthWarnKB since this usually means a large file was embedded by accident.
+func reportBinarySize() {
+	fi, err := os.Stat(getPathToBuiltBinary(config.Data()))
+	if err != nil {
+		warn.Verbosef("Could not get size of built binary %s", err)
+		return
+	}
+
+	size := fi.Size()
+	delta := stats.recordBinarySize(size)
+
+	if delta == 0 {
+		events.Printf("Binary size %s", formatBytes(size))
+	} else {
+		events.Printf("Binary size %s (%s)", formatBytes(size), formatBytesDelta(delta))
+	}
+
+	limit := config.Data().BinaryGrowthWarnKB * 1024
+	if limit > 0 && delta > limit {
+		warn.Printf("Binary grew by %s, more than BinaryGrowthWarnKB (%dKB). Was a large file embedded?", formatBytes(delta), config.Data().BinaryGrowthWarnKB)
+	}
+}
+
+// formatBytes returns a human readable size, i.e. 12.3MB.
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// formatBytesDelta returns a human readable change in size with a sign, i.e.
+// +1.2MB or -340B.
+func formatBytesDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + formatBytes(-delta)
+	}
+	return "+" + formatBytes(delta)
+}
@@ -0,0 +1,83 @@
+package runner3
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/c9845/fresher/config"
+)
+
+// withBuildConfigEnv returns env with the Env of the build config in use, see
+// BuildConfigs, added. A nil env, meaning fresher's environment is used as-is, is
+// returned as-is if the build config doesn't set any Env.
+func withBuildConfigEnv(cfg *config.File, env []string) []string {
+	buildEnv := cfg.BuildConfigEnv()
+	if len(buildEnv) == 0 {
+		return env
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+
+	//Sorted so the environment is the same each time.
+	keys := []string{}
+	for k := range buildEnv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		env = append(env, k+"="+buildEnv[k])
+	}
+
+	return env
+}
+
+// nextBuildConfig returns the name of the build config after the one in use, wrapping
+// around to the first build config.
+func nextBuildConfig(cfg *config.File) string {
+	names := cfg.BuildConfigNames()
+	for i, name := range names {
+		if name == cfg.BuildConfig {
+			return names[(i+1)%len(names)]
+		}
+	}
+
+	return names[0]
+}
+
+// switchBuildConfig uses the build config with the given name, from BuildConfigs, and
+// rebuilds the binary. If name is blank, the next build config is used. This is done
+// by typing "b", optionally followed by a name, and enter or via the control API.
+//
+// The build config is set with config.Update() so that it is kept if the config file
+// is reloaded, as long as the build config still exists.
+func switchBuildConfig(name string) (err error) {
+	cfg := config.Data()
+	if len(cfg.BuildConfigs) == 0 {
+		return fmt.Errorf("no BuildConfigs in config file")
+	}
+
+	if name == "" {
+		name = nextBuildConfig(cfg)
+	}
+	if cfg.FindBuildConfig(name) == nil {
+		return fmt.Errorf("build config %s not found, available build configs are %s", name, cfg.BuildConfigNames())
+	}
+
+	err = config.Update(func(cfg *config.File) error {
+		if cfg.FindBuildConfig(name) != nil {
+			cfg.BuildConfig = name
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	events.Printf("Using build config %s, rebuilding...", name)
+	requestEvent(rebuildEventName)
+
+	return
+}
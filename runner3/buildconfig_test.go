@@ -0,0 +1,116 @@
+package runner3
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestSwitchBuildConfig(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.GoTags = "unused"
+	cfg.BuildConfigs = []config.BuildConfig{
+		{Name: "sqlite", GoTags: "sqlite", Env: map[string]string{"CGO_ENABLED": "1"}},
+		{Name: "postgres", GoTags: "postgres", GoLdflags: "-X main.db=postgres"},
+	}
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if tags := config.Data().BuildTags(); tags != "sqlite" {
+		t.Fatal("First build config should be used.", tags)
+		return
+	}
+	env := withBuildConfigEnv(config.Data(), []string{"A=1"})
+	if len(env) != 2 || env[1] != "CGO_ENABLED=1" {
+		t.Fatal("Unexpected env.", env)
+		return
+	}
+
+	//Switch to the next build config.
+	err = switchBuildConfig("")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if e, ok := receiveEvent(); !ok || e.Name != rebuildEventName {
+		t.Fatal("Rebuild should be requested.", e, ok)
+		return
+	}
+	if config.Data().BuildConfig != "postgres" || config.Data().BuildLdflags() != "-X main.db=postgres" {
+		t.Fatal("Should have switched to postgres.", config.Data().BuildConfig)
+		return
+	}
+	if env := withBuildConfigEnv(config.Data(), nil); env != nil {
+		t.Fatal("Env should be nil when the build config doesn't set Env.", env)
+		return
+	}
+
+	//Wrap around, by name.
+	err = switchBuildConfig("sqlite")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	receiveEvent()
+	if config.Data().BuildConfig != "sqlite" {
+		t.Fatal("Should have switched to sqlite.", config.Data().BuildConfig)
+		return
+	}
+	if next := nextBuildConfig(config.Data()); next != "postgres" {
+		t.Fatal("Unexpected next build config.", next)
+		return
+	}
+
+	err = switchBuildConfig("mysql")
+	if err == nil {
+		t.Fatal("Error should occur for a build config that doesn't exist.")
+		return
+	}
+	if _, ok := receiveEvent(); ok {
+		t.Fatal("Rebuild should not be requested.")
+		return
+	}
+}
+
+func TestBuildUsesConfigSnapshot(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.BuildConfigs = []config.BuildConfig{
+		{Name: "sqlite", GoTags: "sqlite", Env: map[string]string{"CGO_ENABLED": "1"}},
+		{Name: "postgres", GoTags: "postgres"},
+	}
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Switching build configs while building shouldn't change what the build that
+	//already started uses.
+	snapshot := config.Data()
+	err = switchBuildConfig("postgres")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	receiveEvent()
+
+	args := strings.Join(getBuildArgs(snapshot), " ")
+	if !strings.Contains(args, "-tags sqlite") {
+		t.Fatal("Build args should use the snapshot's build config.", args)
+		return
+	}
+	env := getBuildEnv(snapshot)
+	if len(env) == 0 || env[len(env)-1] != "CGO_ENABLED=1" {
+		t.Fatal("Build env should use the snapshot's build config.", env)
+		return
+	}
+	env = getBinaryEnv(snapshot)
+	if env[len(env)-1] != "CGO_ENABLED=1" {
+		t.Fatal("Binary env should use the snapshot's build config.", env)
+		return
+	}
+}
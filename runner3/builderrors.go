@@ -0,0 +1,241 @@
+package runner3
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/fresher/config"
+)
+
+// buildError is a single error parsed from the output of `go build`. The fields are
+// exported, with json tags, so that errors can be output as JSON for consumption by
+// editors and other tools.
+type buildError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// String returns the error in the typical file:line:col: message format that most
+// editors and terminals recognize as a link to the error's location.
+func (b buildError) String() string {
+	if b.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", b.File, b.Line, b.Column, b.Message)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", b.File, b.Line, b.Message)
+}
+
+// buildErrorLine matches a line of `go build` output noting an error's location, for
+// example "./main.go:10:2: undefined: x". The column is optional since some errors,
+// like those from cgo or the linker, only include a line number.
+var buildErrorLine = regexp.MustCompile(`^(.+?\.go):(\d+):(?:(\d+):)? (.+)$`)
+
+// lastBuildErrors is the list of errors parsed from the most recent failed build.
+// This is reset at the start of each build and used when handling a build failure
+// after build() returns.
+var lastBuildErrors []buildError
+
+// lastBuildOutput is the stderr output from the most recent build. This is given to
+// hooks so they can, for example, post the full output somewhere.
+var lastBuildOutput string
+
+// parseBuildErrors parses the stderr output from `go build` into a list of errors.
+// Duplicate errors are removed since `go build` can report the same error more than
+// once (for example, when a package is imported by multiple packages being built).
+//
+// Lines that start with a tab are a continuation of the previous error (i.e.: "have"
+// and "want" lines for a type mismatch) and are appended to the previous error's
+// message. Other lines, such as "# package/path" headers, are ignored.
+func parseBuildErrors(stderr string) (parsed []buildError) {
+	seen := map[string]bool{}
+
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if strings.HasPrefix(line, "\t") && len(parsed) > 0 {
+			parsed[len(parsed)-1].Message += "\n" + line
+			continue
+		}
+
+		matches := buildErrorLine.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		lineNumber, _ := strconv.Atoi(matches[2])
+		column, _ := strconv.Atoi(matches[3])
+		b := buildError{
+			File:    matches[1],
+			Line:    lineNumber,
+			Column:  column,
+			Message: matches[4],
+		}
+
+		if seen[b.String()] {
+			continue
+		}
+		seen[b.String()] = true
+
+		parsed = append(parsed, b)
+	}
+
+	return
+}
+
+// countFiles returns the number of distinct files the errors occured in.
+func countFiles(buildErrs []buildError) int {
+	files := map[string]bool{}
+	for _, b := range buildErrs {
+		files[b.File] = true
+	}
+
+	return len(files)
+}
+
+// repeatedFailure is the output of the most recent failed build and the number of
+// consecutive builds that failed with the same output. This is reset when a build
+// succeeds.
+var repeatedFailure struct {
+	output string
+	count  int
+}
+
+// reportBuildFailure outputs the errors from a failed build, see printBuildErrors().
+// If the build failed with the same output as the previous build, i.e. while in the
+// middle of a refactor, a single line noting the first error is output instead of
+// every error again. Repeated errors are always output in full as JSON so that other
+// tools don't need to remember the previous errors.
+func reportBuildFailure(stderr string, buildErrs []buildError) {
+	if stderr != repeatedFailure.output || config.Data().BuildErrorFormat == config.BuildErrorFormatJSON {
+		repeatedFailure.output = stderr
+		repeatedFailure.count = 1
+		printBuildErrors(stderr, buildErrs)
+		return
+	}
+
+	repeatedFailure.count++
+	first := firstLine(stderr)
+	if len(buildErrs) > 0 {
+		first = buildErrs[0].String()
+	}
+	errs.Printf("Same error as before (x%d): %s", repeatedFailure.count, first)
+}
+
+// isRepeatedFailure returns true if the most recent build failed with the same output
+// as the build before it.
+func isRepeatedFailure() bool {
+	return repeatedFailure.count > 1
+}
+
+// firstLine returns the first line of s that isn't blank or a "# package" header.
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return line
+		}
+	}
+
+	return ""
+}
+
+// printBuildErrors outputs the errors from a failed build in the format set in the
+// config file's BuildErrorFormat field. If no errors could be parsed from the
+// output, the raw output is printed instead so that the user still sees why the
+// build failed.
+func printBuildErrors(stderr string, buildErrs []buildError) {
+	if len(buildErrs) == 0 {
+		errs.Printf("Build output:\n%s", strings.TrimSpace(stderr))
+		return
+	}
+
+	if config.Data().BuildErrorFormat == config.BuildErrorFormatJSON {
+		//Write without the logger's timestamp or prefix so that each line is valid
+		//JSON that can be read by another tool.
+		w := logger.Writer()
+		for _, b := range buildErrs {
+			j, err := json.Marshal(b)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintln(w, string(j))
+		}
+		return
+	}
+
+	for _, b := range buildErrs {
+		errs.Printf("%s", b.String())
+	}
+	errs.Printf("%s in %s", pluralize(len(buildErrs), "error"), pluralize(countFiles(buildErrs), "file"))
+}
+
+// Kinds of build failures, see classifyBuildFailure(). The kind is included in the
+// build.failed event so that tools can handle each kind differently.
+const (
+	buildFailureMissingModule = "missing-module"
+	buildFailureCgo           = "cgo"
+	buildFailureSyntax        = "syntax"
+	buildFailureUndefined     = "undefined"
+	buildFailureGenerator     = "generator"
+	buildFailureTimeout       = "timeout"
+	buildFailureOther         = "other"
+)
+
+// cgoCompilerMissing matches the error output when cgo is used but the C compiler
+// isn't installed, i.e. `cgo: C compiler "gcc" not found` or, with older versions of
+// Go, `exec: "gcc": executable file not found in $PATH`.
+var cgoCompilerMissing = regexp.MustCompile(`cgo: C compiler "([^"]+)" not found|exec: "(gcc|clang|cc)": executable file not found`)
+
+// classifyBuildFailure returns the kind of build failure and a one line hint on how
+// to fix it. The hint is blank if the failure isn't one of the common failures
+// recognized. The kinds are checked in order of what blocks compiling the most, i.e.
+// missing modules stop any package from compiling, so the hint is for what to fix
+// first.
+func classifyBuildFailure(err error, stderr string, buildErrs []buildError) (kind, hint string) {
+	if err == errGeneratorFailed {
+		return buildFailureGenerator, ""
+	}
+	if err == errBuildTimedOut {
+		return buildFailureTimeout, fmt.Sprintf("go build took longer than BuildTimeoutSeconds (%ds), check for a hung module download or cgo compile.", config.Data().BuildTimeoutSeconds)
+	}
+
+	if _, ok := findMissingModules(stderr); ok {
+		return buildFailureMissingModule, fmt.Sprintf("Run `go mod tidy`, or set OnMissingModules to %q to run it automatically.", config.OnMissingModulesTidy)
+	}
+
+	if m := cgoCompilerMissing.FindStringSubmatch(stderr); m != nil {
+		compiler := m[1]
+		if compiler == "" {
+			compiler = m[2]
+		}
+		return buildFailureCgo, fmt.Sprintf("cgo needs a C compiler, install %s or set CGO_ENABLED=0 in fresher's environment to build without cgo.", compiler)
+	}
+
+	for _, b := range buildErrs {
+		if strings.HasPrefix(b.Message, "syntax error") {
+			return buildFailureSyntax, fmt.Sprintf("Fix the syntax error at %s:%d first, other errors in the package aren't reported until it is fixed.", b.File, b.Line)
+		}
+	}
+
+	for _, b := range buildErrs {
+		if name := strings.TrimPrefix(b.Message, "undefined: "); name != b.Message {
+			return buildFailureUndefined, fmt.Sprintf("%s is undefined, check for a typo, a missing import, or a build constraint (GoTags, _test.go, _windows.go) excluding the file that defines it.", name)
+		}
+	}
+
+	return buildFailureOther, ""
+}
+
+// pluralize returns n followed by word, adding an "s" when n is not 1.
+func pluralize(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+
+	return fmt.Sprintf("%d %ss", n, word)
+}
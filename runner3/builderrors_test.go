@@ -0,0 +1,67 @@
+package runner3
+
+import (
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestClassifyBuildFailure(t *testing.T) {
+	tests := []struct {
+		err    error
+		stderr string
+		kind   string
+	}{
+		{errGeneratorFailed, "", buildFailureGenerator},
+		{errBuildTimedOut, "", buildFailureTimeout},
+		{errBuildFailed, "main.go:4:2: no required module provides package example.com/x; to add it:\n\tgo get example.com/x\n", buildFailureMissingModule},
+		{errBuildFailed, "# runtime/cgo\ncgo: C compiler \"gcc\" not found: exec: \"gcc\": executable file not found in $PATH\n", buildFailureCgo},
+		{errBuildFailed, "exec: \"gcc\": executable file not found in $PATH\n", buildFailureCgo},
+		{errBuildFailed, "# app\n./main.go:10:2: syntax error: unexpected }\n./main.go:12:1: undefined: x\n", buildFailureSyntax},
+		{errBuildFailed, "# app\n./main.go:12:1: undefined: x\n", buildFailureUndefined},
+		{errBuildFailed, "# app\n./main.go:12:1: cannot use x (variable of type int) as string value\n", buildFailureOther},
+	}
+
+	for _, tt := range tests {
+		kind, hint := classifyBuildFailure(tt.err, tt.stderr, parseBuildErrors(tt.stderr))
+		if kind != tt.kind {
+			t.Fatal("Unexpected kind.", kind, tt.kind)
+			return
+		}
+		if (hint == "") != (kind == buildFailureOther || kind == buildFailureGenerator) {
+			t.Fatal("Unexpected hint.", kind, hint)
+			return
+		}
+	}
+}
+
+func TestReportBuildFailure(t *testing.T) {
+	config.UseDefaults()
+	repeatedFailure.output, repeatedFailure.count = "", 0
+
+	stderr := "# app\n./main.go:12:1: undefined: x\n"
+	reportBuildFailure(stderr, parseBuildErrors(stderr))
+	if isRepeatedFailure() {
+		t.Fatal("First failure should not be repeated.")
+		return
+	}
+
+	reportBuildFailure(stderr, parseBuildErrors(stderr))
+	reportBuildFailure(stderr, parseBuildErrors(stderr))
+	if !isRepeatedFailure() || repeatedFailure.count != 3 {
+		t.Fatal("Same failure should be counted.", repeatedFailure.count)
+		return
+	}
+
+	other := "# app\n./main.go:13:1: undefined: y\n"
+	reportBuildFailure(other, parseBuildErrors(other))
+	if isRepeatedFailure() {
+		t.Fatal("Different failure should not be repeated.")
+		return
+	}
+
+	if line := firstLine(stderr); line != "./main.go:12:1: undefined: x" {
+		t.Fatal("Unexpected first line.", line)
+		return
+	}
+}
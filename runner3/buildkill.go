@@ -0,0 +1,101 @@
+package runner3
+
+import (
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// runningBuild is the `go build` command that is running, if any. This is used to
+// kill the build when another file change event will just cause build() to run again.
+// There is no sense in completing the running build since build() will just be
+// called again immediately after completing. Killing off the running build just saves
+// a bit of time. The build is also killed if it takes longer than
+// BuildTimeoutSeconds.
+//
+// The command is set once it has started, and cleared once it has exited, under the
+// lock so that a build is never killed before it starts, a kill never applies to a
+// later build, and build() knows why the build was stopped.
+var runningBuild struct {
+	mu  sync.Mutex
+	cmd *exec.Cmd
+
+	//stopped is errBuildKilled or errBuildTimedOut once the build was killed.
+	stopped error
+}
+
+// startBuild starts the `go build` command and notes it as the running build so that
+// it can be killed by killBuild(). finishBuild() must be called once the command has
+// exited if no error is returned.
+func startBuild(cmd *exec.Cmd) error {
+	runningBuild.mu.Lock()
+	defer runningBuild.mu.Unlock()
+
+	err := startBuildCommand(cmd)
+	if err != nil {
+		return err
+	}
+
+	runningBuild.cmd = cmd
+	runningBuild.stopped = nil
+	return nil
+}
+
+// finishBuild clears the running build once the `go build` command has exited. The
+// reason the build was killed, errBuildKilled or errBuildTimedOut, is returned, or nil
+// if the build wasn't killed.
+func finishBuild() (stopped error) {
+	runningBuild.mu.Lock()
+	defer runningBuild.mu.Unlock()
+
+	stopped = runningBuild.stopped
+	runningBuild.cmd = nil
+	runningBuild.stopped = nil
+	return
+}
+
+// killBuild kills the running build, if any. True is returned if a build was killed.
+func killBuild() bool {
+	runningBuild.mu.Lock()
+	defer runningBuild.mu.Unlock()
+
+	if runningBuild.cmd == nil || runningBuild.stopped != nil {
+		return false
+	}
+
+	//Not using errs/warn/events logger here on purpose. I think it causes a panic
+	//when fresher is left running, a computer sleeps, and then wakes back up. Using
+	//log.Println() seems to alleviate the issue.
+	log.Println("Building...killed")
+	stopBuild(errBuildKilled)
+	return true
+}
+
+// timeoutBuild kills the build if cmd is still the running build, since it took
+// longer than BuildTimeoutSeconds. True is returned if the build was killed.
+func timeoutBuild(cmd *exec.Cmd) bool {
+	runningBuild.mu.Lock()
+	defer runningBuild.mu.Unlock()
+
+	if runningBuild.cmd != cmd || runningBuild.stopped != nil {
+		return false
+	}
+
+	log.Printf("Building...timed out after %s", time.Duration(config.Data().BuildTimeoutSeconds)*time.Second)
+	stopBuild(errBuildTimedOut)
+	return true
+}
+
+// stopBuild kills the running build's process and notes why. This must be called with
+// the lock held.
+func stopBuild(reason error) {
+	err := killProcessTree(runningBuild.cmd.Process)
+	if err != nil {
+		log.Printf("Killing build error %s", err)
+	}
+
+	runningBuild.stopped = reason
+}
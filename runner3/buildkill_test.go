@@ -0,0 +1,124 @@
+package runner3
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestKillBuild(t *testing.T) {
+	withoutOutput(t)
+	before := runtime.NumGoroutine()
+
+	//Nothing to kill when no build is running.
+	if killBuild() {
+		t.Fatal("No build is running, nothing should have been killed.")
+		return
+	}
+
+	cmd := helperCommand("sleep")
+	err := startBuild(cmd)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if !killBuild() {
+		t.Fatal("Running build should have been killed.")
+		return
+	}
+	if killBuild() {
+		t.Fatal("Build should only be killed once.")
+		return
+	}
+
+	cmd.Wait()
+	if finishBuild() != errBuildKilled {
+		t.Fatal("Build should be reported as killed.")
+		return
+	}
+
+	waitForGoroutines(t, before)
+}
+
+func TestKillBuildAfterFinish(t *testing.T) {
+	withoutOutput(t)
+
+	//A build that completes on its own isn't killed.
+	cmd := helperCommand("succeed")
+	err := startBuild(cmd)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	cmd.Wait()
+	if finishBuild() != nil {
+		t.Fatal("Build was not killed.")
+		return
+	}
+
+	//Killing once the build is finished must not affect the next build.
+	if killBuild() {
+		t.Fatal("No build is running, nothing should have been killed.")
+		return
+	}
+
+	cmd = helperCommand("succeed")
+	err = startBuild(cmd)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = cmd.Wait()
+	if finishBuild() != nil || err != nil {
+		t.Fatal("Next build should not have been killed.", err)
+		return
+	}
+}
+
+func TestStartBuildFails(t *testing.T) {
+	cmd := helperCommand("succeed")
+	cmd.Path = "/does/not/exist"
+	err := startBuild(cmd)
+	if err == nil {
+		t.Fatal("Build should not have started.")
+		return
+	}
+
+	//A build that didn't start isn't running, so it can't be killed.
+	if killBuild() {
+		t.Fatal("Build that failed to start should not be running.")
+		return
+	}
+}
+
+func TestTimeoutBuild(t *testing.T) {
+	withoutOutput(t)
+
+	cmd := helperCommand("sleep")
+	err := startBuild(cmd)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//A timer from an earlier build must not kill the running build.
+	if timeoutBuild(helperCommand("sleep")) {
+		t.Fatal("Build should not be timed out by another build's timer.")
+		return
+	}
+
+	if !timeoutBuild(cmd) {
+		t.Fatal("Running build should have timed out.")
+		return
+	}
+	if killBuild() {
+		t.Fatal("Build that timed out should not be killed again.")
+		return
+	}
+
+	cmd.Wait()
+	if stopped := finishBuild(); stopped != errBuildTimedOut {
+		t.Fatal("Build should be reported as timed out.", stopped)
+		return
+	}
+}
@@ -0,0 +1,51 @@
+package runner3
+
+import (
+	"os/exec"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+)
+
+// vcsStampable caches if the binary can be stamped with version control info, see
+// canStampVCS(). This is only checked once since neither the repo nor git being
+// installed change while fresher is running.
+var vcsStampable struct {
+	once sync.Once
+	ok   bool
+}
+
+// getBuildVCSFlag returns the -buildvcs flag provided to `go build` per GoBuildVCS, or
+// a blank string to use Go's default.
+func getBuildVCSFlag(cfg *config.File) string {
+	switch cfg.GoBuildVCS {
+	case config.GoBuildVCSTrue:
+		return "-buildvcs=true"
+	case config.GoBuildVCSFalse:
+		return "-buildvcs=false"
+	}
+
+	if !canStampVCS() {
+		return "-buildvcs=false"
+	}
+	return ""
+}
+
+// canStampVCS returns true if WorkingDir is in a git repo and git is installed. Go
+// fails to build, when a .git directory exists but git isn't installed, unless
+// -buildvcs=false is provided.
+func canStampVCS() bool {
+	vcsStampable.once.Do(func() {
+		if findGitDir(config.Data().WorkingDir) == "" {
+			return
+		}
+		if _, err := exec.LookPath("git"); err != nil {
+			warn.Verbosef("git not found, building with -buildvcs=false.")
+			return
+		}
+
+		vcsStampable.ok = true
+	})
+
+	return vcsStampable.ok
+}
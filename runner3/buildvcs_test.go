@@ -0,0 +1,39 @@
+package runner3
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestGetBuildVCSFlag(t *testing.T) {
+	tests := []struct {
+		buildVCS string
+		flag     string
+	}{
+		{config.GoBuildVCSTrue, "-buildvcs=true"},
+		{config.GoBuildVCSFalse, "-buildvcs=false"},
+
+		//Not in a git repo.
+		{config.GoBuildVCSAuto, "-buildvcs=false"},
+	}
+
+	for _, tt := range tests {
+		cfg := config.Defaults()
+		cfg.WorkingDir = t.TempDir()
+		cfg.GoBuildVCS = tt.buildVCS
+		err := config.Use(cfg)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		vcsStampable.once = sync.Once{}
+		vcsStampable.ok = false
+		if flag := getBuildVCSFlag(config.Data()); flag != tt.flag {
+			t.Fatal("Unexpected flag.", tt.buildVCS, flag)
+			return
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package runner3
+
+import "time"
+
+// clock provides timers and sleeping. This is an interface so that debouncing of file
+// change events, and the build delay, can be tested without waiting on real time.
+type clock interface {
+	NewTimer(d time.Duration) clockTimer
+	Sleep(d time.Duration)
+}
+
+// clockTimer is a timer from a clock, the same as a time.Timer.
+type clockTimer interface {
+	C() <-chan time.Time
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+// clk is the clock used by the package. Tests replace this with a fake clock.
+var clk clock = realClock{}
+
+// realClock is a clock using the time package.
+type realClock struct{}
+
+// NewTimer returns a time.Timer.
+func (realClock) NewTimer(d time.Duration) clockTimer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// Sleep calls time.Sleep.
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// realTimer wraps a time.Timer so that the channel is returned from a method, as
+// required by the clockTimer interface.
+type realTimer struct {
+	*time.Timer
+}
+
+// C returns the timer's channel.
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
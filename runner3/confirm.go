@@ -0,0 +1,83 @@
+package runner3
+
+import (
+	"os"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+	"github.com/mattn/go-isatty"
+)
+
+// rebuildConfirmation holds an event waiting for the user to confirm rebuilding since
+// more than ConfirmRebuildFiles files changed at once, i.e. by a mass find and replace.
+// The user confirms by typing "y" followed by enter, see watchKeypresses().
+type rebuildConfirmation struct {
+	mu sync.Mutex
+
+	//event is the event to send on the eventsChan if the rebuild is confirmed. This
+	//is nil when not waiting for confirmation.
+	event *fsnotify.Event
+}
+
+// confirming is the package level rebuild confirmation.
+var confirming rebuildConfirmation
+
+// needsConfirmation returns true if the event should wait for the user to confirm
+// rebuilding since too many files changed. The user is asked to confirm. Otherwise,
+// any event still waiting for confirmation is discarded since the event about to be
+// sent will rebuild with every change anyway.
+func (c *rebuildConfirmation) needsConfirmation(event fsnotify.Event, changedFiles int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	limit := config.Data().ConfirmRebuildFiles
+	if limit <= 0 || changedFiles <= limit || !isRebuildRequired(event) {
+		c.event = nil
+		return false
+	}
+
+	c.event = &event
+	warn.Printf("%d files changed, rebuild? [y/N]", changedFiles)
+	return true
+}
+
+// answer handles the user's answer to the rebuild confirmation. The event waiting for
+// confirmation is returned if the user typed "y". False is returned if not waiting
+// for confirmation so the answer can be handled as another command.
+func (c *rebuildConfirmation) answer(input string) (event *fsnotify.Event, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.event == nil {
+		return nil, false
+	}
+
+	event = c.event
+	c.event = nil
+	if input != "y" && input != "Y" {
+		events.Printf("Rebuild skipped.")
+		return nil, true
+	}
+
+	return event, true
+}
+
+// checkConfirmInput stops asking to confirm rebuilds, see ConfirmRebuildFiles, if
+// stdin isn't a terminal, i.e. fresher is run by a script or in a container without a
+// tty. The confirmation could never be answered so every large change would go
+// unbuilt.
+func checkConfirmInput() {
+	if config.Data().ConfirmRebuildFiles <= 0 {
+		return
+	}
+	if isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+		return
+	}
+
+	warn.Printf("WARNING! ConfirmRebuildFiles is set but stdin is not a terminal, rebuilding without asking.")
+	config.Update(func(cfg *config.File) error {
+		cfg.ConfirmRebuildFiles = 0
+		return nil
+	})
+}
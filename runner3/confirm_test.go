@@ -0,0 +1,30 @@
+package runner3
+
+import (
+	"os"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+	"github.com/mattn/go-isatty"
+)
+
+func TestCheckConfirmInput(t *testing.T) {
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		t.Skip("stdin is a terminal.")
+	}
+
+	cfg := config.Defaults()
+	cfg.ConfirmRebuildFiles = 2
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//The confirmation can't be answered, so rebuilds shouldn't wait for it.
+	checkConfirmInput()
+	if config.Data().ConfirmRebuildFiles != 0 {
+		t.Fatal("ConfirmRebuildFiles should be disabled when stdin is not a terminal.", config.Data().ConfirmRebuildFiles)
+		return
+	}
+}
@@ -0,0 +1,58 @@
+package runner3
+
+import (
+	gobuild "go/build"
+	"path/filepath"
+	"strings"
+
+	"github.com/c9845/fresher/config"
+)
+
+// isExcludedFromBuild returns true if the .go file isn't part of the build, meaning a
+// change to it can't change the binary, when SkipExcludedFiles is set. A file is
+// excluded by its name, i.e. file_windows.go when building on Linux or a _test.go
+// file, or by a //go:build line, i.e. a tag not in GoTags.
+//
+// A file that can't be read, i.e. it was removed, isn't excluded unless its name
+// excludes it since the file's //go:build line isn't known.
+func isExcludedFromBuild(path string) bool {
+	if !config.Data().SkipExcludedFiles || filepath.Ext(path) != ".go" {
+		return false
+	}
+	if strings.HasSuffix(path, "_test.go") {
+		return true
+	}
+
+	ctx := buildContext(getBuildEnv(config.Data()))
+	match, err := ctx.MatchFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return false
+	}
+
+	return !match
+}
+
+// buildContext returns the build context the binary is built with, fresher's own
+// environment unless env, from getBuildEnv(), sets GOOS, GOARCH, or CGO_ENABLED, and
+// the tags from GoTags, or the build config in use. Tags can be comma or space
+// separated, the same as the -tags flag.
+func buildContext(env []string) gobuild.Context {
+	ctx := gobuild.Default
+	for _, e := range env {
+		k, v, _ := strings.Cut(e, "=")
+		switch k {
+		case "GOOS":
+			ctx.GOOS = v
+		case "GOARCH":
+			ctx.GOARCH = v
+		case "CGO_ENABLED":
+			ctx.CgoEnabled = v == "1"
+		}
+	}
+
+	ctx.BuildTags = strings.FieldsFunc(config.Data().BuildTags(), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	return ctx
+}
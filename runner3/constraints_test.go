@@ -0,0 +1,87 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestIsExcludedFromBuild(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":    "package main\n",
+		"debug.go":   "//go:build debug\n\npackage main\n",
+		"nodebug.go": "//go:build !debug\n\npackage main\n",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	//A file for an OS other than the one being built for.
+	other := "windows"
+	if runtime.GOOS == "windows" {
+		other = "linux"
+	}
+
+	cfg := config.Defaults()
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	tests := []struct {
+		name     string
+		excluded bool
+	}{
+		{"main.go", false},
+		{"main_test.go", true},
+		{"file_" + other + ".go", true},
+		{"file_" + runtime.GOOS + ".go", false},
+		{"debug.go", true},
+		{"nodebug.go", false},
+		{"removed.go", false},
+		{"index.html", false},
+	}
+	for _, tt := range tests {
+		if excluded := isExcludedFromBuild(filepath.Join(dir, tt.name)); excluded != tt.excluded {
+			t.Fatal("Unexpected result for", tt.name, excluded)
+			return
+		}
+	}
+
+	//The tags in GoTags should be used.
+	cfg.GoTags = "debug,other"
+	err = config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if isExcludedFromBuild(filepath.Join(dir, "debug.go")) {
+		t.Fatal("File with a tag in GoTags should not be excluded.")
+		return
+	}
+	if !isExcludedFromBuild(filepath.Join(dir, "nodebug.go")) {
+		t.Fatal("File excluding a tag in GoTags should be excluded.")
+		return
+	}
+
+	//Nothing is excluded if disabled.
+	cfg.SkipExcludedFiles = false
+	err = config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if isExcludedFromBuild(filepath.Join(dir, "main_test.go")) {
+		t.Fatal("Nothing should be excluded when SkipExcludedFiles is disabled.")
+		return
+	}
+}
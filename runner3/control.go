@@ -0,0 +1,311 @@
+package runner3
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Events sent on the eventsChan by the control API, a signal, the trigger file, or
+// when fresher starts, rather than by a watched file changing. These names are not
+// real files; see isRebuildRequired() for how these are handled.
+const (
+	rebuildEventName    = "(rebuild requested)"
+	restartEventName    = "(restart requested)"
+	initialEventName    = "(initial build)"
+	initialRunEventName = "(initial run)"
+)
+
+// isRebuildRequired returns true if the event requires the binary to be rebuilt, not
+// just rerun. A rebuild is required unless the file that changed has an extension
+// listed in NoRebuildExtensions or the event is a request to just restart the binary.
+// A file embedded in the binary, see isEmbeddedFile(), always requires a rebuild.
+func isRebuildRequired(event fsnotify.Event) bool {
+	if event.Name == restartEventName || event.Name == autoRestartEventName || event.Name == initialRunEventName {
+		return false
+	}
+	if isEmbeddedFile(event.Name) {
+		return true
+	}
+
+	return config.Data().IsRebuildExtension(filepath.Ext(event.Name))
+}
+
+// requestEvent sends an event with the given name, i.e. rebuildEventName when a
+// rebuild is requested via the control API, see sendEvent().
+func requestEvent(eventName string) {
+	sendEvent(fsnotify.Event{
+		Name: eventName,
+		Op:   fsnotify.Write,
+	})
+}
+
+// sendEvent sends an event on the eventsChan without blocking the caller while a build
+// is running. The running build is killed if the event will just cause another build,
+// the same as when a file changes, see flush().
+func sendEvent(event fsnotify.Event) {
+	select {
+	case eventsChan <- event:
+	default:
+		//An event is already waiting to be handled, i.e. a build is running. Send
+		//once the event can be received so that this request isn't lost.
+		go func() {
+			eventsChan <- event
+		}()
+	}
+
+	if isRebuildRequired(event) {
+		killBuild()
+	}
+}
+
+// serveControl starts a server that allows other tools, such as an editor plugin or
+// a tmux status line, to control and query fresher. This is only started if the
+// ControlAddress is set in the config.
+//
+// The server listens on a Unix socket if ControlAddress starts with "unix:", for
+// example "unix:tmp/fresher.sock", otherwise on a TCP host:port.
+//
+// Endpoints:
+//   - POST /rebuild: rebuild and rerun the binary.
+//   - POST /restart: rerun the binary without rebuilding.
+//   - POST /pause: stop handling file changes, see watchPause.
+//   - POST /resume: resume handling file changes.
+//   - GET /status: JSON describing if a build is running, if the binary is running,
+//     the last build, and the last build error.
+//   - GET /logs: streams fresher's logging, and the binary's output, as it happens.
+//   - GET /watch-stats: JSON describing the number of directories watched and ignored.
+//   - POST /reload-config: reread the config file.
+//   - GET /build-config: JSON listing the build configs and the one in use.
+//   - POST /build-config?name=: switch to the named build config, or the next one if
+//     no name is given, and rebuild. See BuildConfigs.
+func serveControl() (err error) {
+	addr := config.Data().ControlAddress
+	if addr == "" {
+		return
+	}
+
+	//Get the listener. A stale socket file, from a previous fresher that didn't exit
+	//cleanly, is removed since it would cause listening to fail.
+	var ln net.Listener
+	if strings.HasPrefix(addr, "unix:") {
+		socketPath := strings.TrimPrefix(addr, "unix:")
+		os.Remove(socketPath)
+		ln, err = net.Listen("unix", socketPath)
+	} else {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rebuild", handleControlEvent(rebuildEventName))
+	mux.HandleFunc("/restart", handleControlEvent(restartEventName))
+	mux.HandleFunc("/pause", handleControlPause(pauseWatching))
+	mux.HandleFunc("/resume", handleControlPause(resumeWatching))
+	mux.HandleFunc("/status", handleControlStatus)
+	mux.HandleFunc("/logs", handleControlLogs)
+	mux.HandleFunc("/watch-stats", handleControlWatchStats)
+	mux.HandleFunc("/reload-config", handleControlReloadConfig)
+	mux.HandleFunc("/build-config", handleControlBuildConfig)
+
+	//Copy logging to any clients streaming logs.
+	logger.SetOutput(io.MultiWriter(logger.Writer(), logStream))
+	childStdout = io.MultiWriter(childStdout, logStream)
+	childStderr = io.MultiWriter(childStderr, logStream)
+
+	events.Printf("Control API listening at %s", addr)
+
+	go func() {
+		err := http.Serve(ln, mux)
+		if err != nil {
+			//Not exiting on error since the control API is not required for building
+			//and running the binary.
+			errs.Printf("Control API error %s", err)
+		}
+	}()
+
+	return
+}
+
+// handleControlEvent returns an http.HandlerFunc that sends an event, with the given
+// name, to cause the binary to be rebuilt and/or rerun.
+func handleControlEvent(eventName string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed, use POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		requestEvent(eventName)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// handleControlPause returns an http.HandlerFunc that pauses or resumes handling file
+// changes by calling fn.
+func handleControlPause(fn func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed, use POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		fn()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleControlStatus responds with the current status of fresher as JSON.
+func handleControlStatus(w http.ResponseWriter, r *http.Request) {
+	s := status.snapshot()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&s)
+}
+
+// handleControlWatchStats responds with the watcher stats as JSON. The summary is
+// also logged so that it shows up in the terminal fresher is running in.
+func handleControlWatchStats(w http.ResponseWriter, r *http.Request) {
+	s := watching.snapshot()
+	events.Printf("%s", watching.summary())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&s)
+}
+
+// handleControlReloadConfig rereads the config file so that changes take effect
+// without restarting fresher. The error is returned, and the config in use is kept,
+// if the config file is invalid.
+func handleControlReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed, use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := config.Reload()
+	if err != nil {
+		errs.Printf("Could not reload config %s", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events.Printf("Config reloaded.")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleControlBuildConfig responds with the build configs, and the one in use, as
+// JSON or switches build configs, see switchBuildConfig().
+func handleControlBuildConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		cfg := config.Data()
+		resp := struct {
+			Active    string   `json:"active"`
+			Available []string `json:"available"`
+		}{cfg.BuildConfig, cfg.BuildConfigNames()}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&resp)
+
+	case http.MethodPost:
+		err := switchBuildConfig(r.URL.Query().Get("name"))
+		if err != nil {
+			errs.Printf("Could not switch build config. %s", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		http.Error(w, "method not allowed, use GET or POST", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleControlLogs streams logging to the client until the client disconnects.
+func handleControlLogs(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := logStream.subscribe()
+	defer logStream.unsubscribe(ch)
+
+	for {
+		select {
+		case b := <-ch:
+			_, err := w.Write(b)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// logBroadcaster is an io.Writer that copies everything written to it to each
+// subscriber. This is used to stream logging to clients of the control API.
+type logBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan []byte]bool
+}
+
+// logStream is the broadcaster logging is copied to for streaming via the control API.
+var logStream = &logBroadcaster{subscribers: map[chan []byte]bool{}}
+
+// Write implements io.Writer. Writes are never blocked by a slow subscriber; if a
+// subscriber isn't keeping up, data is dropped for that subscriber.
+func (l *logBroadcaster) Write(p []byte) (n int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for ch := range l.subscribers {
+		//Copy since the caller may reuse p after Write returns.
+		b := make([]byte, len(p))
+		copy(b, p)
+
+		select {
+		case ch <- b:
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+// subscribe returns a channel that will receive everything written to the
+// broadcaster.
+func (l *logBroadcaster) subscribe() chan []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ch := make(chan []byte, 100)
+	l.subscribers[ch] = true
+	return ch
+}
+
+// unsubscribe stops sending to the channel.
+func (l *logBroadcaster) unsubscribe(ch chan []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.subscribers, ch)
+}
@@ -0,0 +1,43 @@
+package runner3
+
+import (
+	"testing"
+	"time"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestSendEventDoesNotBlock(t *testing.T) {
+	config.UseDefaults()
+
+	//Fill the channel, as if a build is running and another event is waiting.
+	eventsChan <- fsnotify.Event{Name: "main.go", Op: fsnotify.Write}
+
+	done := make(chan bool)
+	go func() {
+		requestEvent(rebuildEventName)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Sending an event should not block.")
+		return
+	}
+
+	//Both events should be received, the request is not dropped.
+	for _, name := range []string{"main.go", rebuildEventName} {
+		select {
+		case e := <-eventsChan:
+			if e.Name != name {
+				t.Fatal("Unexpected event.", e.Name, name)
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Event not received.", name)
+			return
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package runner3
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// crossChecking tracks the `go build` compiling the binary for a CrossCheckTargets
+// platform, if one is running. This is used to stop cross checking when a build
+// starts since the results would be out of date.
+var crossChecking struct {
+	mu sync.Mutex
+
+	//cmd is the running command, nil once it exits.
+	cmd *exec.Cmd
+
+	//run is incremented each time cross checking starts, or is stopped, so that a
+	//previous run stops checking the rest of its targets.
+	run int
+
+	//failing is the output of targets that failed the last time they were checked,
+	//used to note when a target is fixed and to not repeat the same errors.
+	failing map[string]string
+}
+
+// getCrossCheckEnv returns the environment `go build` is run with to cross check a
+// target. This is the same as when building the binary, including the build config's
+// Env, with the target's GOOS and GOARCH added last so that they are used.
+func getCrossCheckEnv(target string) []string {
+	cfg := config.Data()
+	return append(withBuildConfigEnv(cfg, withGoCacheEnv(withGitEnv(cfg, os.Environ()))), crossCheckEnv(target)...)
+}
+
+// crossCheckEnv returns the GOOS and GOARCH environment variables to build for the
+// target, GOOS or GOOS/GOARCH. GOARCH defaults to the current GOARCH, except for
+// platforms that only support wasm.
+func crossCheckEnv(target string) []string {
+	goos, goarch, found := strings.Cut(target, "/")
+	if !found {
+		goarch = runtime.GOARCH
+		if goos == "js" || goos == "wasip1" {
+			goarch = "wasm"
+		}
+	}
+
+	return []string{"GOOS=" + goos, "GOARCH=" + goarch}
+}
+
+// getCrossCheckArgs returns the arguments passed to "go" to compile the binary for a
+// CrossCheckTargets platform. The binary is discarded since it is never run.
+func getCrossCheckArgs() []string {
+	args := []string{"build", "-o", os.DevNull}
+	args = append(args, getBuildFlags(config.Data())...)
+	return append(args, config.Data().EntryPoint)
+}
+
+// startCrossCheck compiles the binary for each of the CrossCheckTargets, one at a
+// time in the background, after a successful build. Any cross check still running
+// from a previous build is stopped first. Nothing is done if no targets are set.
+func startCrossCheck() {
+	targets := config.Data().CrossCheckTargets
+	if len(targets) == 0 {
+		return
+	}
+
+	stopCrossCheck()
+
+	crossChecking.mu.Lock()
+	run := crossChecking.run
+	crossChecking.mu.Unlock()
+
+	go func() {
+		for _, target := range targets {
+			if !crossCheck(run, target) {
+				return
+			}
+		}
+	}()
+}
+
+// crossCheck compiles the binary for a single target and logs the result. False is
+// returned if cross checking was stopped, meaning the remaining targets shouldn't be
+// checked.
+func crossCheck(run int, target string) bool {
+	args := getCrossCheckArgs()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = config.Data().WorkingDir
+	cmd.Env = getCrossCheckEnv(target)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	crossChecking.mu.Lock()
+	if run != crossChecking.run {
+		crossChecking.mu.Unlock()
+		return false
+	}
+	events.Verbosef("Cross checking %s... %s", target, formatCommand("go", args))
+	start := time.Now()
+	err := startBuildCommand(cmd)
+	if err != nil {
+		crossChecking.mu.Unlock()
+		warn.Printf("Could not cross check %s %s", target, err)
+		return true
+	}
+	crossChecking.cmd = cmd
+	crossChecking.mu.Unlock()
+
+	err = cmd.Wait()
+
+	crossChecking.mu.Lock()
+	defer crossChecking.mu.Unlock()
+	crossChecking.cmd = nil
+	if run != crossChecking.run {
+		return false
+	}
+	if crossChecking.failing == nil {
+		crossChecking.failing = map[string]string{}
+	}
+
+	if err == nil {
+		if _, failed := crossChecking.failing[target]; failed {
+			events.Printf("Cross check %s fixed.", target)
+		} else {
+			events.Verbosef("Cross check %s passed in %s.", target, time.Since(start).Round(time.Millisecond))
+		}
+		delete(crossChecking.failing, target)
+		return true
+	}
+
+	output := stderr.String()
+	if crossChecking.failing[target] == output {
+		events.Verbosef("Cross check %s failed, same errors as before.", target)
+		return true
+	}
+	crossChecking.failing[target] = output
+
+	buildErrs := parseBuildErrors(output)
+	if len(buildErrs) == 0 {
+		errs.Printf("Cross check %s failed: %s", target, firstLine(output))
+		return true
+	}
+	for _, b := range buildErrs {
+		errs.Printf("Cross check %s failed: %s", target, b.String())
+	}
+	return true
+}
+
+// stopCrossCheck stops cross checking, if it is running, so that it doesn't compete
+// with building the binary.
+func stopCrossCheck() {
+	crossChecking.mu.Lock()
+	defer crossChecking.mu.Unlock()
+
+	crossChecking.run++
+	if crossChecking.cmd != nil {
+		killProcessTree(crossChecking.cmd.Process)
+	}
+}
@@ -0,0 +1,47 @@
+package runner3
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestCrossCheckEnv(t *testing.T) {
+	tests := []struct {
+		target string
+		env    []string
+	}{
+		{"windows", []string{"GOOS=windows", "GOARCH=" + runtime.GOARCH}},
+		{"linux/arm64", []string{"GOOS=linux", "GOARCH=arm64"}},
+		{"js", []string{"GOOS=js", "GOARCH=wasm"}},
+		{"wasip1", []string{"GOOS=wasip1", "GOARCH=wasm"}},
+	}
+	for _, tt := range tests {
+		if env := crossCheckEnv(tt.target); !reflect.DeepEqual(env, tt.env) {
+			t.Fatal("Unexpected environment.", tt.target, env)
+			return
+		}
+	}
+}
+
+func TestGetCrossCheckEnv(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.BuildConfigs = []config.BuildConfig{
+		{Name: "sqlite", Env: map[string]string{"CGO_ENABLED": "1", "GOOS": "linux"}},
+	}
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//The build config's Env is used, but the target's GOOS is last so it wins.
+	env := getCrossCheckEnv("windows")
+	n := len(env)
+	if n < 4 || env[n-4] != "CGO_ENABLED=1" || env[n-3] != "GOOS=linux" || env[n-2] != "GOOS=windows" {
+		t.Fatal("Unexpected environment.", env)
+		return
+	}
+}
@@ -0,0 +1,337 @@
+package runner3
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// debounceDelay is how long to wait after a file change event for more events before
+// sending the last event on the eventsChan.
+const debounceDelay = 50 * time.Millisecond
+
+// debouncer filters file change events from the watcher and coalesces events that
+// occur in quick succession into a single event sent on the eventsChan.
+//
+// This handles double-save events that can sometimes occur. Usually due to "save new
+// file and rename" method of saving files by text editors/OSes. This is particularly
+// helpful on Windows as duplicate events occur for each file save a human initiates.
+//
+// This works by resetting a timer when a file change event occurs. While the timer is
+// running, before it expires, other file change events are still received. However,
+// only the last event is "remembered". After the timer expires, flush() is called and
+// the "remembered" last event is sent on the events channel causing the rebuild
+// and/or rerun to occur.
+//
+// Taken from: https://github.com/fsnotify/fsnotify/issues/122#issuecomment-1065925569
+//
+// Events are handled, and the timer expiring is handled, by calling handleEvent() and
+// flush() from a single goroutine, see Watch(). Tests call these directly, with a
+// fake clock, so that coalescing can be tested without waiting on real timers.
+type debouncer struct {
+	//timer is reset each time an event is handled. flush() should be called when
+	//the timer expires.
+	timer clockTimer
+
+	//native is true when the native, recursive, watcher is used since it sends
+	//events for files in ignored directories.
+	native bool
+
+	//lastEvent is the event sent when the timer expires.
+	lastEvent fsnotify.Event
+
+	//Track the .go files written to since the last event was sent, and if any
+	//other change occured, so that the event isn't sent if only comments in .go
+	//files changed. See SkipCommentOnlyChanges.
+	changedGoFiles map[string]bool
+	otherChange    bool
+
+	//gitOperation is true while a git operation, i.e. switching branches, is
+	//changing files. Events are coalesced, with a longer delay, into a single
+	//gitEventName event. See WatchGit.
+	gitOperation bool
+
+	//changed is true once an event that should be sent, lastEvent, was handled since
+	//the last event was sent. This is false when a git operation only locked the
+	//index, see gitLockEventName.
+	changed bool
+
+	//pending is the number of events handled since the last event was sent, used
+	//to detect a bulk change, see BulkChangeEvents. bulkChange is true once
+	//detected so that it is only logged once.
+	pending    int
+	bulkChange bool
+
+	//files are the distinct files changed since the last event was sent, used to
+	//ask before rebuilding when many files changed, see ConfirmRebuildFiles.
+	files map[string]bool
+}
+
+// watchEvents handles events, and errors, from the watcher until the events channel
+// is closed. The watcher is passed as channels so that tests can send events without
+// watching real files.
+func watchEvents(fileEvents <-chan fsnotify.Event, watchErrors <-chan error, d *debouncer) {
+	for {
+		select {
+		case err := <-watchErrors:
+			if err != nil {
+				errs.Printf("watcher error %s", err)
+			}
+
+		case event, ok := <-fileEvents:
+			if !ok {
+				return
+			}
+			d.handleEvent(event)
+
+		case <-d.timer.C():
+			d.flush()
+		}
+	}
+}
+
+// newDebouncer returns a debouncer with a stopped timer from the clock.
+func newDebouncer(c clock, native bool) *debouncer {
+	timer := c.NewTimer(debounceDelay)
+	timer.Stop()
+
+	return &debouncer{
+		timer:          timer,
+		native:         native,
+		changedGoFiles: map[string]bool{},
+		files:          map[string]bool{},
+	}
+}
+
+// handleEvent handles a file change event from the watcher. Events that don't cause
+// a rebuild or rerun are ignored. Otherwise, the event is remembered and the timer is
+// reset to catch more events.
+func (d *debouncer) handleEvent(event fsnotify.Event) {
+	events.Tracef("Event... %s (%s)", event.Name, event.Op.String())
+
+	//Ignore event on certain events.
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+
+	//A git operation changes many files at once. Wait for the operation to complete
+	//and rebuild once rather than rebuilding for each changed file.
+	if event.Name == gitEventName || event.Name == gitLockEventName {
+		if !d.gitOperation {
+			events.Printf("Git operation detected, waiting for it to complete...")
+		}
+		d.gitOperation = true
+		if event.Name == gitEventName {
+			d.otherChange = true
+			d.changed = true
+			d.lastEvent = event
+		}
+		d.timer.Reset(gitSettleDelay)
+		return
+	}
+
+	//Always rebuild when the trigger file is touched, regardless of
+	//its extension.
+	if isTriggerFile(event.Name) {
+		d.otherChange = true
+		d.changed = true
+		d.lastEvent = fsnotify.Event{Name: rebuildEventName, Op: fsnotify.Write}
+		d.timer.Reset(debounceDelay)
+		return
+	}
+
+	//Skip sending event if the operation isn't one to react to, i.e.:
+	//file was removed but "remove" isn't in EventOps.
+	if !isEventOpToWatch(event.Op) {
+		events.Tracef("Ignoring event op %s %s", event.Op.String(), event.Name)
+		return
+	}
+
+	//Skip sending event if an editor's temporary file is changed. This
+	//is checked first since the temporary file may have a watched
+	//extension, i.e.: main.go~.
+	if config.Data().IsEditorTempFile(event.Name) {
+		events.Tracef("Ignoring editor temp file %s", event.Name)
+		return
+	}
+
+	//Check for vulnerabilities when dependencies change. This is
+	//checked before the extension since go.mod and go.sum usually
+	//aren't watched extensions.
+	vulnCheck.queue(event.Name)
+
+	//Skip sending event if a non-watched file is changed. Embedded files
+	//are always watched since the binary must be rebuilt to see them.
+	if !config.Data().IsExtensionToWatch(filepath.Ext(event.Name)) && !isEmbeddedFile(event.Name) {
+		return
+	}
+
+	//The native watcher is recursive so it sends events for files in
+	//ignored directories as well.
+	if d.native && isInIgnoredDirectory(event.Name) {
+		return
+	}
+
+	//Make sure the next rescan doesn't report this change again.
+	rescan.noteEvent(event.Name)
+
+	//Skip .go files that aren't part of the build, i.e. file_windows.go
+	//when building on Linux, since the binary wouldn't change.
+	if isExcludedFromBuild(event.Name) {
+		events.Verbosef("Skipping %s, excluded from the build by build constraints", event.Name)
+		return
+	}
+
+	//Skip files in modules the binary doesn't use, i.e. another service's module
+	//in a monorepo.
+	if isInUnrelatedModule(event.Name) {
+		events.Verbosef("Skipping %s, in a module the binary doesn't use", event.Name)
+		return
+	}
+
+	//Run asset commands for front end assets, i.e.: bundling .ts files.
+	//These files don't affect the binary so it isn't rebuilt or
+	//restarted.
+	if assets.queue(event.Name) {
+		return
+	}
+
+	//Keep track of the changes since the last successful build. Every
+	//event is recorded here, not just the last event that is sent
+	//below, so that no changed file is missed.
+	if isRebuildRequired(event) {
+		status.recordChange(filepath.Clean(event.Name))
+		d.files[filepath.Clean(event.Name)] = true
+	}
+
+	//Note generators to run, for code generation, before rebuilding.
+	generators.queue(event.Name)
+
+	//Note if a .go file's content changed, rather than being removed or
+	//renamed, to check if only comments changed before sending the
+	//event. A create is included since editors often save by renaming
+	//a temp file over the original file.
+	if filepath.Ext(event.Name) == ".go" && (event.Op.Has(fsnotify.Write) || event.Op.Has(fsnotify.Create)) {
+		d.changedGoFiles[filepath.Clean(event.Name)] = true
+	} else {
+		d.otherChange = true
+	}
+
+	//Keep waiting for the git operation to complete, the rebuild is done once the
+	//operation's events stop.
+	if d.gitOperation {
+		d.changed = true
+		d.lastEvent = fsnotify.Event{Name: gitEventName, Op: fsnotify.Write}
+		d.timer.Reset(gitSettleDelay)
+		return
+	}
+
+	//Store the event and wait a short while to catch duplicate events.
+	d.changed = true
+	d.lastEvent = event
+	d.pending++
+	d.timer.Reset(d.delay())
+}
+
+// delay returns how long to wait for more events before sending the last event. This
+// is debounceDelay unless more than BulkChangeEvents events were handled since the
+// last event was sent, i.e. a formatter rewriting every file, in which case the delay
+// is stretched to BulkDelayMilliseconds so that the binary is built once the changes
+// stop, rather than starting and killing builds as each file changes.
+func (d *debouncer) delay() time.Duration {
+	bulkDelay := config.Data().BulkDelayMilliseconds
+	if bulkDelay <= 0 || d.pending <= config.Data().BulkChangeEvents {
+		return debounceDelay
+	}
+
+	if !d.bulkChange {
+		events.Printf("Bulk change detected (%d events), waiting for changes to stop...", d.pending)
+		d.bulkChange = true
+	}
+	return time.Duration(bulkDelay) * time.Millisecond
+}
+
+// flush sends the last event on the eventsChan, once the timer expires, and kills the
+// running build if the event will just cause another build.
+func (d *debouncer) flush() {
+	//Once a git operation completes, watch any directories it created so that
+	//changes to files in them cause a rebuild.
+	if d.gitOperation {
+		if isGitBusy() {
+			d.timer.Reset(gitSettleDelay)
+			return
+		}
+
+		d.gitOperation = false
+		if git.watchNewDirectories != nil {
+			git.watchNewDirectories()
+		}
+
+		//The git operation only locked the index, i.e. `git add`, nothing to
+		//rebuild.
+		if !d.changed {
+			events.Verbosef("Git operation completed, no files changed.")
+			return
+		}
+	}
+	d.changed = false
+
+	d.pending = 0
+	d.bulkChange = false
+	changedFiles := len(d.files)
+	d.files = map[string]bool{}
+
+	eventName := d.lastEvent.Name
+	eventType := d.lastEvent.Op.String()
+
+	//The changes are reset regardless of SkipCommentOnlyChanges so that they don't
+	//build up for the whole session when it isn't set.
+	changedGoFiles, otherChange := d.changedGoFiles, d.otherChange
+	d.changedGoFiles = map[string]bool{}
+	d.otherChange = false
+
+	//Skip rebuilding if only comments changed. Fingerprints are always
+	//updated so that they match the code that was last built.
+	if config.Data().SkipCommentOnlyChanges {
+		commentsOnly := goFingerprints.onlyCommentsChanged(changedGoFiles)
+		skip := commentsOnly && !otherChange
+
+		if skip {
+			events.Printf("Only comments changed, skipping rebuild... %s", eventName)
+			return
+		}
+	}
+
+	//Hold the event, rather than rebuilding, while watching is paused. The event is
+	//sent once watching is resumed, see resumeWatching().
+	if pausing.hold(d.lastEvent) {
+		events.Verbosef("Watching paused, holding event... %s (%s)", eventName, eventType)
+		return
+	}
+
+	//Ask before rebuilding if many files changed at once, in case the changes were
+	//made by accident.
+	if confirming.needsConfirmation(d.lastEvent, changedFiles) {
+		return
+	}
+
+	events.Verbosef("Sending Event... %s (%s)", eventName, eventType)
+
+	//Cause binary to be rebuilt and/or rerun.
+	eventsChan <- d.lastEvent
+
+	//Check if binary is currently being built and stop the build if this
+	//event will just result in a rebuild. This saves a bit of time since
+	//we don't build the binary twice (once is ongoing and again for the
+	//new event) and have to wait for the first build to complete before
+	//the second build starts.
+	//
+	//This is not checked in start() since start blocks when build() is
+	//running and thus will not be able to receive a new event until build()
+	//is complete, therefore building can never be killed!
+	if isRebuildRequired(d.lastEvent) {
+		killBuild()
+	}
+}
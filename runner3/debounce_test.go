@@ -0,0 +1,375 @@
+package runner3
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// newTestDebouncer returns a debouncer using a fake clock, with the default config.
+func newTestDebouncer(t *testing.T) (*debouncer, *fakeClock) {
+	config.UseDefaults()
+
+	c := &fakeClock{}
+	return newDebouncer(c, false), c
+}
+
+// receiveEvent returns the event sent on the eventsChan, if any.
+func receiveEvent() (fsnotify.Event, bool) {
+	select {
+	case e := <-eventsChan:
+		return e, true
+	default:
+		return fsnotify.Event{}, false
+	}
+}
+
+func TestDebouncerCoalescesEvents(t *testing.T) {
+	d, c := newTestDebouncer(t)
+
+	d.handleEvent(fsnotify.Event{Name: "a.go", Op: fsnotify.Write})
+	d.handleEvent(fsnotify.Event{Name: "b.go", Op: fsnotify.Write})
+
+	//Nothing should be sent until the timer expires.
+	c.Advance(debounceDelay - time.Millisecond)
+	select {
+	case <-d.timer.C():
+		t.Fatal("Timer should not have expired yet.")
+		return
+	default:
+	}
+
+	c.Advance(time.Millisecond)
+	select {
+	case <-d.timer.C():
+		d.flush()
+	default:
+		t.Fatal("Timer should have expired.")
+		return
+	}
+
+	//Only the last event should be sent.
+	e, ok := receiveEvent()
+	if !ok || e.Name != "b.go" {
+		t.Fatal("Last event should have been sent.", e, ok)
+		return
+	}
+	if e, ok := receiveEvent(); ok {
+		t.Fatal("Only one event should have been sent.", e)
+		return
+	}
+}
+
+func TestDebouncerIgnoresEvents(t *testing.T) {
+	d, _ := newTestDebouncer(t)
+
+	ignored := []fsnotify.Event{
+		{Name: "main.go", Op: fsnotify.Chmod},
+		{Name: "notes.txt", Op: fsnotify.Write},
+		{Name: "main.go~", Op: fsnotify.Write},
+	}
+	for _, e := range ignored {
+		d.handleEvent(e)
+		if d.timer.(*fakeTimer).isActive() {
+			t.Fatal("Event should have been ignored.", e)
+			return
+		}
+	}
+}
+
+func TestDebouncerKillsBuild(t *testing.T) {
+	d, _ := newTestDebouncer(t)
+	withoutOutput(t)
+
+	//A helper process acts as the running build.
+	cmd := helperCommand("sleep")
+	err := startBuild(cmd)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer func() {
+		killBuild()
+		cmd.Wait()
+		finishBuild()
+	}()
+
+	//A file that doesn't require a rebuild shouldn't kill the running build.
+	d.handleEvent(fsnotify.Event{Name: "index.html", Op: fsnotify.Write})
+	d.flush()
+	receiveEvent()
+	if runningBuild.stopped != nil {
+		t.Fatal("Build should not have been killed for a file that doesn't require a rebuild.")
+		return
+	}
+
+	//A .go file will just cause another build so the running build is killed.
+	d.handleEvent(fsnotify.Event{Name: "main.go", Op: fsnotify.Write})
+	d.flush()
+	receiveEvent()
+	cmd.Wait()
+	if finishBuild() != errBuildKilled {
+		t.Fatal("Build should have been killed.")
+		return
+	}
+}
+
+func TestDebouncerGitOperation(t *testing.T) {
+	d, c := newTestDebouncer(t)
+
+	//Files changed during a git operation should be coalesced into a single event,
+	//sent once events stop for gitSettleDelay.
+	d.handleEvent(fsnotify.Event{Name: gitEventName, Op: fsnotify.Write})
+	d.handleEvent(fsnotify.Event{Name: "a.go", Op: fsnotify.Write})
+	c.Advance(debounceDelay)
+	d.handleEvent(fsnotify.Event{Name: "b.go", Op: fsnotify.Remove})
+
+	c.Advance(gitSettleDelay - time.Millisecond)
+	select {
+	case <-d.timer.C():
+		t.Fatal("Timer should not have expired yet.")
+		return
+	default:
+	}
+
+	c.Advance(time.Millisecond)
+	<-d.timer.C()
+	d.flush()
+
+	e, ok := receiveEvent()
+	if !ok || e.Name != gitEventName {
+		t.Fatal("Git operation event should have been sent.", e, ok)
+		return
+	}
+	if e, ok := receiveEvent(); ok {
+		t.Fatal("Only one event should have been sent.", e)
+		return
+	}
+
+	//Events after the git operation should be handled as usual.
+	d.handleEvent(fsnotify.Event{Name: "c.go", Op: fsnotify.Write})
+	c.Advance(debounceDelay)
+	<-d.timer.C()
+	d.flush()
+	if e, _ := receiveEvent(); e.Name != "c.go" {
+		t.Fatal("Event after git operation should have been sent.", e)
+		return
+	}
+}
+
+func TestDebouncerGitLock(t *testing.T) {
+	d, c := newTestDebouncer(t)
+
+	//Locking the index starts a git operation, files changed by the operation,
+	//before HEAD is written, are coalesced into a single event.
+	d.handleEvent(fsnotify.Event{Name: gitLockEventName, Op: fsnotify.Create})
+	d.handleEvent(fsnotify.Event{Name: "a.go", Op: fsnotify.Write})
+	c.Advance(debounceDelay)
+	d.handleEvent(fsnotify.Event{Name: "b.go", Op: fsnotify.Write})
+	c.Advance(gitSettleDelay)
+	<-d.timer.C()
+	d.flush()
+
+	if e, ok := receiveEvent(); !ok || e.Name != gitEventName {
+		t.Fatal("Git operation event should have been sent.", e, ok)
+		return
+	}
+	if e, ok := receiveEvent(); ok {
+		t.Fatal("Only one event should have been sent.", e)
+		return
+	}
+
+	//Locking the index without changing any files, i.e. `git add`, should not
+	//cause a rebuild.
+	d.handleEvent(fsnotify.Event{Name: gitLockEventName, Op: fsnotify.Create})
+	c.Advance(gitSettleDelay)
+	<-d.timer.C()
+	d.flush()
+
+	if e, ok := receiveEvent(); ok {
+		t.Fatal("No event should have been sent.", e)
+		return
+	}
+}
+
+func TestDebouncerBulkChange(t *testing.T) {
+	d, c := newTestDebouncer(t)
+	bulkDelay := time.Duration(config.Data().BulkDelayMilliseconds) * time.Millisecond
+
+	for i := 0; i <= config.Data().BulkChangeEvents; i++ {
+		d.handleEvent(fsnotify.Event{Name: fmt.Sprintf("file%d.go", i), Op: fsnotify.Write})
+	}
+
+	//The usual delay should be stretched since a bulk change was detected.
+	c.Advance(debounceDelay)
+	select {
+	case <-d.timer.C():
+		t.Fatal("Timer should not expire until the bulk delay.")
+		return
+	default:
+	}
+
+	c.Advance(bulkDelay - debounceDelay)
+	select {
+	case <-d.timer.C():
+		d.flush()
+	default:
+		t.Fatal("Timer should have expired after the bulk delay.")
+		return
+	}
+	if e, _ := receiveEvent(); e.Name == "" {
+		t.Fatal("Event should have been sent once the bulk change stopped.")
+		return
+	}
+
+	//The next change should use the usual delay.
+	d.handleEvent(fsnotify.Event{Name: "main.go", Op: fsnotify.Write})
+	c.Advance(debounceDelay)
+	select {
+	case <-d.timer.C():
+		d.flush()
+	default:
+		t.Fatal("Timer should have expired after the usual delay.")
+		return
+	}
+	if e, _ := receiveEvent(); e.Name != "main.go" {
+		t.Fatal("Event should have been sent.", e)
+		return
+	}
+}
+
+func TestBuildDelay(t *testing.T) {
+	config.UseDefaults()
+
+	if d := buildDelay(initialEventName); d != 0 {
+		t.Fatal("Initial build should not be delayed.", d)
+		return
+	}
+
+	expected := time.Duration(config.Data().BuildDelayMilliseconds) * time.Millisecond
+	if d := buildDelay("main.go"); d != expected {
+		t.Fatal("Build delay should match BuildDelayMilliseconds.", d, expected)
+		return
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	d, c := newTestDebouncer(t)
+
+	//A fake watcher. The channels are unbuffered so that each send returns once
+	//the event has been received.
+	fileEvents := make(chan fsnotify.Event)
+	watchErrors := make(chan error)
+	done := make(chan bool)
+	go func() {
+		watchEvents(fileEvents, watchErrors, d)
+		done <- true
+	}()
+
+	//Stop watching, and wait for watching to stop, so that other tests aren't
+	//affected.
+	defer func() {
+		close(fileEvents)
+		<-done
+	}()
+
+	fileEvents <- fsnotify.Event{Name: "main.go", Op: fsnotify.Write}
+
+	//Send an ignored event so that, once received, handling of the prior event is
+	//known to be complete and the timer was reset.
+	fileEvents <- fsnotify.Event{Name: "main.go", Op: fsnotify.Chmod}
+	c.Advance(debounceDelay)
+
+	select {
+	case e := <-eventsChan:
+		if e.Name != "main.go" {
+			t.Fatal("Wrong event sent.", e)
+			return
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Event should have been sent once the timer expired.")
+		return
+	}
+}
+
+func TestDebouncerConfirmRebuild(t *testing.T) {
+	d, c := newTestDebouncer(t)
+	cfg := config.Defaults()
+	cfg.ConfirmRebuildFiles = 2
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		d.handleEvent(fsnotify.Event{Name: name, Op: fsnotify.Write})
+	}
+	c.Advance(debounceDelay)
+	<-d.timer.C()
+	d.flush()
+	if e, ok := receiveEvent(); ok {
+		t.Fatal("Event should wait for confirmation.", e)
+		return
+	}
+
+	//Confirming returns the event to send.
+	event, ok := confirming.answer("y")
+	if !ok || event == nil || event.Name != "c.go" {
+		t.Fatal("Confirming should return the event.", event, ok)
+		return
+	}
+	if _, ok := confirming.answer("y"); ok {
+		t.Fatal("Nothing should be waiting for confirmation.")
+		return
+	}
+
+	//Anything but "y" skips the rebuild.
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		d.handleEvent(fsnotify.Event{Name: name, Op: fsnotify.Write})
+	}
+	c.Advance(debounceDelay)
+	<-d.timer.C()
+	d.flush()
+	if event, ok := confirming.answer(""); !ok || event != nil {
+		t.Fatal("Rebuild should be skipped.", event, ok)
+		return
+	}
+
+	//Changing fewer files rebuilds without asking.
+	d.handleEvent(fsnotify.Event{Name: "a.go", Op: fsnotify.Write})
+	d.handleEvent(fsnotify.Event{Name: "a.go", Op: fsnotify.Write})
+	d.handleEvent(fsnotify.Event{Name: "b.go", Op: fsnotify.Write})
+	c.Advance(debounceDelay)
+	<-d.timer.C()
+	d.flush()
+	if e, _ := receiveEvent(); e.Name != "b.go" {
+		t.Fatal("Event should have been sent.", e)
+		return
+	}
+}
+
+func TestDebouncerResetsChanges(t *testing.T) {
+	d, c := newTestDebouncer(t)
+	if config.Data().SkipCommentOnlyChanges {
+		t.Fatal("SkipCommentOnlyChanges should be off by default for this test.")
+		return
+	}
+
+	//Changes should be forgotten once sent, even when not checking for comment
+	//only changes, so that they don't build up.
+	d.handleEvent(fsnotify.Event{Name: "a.go", Op: fsnotify.Write})
+	d.handleEvent(fsnotify.Event{Name: "static/site.css", Op: fsnotify.Write})
+	c.Advance(debounceDelay)
+	<-d.timer.C()
+	d.flush()
+	receiveEvent()
+
+	if len(d.changedGoFiles) != 0 || d.otherChange {
+		t.Fatal("Changes should be reset once the event is sent.", d.changedGoFiles, d.otherChange)
+		return
+	}
+}
@@ -0,0 +1,98 @@
+package runner3
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+
+	"github.com/c9845/fresher/config"
+)
+
+// debugStats is the runtime stats for fresher itself, served at /debug/stats.
+//
+// The fields are exported, with json tags, so that the stats can be output as JSON.
+type debugStats struct {
+	Goroutines int `json:"goroutines"`
+	Threads    int `json:"threads"`
+
+	//OpenFiles is the number of file descriptors fresher has open. This is only
+	//known on OSes with /proc/self/fd or /dev/fd, i.e. Linux and macOS.
+	OpenFiles int `json:"openFiles,omitempty"`
+
+	//Memory is in bytes, see runtime.MemStats.
+	HeapAllocBytes uint64 `json:"heapAllocBytes"`
+	SysBytes       uint64 `json:"sysBytes"`
+	NumGC          uint32 `json:"numGC"`
+
+	Watch *watcherStats `json:"watch"`
+}
+
+// serveDebug starts an HTTP server exposing profiling data and runtime stats for
+// fresher, not the binary. This is only started if DebugAddress is set in the config.
+//
+// The pprof handlers are added to this server's mux, rather than relying on
+// net/http/pprof adding them to http.DefaultServeMux, so that they are only served
+// at DebugAddress.
+func serveDebug() {
+	addr := config.Data().DebugAddress
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/stats", handleDebugStats)
+
+	events.Printf("Serving debug info at http://%s/debug/pprof/", addr)
+
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			//Not exiting on error since debugging is not required for building and
+			//running the binary.
+			errs.Printf("Debug server error %s", err)
+		}
+	}()
+}
+
+// handleDebugStats responds with fresher's runtime stats as JSON.
+func handleDebugStats(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	threads, _ := runtime.ThreadCreateProfile(nil)
+	watch := watching.snapshot()
+
+	s := debugStats{
+		Goroutines:     runtime.NumGoroutine(),
+		Threads:        threads,
+		OpenFiles:      countOpenFiles(),
+		HeapAllocBytes: mem.HeapAlloc,
+		SysBytes:       mem.Sys,
+		NumGC:          mem.NumGC,
+		Watch:          &watch,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(&s)
+}
+
+// countOpenFiles returns the number of file descriptors fresher has open, or 0 if
+// this can't be determined on this OS.
+func countOpenFiles() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		entries, err := os.ReadDir(dir)
+		if err == nil {
+			return len(entries)
+		}
+	}
+
+	return 0
+}
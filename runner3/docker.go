@@ -0,0 +1,69 @@
+package runner3
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// usingDocker returns true if the binary is run in a Docker Compose service rather
+// than on the host. See the DockerService field in the config file.
+func usingDocker() bool {
+	return config.Data().DockerService != ""
+}
+
+// dockerBuildEnv returns the environment `go build` is run with when using Docker.
+// The binary is built for Linux, since that is what containers run, and without cgo
+// so that the binary doesn't depend on the container's C libraries.
+func dockerBuildEnv() []string {
+	return append(os.Environ(), "GOOS=linux", "CGO_ENABLED=0")
+}
+
+// dockerCompose returns a `docker compose` command with the given arguments, using
+// the DockerComposeFile if one is set.
+func dockerCompose(args ...string) *exec.Cmd {
+	composeArgs := []string{"compose"}
+	if f := config.Data().DockerComposeFile; f != "" {
+		composeArgs = append(composeArgs, "-f", f)
+	}
+	composeArgs = append(composeArgs, args...)
+
+	cmd := exec.Command("docker", composeArgs...)
+	cmd.Dir = config.Data().WorkingDir
+	return cmd
+}
+
+// deployToDocker copies the built binary into the DockerService's container, unless
+// the binary is bind-mounted, and restarts the container so the new binary is run.
+func deployToDocker(cfg *config.File) (err error) {
+	service := cfg.DockerService
+
+	if dest := cfg.DockerBinaryPath; dest != "" {
+		events.Verbosef("Copying binary to %s:%s", service, dest)
+		out, err := dockerCompose("cp", getPathToBuiltBinary(cfg), service+":"+dest).CombinedOutput()
+		if err != nil {
+			errs.Printf("Could not copy binary to %s %s %s", service, err, strings.TrimSpace(string(out)))
+			return err
+		}
+	}
+
+	events.Verbosef("Restarting %s", service)
+	out, err := dockerCompose("restart", service).CombinedOutput()
+	if err != nil {
+		errs.Printf("Could not restart %s %s %s", service, err, strings.TrimSpace(string(out)))
+		return err
+	}
+
+	return nil
+}
+
+// dockerLogs returns the command that follows the DockerService's logs from the given
+// time on. This is run in place of the binary so that the container's output is
+// shown the same as the binary's output would be, and stopping the command, when the
+// binary is rebuilt, works the same.
+func dockerLogs(since time.Time) *exec.Cmd {
+	return dockerCompose("logs", "--follow", "--no-log-prefix", "--since", since.Format(time.RFC3339), config.Data().DockerService)
+}
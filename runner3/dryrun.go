@@ -0,0 +1,97 @@
+package runner3
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+)
+
+// DryRun prints what fresher would do without building or running anything: each
+// directory that would be watched or ignored, with the reason it is ignored, and the
+// exact commands used to build and run the binary. This is used by the -dry-run flag
+// to diagnose why a file change isn't causing a rebuild.
+//
+// Output is printed, not logged, so that it is shown regardless of LogLevel and can
+// easily be piped to other tools (i.e.: grep).
+func DryRun() (err error) {
+	//Walk the same way as Watch() does so that the output matches what would
+	//actually be watched. Directories are walked in parallel, so the lines are
+	//sorted afterwards to be readable.
+	var (
+		mu               sync.Mutex
+		lines            []string
+		watched, ignored int
+	)
+	err = walkDirectories(config.Data().WorkingDir, func(path string) (bool, error) {
+		reason, err := ignoreDirectoryReason(path)
+		if err != nil {
+			return false, err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if max := config.Data().MaxWatchedDirectories; reason == "" && max > 0 && watched >= max {
+			reason = ignoreReasonMaxWatched
+		}
+		if reason != "" {
+			lines = append(lines, fmt.Sprintf("IGNORE  %s (%s)", path, reason))
+			ignored++
+			return hasUnignoredSubdirectories(path, reason), nil
+		}
+
+		lines = append(lines, fmt.Sprintf("WATCH   %s", path))
+		watched++
+		return true, nil
+	})
+	if err != nil {
+		return
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		//Sort by path, not the WATCH/IGNORE prefix.
+		return lines[i][8:] < lines[j][8:]
+	})
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+
+	if triggerFilePath := getTriggerFilePath(); triggerFilePath != "" {
+		fmt.Printf("TRIGGER %s\n", triggerFilePath)
+	}
+	if config.Data().WatchReplacedModules {
+		for _, dir := range findReplacedModuleDirs() {
+			fmt.Printf("MODULE  %s\n", dir)
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Directories: %d watched, %d ignored\n", watched, ignored)
+	fmt.Printf("Extensions: %s (no rebuild: %s)\n", config.Data().ExtensionsToWatch, config.Data().NoRebuildExtensions)
+	fmt.Printf("Build: %s\n", formatCommand("go", getBuildArgs(config.Data())))
+	if config.Data().WarmBuildCache != config.WarmBuildCacheOff {
+		fmt.Printf("Warm: %s\n", formatCommand("go", getWarmArgs()))
+	}
+	fmt.Printf("Run: %s\n", formatCommand(getPathToBuiltBinary(config.Data()), config.Data().Args))
+
+	return nil
+}
+
+// formatCommand returns a command and its arguments as they would be typed in a
+// shell. Arguments with spaces are quoted so that values such as -ldflags "-s -w"
+// are shown correctly.
+func formatCommand(name string, args []string) string {
+	parts := []string{name}
+	for _, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		parts = append(parts, arg)
+	}
+
+	return strings.Join(parts, " ")
+}
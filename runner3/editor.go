@@ -0,0 +1,55 @@
+package runner3
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/c9845/fresher/config"
+)
+
+// openEditor runs the command set in the config file's OnBuildErrorOpenEditor field
+// to open the first error from a failed build in the user's editor. This tightens
+// up the save, see error, fix error loop since the user doesn't have to go find the
+// file and line the error occured at.
+//
+// The command is a text/template that is given the first buildError, for example
+// `code -g {{.File}}:{{.Line}}:{{.Column}}`. The executed template is split on
+// whitespace to get the command and its arguments; quoting is not supported.
+func openEditor(buildErrs []buildError) {
+	tmpl := config.Data().OnBuildErrorOpenEditor
+	if tmpl == "" || len(buildErrs) == 0 {
+		return
+	}
+
+	t, err := template.New("editor").Parse(tmpl)
+	if err != nil {
+		errs.Printf("Could not parse OnBuildErrorOpenEditor %s", err)
+		return
+	}
+
+	var b bytes.Buffer
+	err = t.Execute(&b, buildErrs[0])
+	if err != nil {
+		errs.Printf("Could not execute OnBuildErrorOpenEditor %s", err)
+		return
+	}
+
+	fields := strings.Fields(b.String())
+	if len(fields) == 0 {
+		return
+	}
+
+	warn.Verbosef("Opening editor... %s", b.String())
+
+	//Don't wait for the editor to close, some editors block until the file is
+	//closed. Wait() is called in a goroutine just to release the process's resources.
+	cmd := exec.Command(fields[0], fields[1:]...)
+	err = cmd.Start()
+	if err != nil {
+		errs.Printf("Could not open editor %s", err)
+		return
+	}
+	go cmd.Wait()
+}
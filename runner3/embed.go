@@ -0,0 +1,136 @@
+package runner3
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+)
+
+// embedListFormat is the `go list` template that outputs the directory and pattern
+// of each //go:embed directive in the main module's packages, one per line. Packages
+// from dependencies are skipped since their files aren't watched.
+const embedListFormat = `{{if and .Module .Module.Main}}{{range .EmbedPatterns}}{{$.Dir}}{{"\t"}}{{.}}{{"\n"}}{{end}}{{end}}`
+
+// embedded is the patterns, relative to WorkingDir, from the //go:embed directives
+// found in the binary's packages. See DetectEmbeddedPaths in the config file.
+var embedded struct {
+	mu       sync.Mutex
+	patterns []string
+}
+
+// isEmbeddedFile returns true if the file is embedded in the binary, either listed in
+// EmbeddedPaths or found by detectEmbeddedPaths(), meaning the binary must be rebuilt
+// when the file changes.
+func isEmbeddedFile(path string) bool {
+	if config.Data().IsEmbeddedPath(path) {
+		return true
+	}
+
+	embedded.mu.Lock()
+	defer embedded.mu.Unlock()
+
+	for _, p := range embedded.patterns {
+		if config.Data().MatchesPath(p, path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// detectEmbeddedPaths finds the //go:embed directives in the binary's packages, in the
+// background, using `go list`. This is run after each successful build since a
+// directive could have been added or removed. Nothing is done if DetectEmbeddedPaths
+// is disabled.
+func detectEmbeddedPaths() {
+	if !config.Data().DetectEmbeddedPaths {
+		return
+	}
+
+	go func() {
+		patterns, err := findEmbedPatterns()
+		if err != nil {
+			warn.Verbosef("Could not detect embedded files %s", err)
+			return
+		}
+
+		embedded.mu.Lock()
+		defer embedded.mu.Unlock()
+
+		if reflect.DeepEqual(patterns, embedded.patterns) {
+			return
+		}
+		embedded.patterns = patterns
+
+		if len(patterns) > 0 {
+			events.Verbosef("Detected embedded files: %s", strings.Join(patterns, ", "))
+		}
+	}()
+}
+
+// findEmbedPatterns runs `go list` on the EntryPoint, with the same tags and
+// environment the binary is built with, to get the patterns from the //go:embed
+// directives in the binary's packages.
+func findEmbedPatterns() ([]string, error) {
+	out, err := goListDeps(embedListFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	workingDir, err := filepath.Abs(config.Data().WorkingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEmbedPatterns(out, workingDir), nil
+}
+
+// goListDeps runs `go list -deps` on the EntryPoint, with the same tags and
+// environment the binary is built with, outputting each package using the format.
+func goListDeps(format string) (string, error) {
+	args := []string{"list", "-deps"}
+	if tags := config.Data().BuildTags(); len(tags) > 0 {
+		args = append(args, "-tags", tags)
+	}
+	if mod := config.Data().GoMod; mod != "" {
+		args = append(args, "-mod="+mod)
+	}
+	args = append(args, "-f", format, config.Data().EntryPoint)
+
+	cmd := exec.Command("go", args...)
+	cmd.Env = getBuildEnv(config.Data())
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// parseEmbedPatterns returns the patterns, relative to workingDir, from the output of
+// `go list` using embedListFormat. The "all:" prefix, used to include hidden files, is
+// removed since hidden files are matched the same as other files. Patterns outside of
+// the workingDir are skipped since they aren't watched.
+func parseEmbedPatterns(out, workingDir string) (patterns []string) {
+	seen := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		dir, pattern, found := strings.Cut(strings.TrimSpace(line), "\t")
+		if !found {
+			continue
+		}
+		pattern = strings.TrimPrefix(pattern, "all:")
+
+		rel, err := filepath.Rel(workingDir, filepath.Join(dir, filepath.FromSlash(pattern)))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if seen[rel] {
+			continue
+		}
+
+		seen[rel] = true
+		patterns = append(patterns, rel)
+	}
+
+	return
+}
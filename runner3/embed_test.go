@@ -0,0 +1,27 @@
+package runner3
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseEmbedPatterns(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "app")
+	out := filepath.Join(root, "web") + "\tstatic\n" +
+		filepath.Join(root, "web") + "\tall:templates/*.html\n" +
+		filepath.Join(root, "web") + "\tstatic\n" +
+		root + "\tversion.txt\n" +
+		filepath.Join(root, "..", "shared") + "\tshared.txt\n"
+
+	patterns := parseEmbedPatterns(out, root)
+	expected := []string{
+		filepath.Join("web", "static"),
+		filepath.Join("web", "templates", "*.html"),
+		"version.txt",
+	}
+	if !reflect.DeepEqual(patterns, expected) {
+		t.Fatal("Unexpected patterns.", patterns)
+		return
+	}
+}
@@ -0,0 +1,97 @@
+package runner3
+
+import (
+	"os/exec"
+	"time"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// builder builds the binary. The default builder runs `go build`. Other builders, i.e.
+// running `make`, can be added by implementing this interface and returning the
+// builder from newBuilder(). Tests can use a fake builder so that building doesn't
+// need a Go toolchain.
+type builder interface {
+	//Build builds the binary for the file change event that triggered the build,
+	//using cfg for the whole build even if the config file is reloaded meanwhile.
+	//errBuildFailed should be returned when the code doesn't build, with the errors
+	//saved to lastBuildErrors, and errBuildKilled when building was stopped by
+	//killBuild().
+	Build(cfg *config.File, event fsnotify.Event) error
+}
+
+// processRunner creates the command that runs the built binary. The command is
+// started, its output shown, and stopped when the binary is rebuilt, by run(), so
+// that every runner is handled the same. The default runner runs the binary on the
+// host. Tests can use a fake runner that returns a command that doesn't need a built
+// binary.
+type processRunner interface {
+	//Command returns the command to run, not yet started, based on cfg.
+	Command(cfg *config.File) *exec.Cmd
+}
+
+// The builder and runner in use, set in Configure() based on the config file.
+var (
+	activeBuilder builder
+	activeRunner  processRunner
+)
+
+// newBuilder returns the builder to use based on the config file.
+func newBuilder() builder {
+	return goBuilder{}
+}
+
+// newProcessRunner returns the processRunner to use based on the config file.
+func newProcessRunner() processRunner {
+	if usingDocker() {
+		return dockerRunner{}
+	}
+
+	return binaryRunner{}
+}
+
+// goBuilder builds the binary with `go build`.
+type goBuilder struct{}
+
+// Build runs `go build`, see build(). If the build failed since modules are missing
+// from go.mod, and OnMissingModules is "tidy", the binary is rebuilt once go.mod is
+// tidied.
+func (goBuilder) Build(cfg *config.File, event fsnotify.Event) error {
+	err := build(cfg, event)
+	if err == errBuildFailed && handleMissingModules(cfg, lastBuildOutput) {
+		return build(cfg, event)
+	}
+
+	return err
+}
+
+// binaryRunner runs the built binary on the host.
+type binaryRunner struct{}
+
+// Command returns the command to run the built binary with the Args and Env from
+// the config file.
+func (binaryRunner) Command(cfg *config.File) *exec.Cmd {
+	cmd := exec.Command(getPathToBuiltBinary(cfg))
+	if len(cfg.Args) > 0 {
+		cmd.Args = append(cmd.Args, cfg.Args...)
+	}
+	cmd.Env = getBinaryEnv(cfg)
+
+	return cmd
+}
+
+// dockerRunner runs the built binary in a Docker Compose service's container, see
+// DockerService in the config file.
+type dockerRunner struct{}
+
+// Command deploys the built binary to the container and returns the command that
+// follows the container's logs. The logs are followed in place of running the binary
+// so that the output, and stopping when rebuilt, is handled the same as when running
+// on the host. The logs are still followed if deploying failed so that the
+// container's state is shown.
+func (dockerRunner) Command(cfg *config.File) *exec.Cmd {
+	deployedAt := time.Now()
+	deployToDocker(cfg)
+	return dockerLogs(deployedAt)
+}
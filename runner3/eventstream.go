@@ -0,0 +1,132 @@
+package runner3
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// Types of events written to the event stream.
+const (
+	streamWatchReady     = "watch.ready"
+	streamFileChanged    = "file.changed"
+	streamBuildStarted   = "build.started"
+	streamBuildSucceeded = "build.succeeded"
+	streamBuildFailed    = "build.failed"
+	streamBuildKilled    = "build.killed"
+	streamRunStarted     = "run.started"
+	streamRunStopped     = "run.stopped"
+	streamAssetsBuilt    = "assets.built"
+	streamAssetsFailed   = "assets.failed"
+)
+
+// streamEvent is a single event written to the event stream as a line of JSON. The
+// fields are exported, with json tags, for encoding. Fields not applicable to an
+// event's type are omitted.
+type streamEvent struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+
+	//File and Op describe the file change event that triggered a build or run.
+	File string `json:"file,omitempty"`
+	Op   string `json:"op,omitempty"`
+
+	//Command is the asset command that was run.
+	Command string `json:"command,omitempty"`
+
+	//DurationSeconds is how long a build, or asset command, took, or how long the
+	//binary ran before it was stopped.
+	DurationSeconds float64 `json:"durationSeconds,omitempty"`
+
+	//Error and Errors describe why a build failed. Failure is the kind of failure,
+	//see classifyBuildFailure().
+	Error   string       `json:"error,omitempty"`
+	Errors  []buildError `json:"errors,omitempty"`
+	Failure string       `json:"failure,omitempty"`
+
+	//Directories is the number of directories being watched.
+	Directories int `json:"directories,omitempty"`
+}
+
+// eventStream is where events are written to. This is nil when the event stream is
+// not enabled. This is set in configureEventStream().
+var (
+	eventStream   io.Writer
+	eventStreamMu sync.Mutex
+)
+
+// configureEventStream opens the writer for the event stream based on the config
+// file's EventStream field. The event stream is designed for consumption by editor
+// plugins that show fresher's status inline.
+//
+// Supported targets:
+//   - "fd:N" writes to file descriptor N, i.e. "fd:3", which an editor plugin can
+//     pass when starting fresher.
+//   - "unix:/path/to/socket" connects to a Unix socket the plugin is listening on.
+//   - "tcp:host:port" connects to a TCP socket the plugin is listening on.
+//   - Anything else is treated as a path to a file that events are appended to.
+func configureEventStream() (err error) {
+	target := config.Data().EventStream
+	if target == "" {
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(target, "fd:"):
+		fd, innerErr := strconv.Atoi(strings.TrimPrefix(target, "fd:"))
+		if innerErr != nil {
+			return fmt.Errorf("invalid event stream file descriptor %s", target)
+		}
+		eventStream = os.NewFile(uintptr(fd), "event-stream")
+
+	case strings.HasPrefix(target, "unix:"):
+		eventStream, err = net.Dial("unix", strings.TrimPrefix(target, "unix:"))
+
+	case strings.HasPrefix(target, "tcp:"):
+		eventStream, err = net.Dial("tcp", strings.TrimPrefix(target, "tcp:"))
+
+	default:
+		eventStream, err = os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	}
+	if err != nil {
+		eventStream = nil
+		return
+	}
+
+	events.Verbosef("Writing event stream to %s", target)
+	return
+}
+
+// emit writes an event to the event stream, if enabled. The event's Time and Type
+// are set here. Errors are only logged since the event stream is not required for
+// building and running the binary.
+func emit(eventType string, e streamEvent) {
+	if eventStream == nil {
+		return
+	}
+
+	e.Time = time.Now()
+	e.Type = eventType
+
+	j, err := json.Marshal(e)
+	if err != nil {
+		errs.Printf("Could not encode event %s", err)
+		return
+	}
+
+	eventStreamMu.Lock()
+	defer eventStreamMu.Unlock()
+
+	_, err = eventStream.Write(append(j, '\n'))
+	if err != nil {
+		errs.Printf("Could not write event %s", err)
+	}
+}
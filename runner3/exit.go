@@ -0,0 +1,146 @@
+package runner3
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// autoRestartEventName is the name of the event sent on the eventsChan when the
+// binary is rerun after exiting with an error, per the AutoRestart config field.
+const autoRestartEventName = "(auto restart)"
+
+// outputTail stores the last few lines of output from the running binary. This is
+// used to diagnose why the binary exited, for example to find an "address already in
+// use" error.
+type outputTail struct {
+	mu       sync.Mutex
+	lines    []string
+	partial  string
+	maxLines int
+}
+
+// recentOutput is the last lines of output from the running binary. This is reset
+// each time the binary is run.
+var recentOutput = &outputTail{maxLines: 50}
+
+// Write implements io.Writer so that outputTail can be used with io.TeeReader.
+func (t *outputTail) Write(p []byte) (n int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data := t.partial + string(p)
+	lines := strings.Split(data, "\n")
+
+	//The last element is an incomplete line, or blank if p ended with a newline.
+	t.partial = lines[len(lines)-1]
+	t.lines = append(t.lines, lines[:len(lines)-1]...)
+
+	if len(t.lines) > t.maxLines {
+		t.lines = t.lines[len(t.lines)-t.maxLines:]
+	}
+
+	return len(p), nil
+}
+
+// reset clears the stored output.
+func (t *outputTail) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lines = nil
+	t.partial = ""
+}
+
+// last returns up to n of the most recent lines of output.
+func (t *outputTail) last(n int) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lines := t.lines
+	if t.partial != "" {
+		lines = append(lines[:len(lines):len(lines)], t.partial)
+	}
+
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return lines
+}
+
+// quickCrashes is the number of times in a row the binary has exited with an error
+// shortly after starting. This is used to detect a crash loop so that the binary
+// isn't endlessly rerun when it will just crash again. This is reset when the binary
+// is rerun due to a file change.
+var quickCrashes atomic.Int32
+
+// resetCrashLoop is called when the binary is run due to a file change, rather than
+// an automatic restart, since the file change may have fixed the crash.
+func resetCrashLoop() {
+	quickCrashes.Store(0)
+}
+
+// handleBinaryExited is called when the running binary exits on its own, not when it
+// was stopped by fresher to be rerun. This diagnoses why the binary exited, when
+// possible, to save the user from having to figure it out, and reruns the binary if
+// AutoRestart is enabled.
+func handleBinaryExited(err error, ranFor time.Duration) {
+	if err != nil {
+		errs.Printf("Binary exited %s", err)
+	} else {
+		warn.Printf("Binary exited")
+	}
+
+	//If the binary exited due to a port conflict that was resolved, the binary is
+	//already being rerun.
+	if handlePortConflict(recentOutput.last(recentOutput.maxLines)) {
+		return
+	}
+
+	//Only rerun the binary if it crashed, not if it exited cleanly since exiting
+	//might be what the binary is supposed to do.
+	if err == nil || !config.Data().AutoRestart {
+		return
+	}
+
+	//Track quick crashes to detect a crash loop.
+	window := time.Duration(config.Data().CrashLoopSeconds) * time.Second
+	crashes := int32(0)
+	if ranFor < window {
+		crashes = quickCrashes.Add(1)
+	} else {
+		quickCrashes.Store(0)
+	}
+
+	//Stop restarting if the binary is crash looping and show the last output from
+	//the binary prominently since it most likely has the reason for the crash.
+	if int(crashes) >= config.Data().CrashLoopLimit {
+		errs.Printf("Binary crashed %d times within %s of starting, not restarting until a file changes.", crashes, window)
+		errs.Printf("Last output from binary:\n%s\n%s\n%s",
+			strings.Repeat("=", 50),
+			strings.Join(recentOutput.last(20), "\n"),
+			strings.Repeat("=", 50),
+		)
+		return
+	}
+
+	//Back off before restarting, doubling the delay with each quick crash, so that
+	//a binary that is failing due to some external reason (i.e. database not up
+	//yet) isn't rerun in a tight loop.
+	backoff := 500 * time.Millisecond
+	for i := int32(1); i < crashes; i++ {
+		backoff *= 2
+	}
+	warn.Printf("Restarting binary in %s...", backoff)
+	clk.Sleep(backoff)
+
+	eventsChan <- fsnotify.Event{
+		Name: autoRestartEventName,
+		Op:   fsnotify.Write,
+	}
+}
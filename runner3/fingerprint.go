@@ -0,0 +1,109 @@
+package runner3
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"go/scanner"
+	"go/token"
+	"os"
+	"strings"
+)
+
+// fingerprints stores a fingerprint of the code in each .go file, ignoring comments
+// and whitespace, as of the last time the file changed. This is used to skip
+// rebuilding when only comments changed, see SkipCommentOnlyChanges.
+//
+// This is only used from the goroutine in Watch() so no locking is needed.
+type fingerprints map[string][sha256.Size]byte
+
+// goFingerprints is the package level store of fingerprints.
+var goFingerprints = fingerprints{}
+
+// onlyCommentsChanged updates the fingerprints of the given files and returns true
+// if none of the files' code changed. False is returned if a file wasn't seen before,
+// since there is nothing to compare to, or the file couldn't be read or parsed.
+func (f fingerprints) onlyCommentsChanged(files map[string]bool) bool {
+	if len(files) == 0 {
+		return false
+	}
+
+	commentsOnly := true
+	for path := range files {
+		sum, ok := fingerprintGoFile(path)
+		if !ok {
+			delete(f, path)
+			commentsOnly = false
+			continue
+		}
+
+		previous, seen := f[path]
+		if !seen || previous != sum {
+			commentsOnly = false
+		}
+		f[path] = sum
+	}
+
+	return commentsOnly
+}
+
+// fingerprintGoFile returns a hash of the tokens in a .go file, ignoring whitespace
+// and comments. Comments that affect building, directives and cgo preambles, are
+// included. False is returned if the file can't be read or has a syntax error.
+func fingerprintGoFile(path string) (sum [sha256.Size]byte, ok bool) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	//Every comment is included in files using cgo since the comment before
+	//`import "C"` is C code.
+	cgo := bytes.Contains(src, []byte(`"C"`))
+
+	fset := token.NewFileSet()
+	file := fset.AddFile(path, -1, len(src))
+
+	var s scanner.Scanner
+	errorCount := 0
+	s.Init(file, src, func(token.Position, string) { errorCount++ }, scanner.ScanComments)
+
+	h := sha256.New()
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+
+		if tok == token.COMMENT && !cgo && !isDirectiveComment(lit) {
+			continue
+		}
+
+		//A semicolon's literal is "\n" when inserted at the end of a line, or ";"
+		//when in the source, but both mean the same thing.
+		if tok == token.SEMICOLON {
+			lit = ""
+		}
+
+		h.Write([]byte(tok.String()))
+		h.Write([]byte{0})
+		h.Write([]byte(lit))
+		h.Write([]byte{0})
+	}
+	if errorCount > 0 {
+		return
+	}
+
+	copy(sum[:], h.Sum(nil))
+	return sum, true
+}
+
+// isDirectiveComment returns true if a comment affects how code is built, i.e.
+// //go:embed or //go:build.
+func isDirectiveComment(comment string) bool {
+	for _, prefix := range []string{"//go:", "//line ", "/*line ", "// +build", "//export "} {
+		if strings.HasPrefix(comment, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,54 @@
+package runner3
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/c9845/fresher/config"
+)
+
+// checkFormatting checks if the changed .go files are formatted, using the tool set in
+// FormatCheck, and logs a warning naming each file that isn't. This is run in the
+// background since it is only informational and shouldn't delay building.
+func checkFormatting(changedFiles map[string]int) {
+	tool := config.Data().FormatCheck
+	if tool == config.FormatCheckOff {
+		return
+	}
+
+	//Removed files are skipped since they can't be checked.
+	files := []string{}
+	for f := range changedFiles {
+		if filepath.Ext(f) != ".go" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return
+	}
+	sort.Strings(files)
+
+	go func() {
+		//Files with syntax errors cause an error, and output on stderr, but these
+		//are reported by the build so only stdout, the unformatted files, is used.
+		out, err := exec.Command(tool, append([]string{"-l"}, files...)...).Output()
+		if _, ok := err.(*exec.ExitError); err != nil && !ok {
+			warn.Printf("Could not check formatting with %s %s", tool, err)
+			return
+		}
+
+		for _, f := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if f == "" {
+				continue
+			}
+			warn.Printf("%s is not formatted, run `%s -w %s`.", f, tool, f)
+		}
+	}()
+}
@@ -0,0 +1,83 @@
+package runner3
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+)
+
+// errGeneratorFailed is returned when a generator's command fails. This is handled
+// the same as a failed build since the binary would be built with stale generated
+// code.
+var errGeneratorFailed = errors.New("generator failed")
+
+// pendingGenerators is the list of generators to run before the next build. Changed
+// files are matched against Generators as events occur, in Watch(), since events are
+// debounced and only the last event is sent to start(); matching in start() would miss
+// files changed in quick succession.
+type pendingGenerators struct {
+	mu sync.Mutex
+
+	//commands are the generators' commands to run, keyed by command so that each
+	//command is only run once regardless of how many matching files changed.
+	commands map[string]bool
+}
+
+// generators is the package level list of generators to run.
+var generators = &pendingGenerators{commands: map[string]bool{}}
+
+// queue notes the generators matching a changed file so they are run before the next
+// build.
+func (p *pendingGenerators) queue(path string) {
+	matches := config.Data().GeneratorsFor(path)
+	if len(matches) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, g := range matches {
+		p.commands[g.Command] = true
+	}
+}
+
+// run runs the queued generators, in the order they are listed in the config file,
+// and clears the queue. Output from each command is shown in the terminal. Running
+// stops at the first failed command, the failed command and any not yet run are left
+// queued.
+func (p *pendingGenerators) run() error {
+	p.mu.Lock()
+	queued := p.commands
+	p.commands = map[string]bool{}
+	p.mu.Unlock()
+
+	if len(queued) == 0 {
+		return nil
+	}
+
+	for _, g := range config.Data().Generators {
+		if !queued[g.Command] {
+			continue
+		}
+
+		events.Printf("Generating... %s", g.Command)
+		err := runCommand(g.Command)
+		if err != nil {
+			//Requeue the failed, and not yet run, generators so that they are run
+			//again on the next build.
+			p.mu.Lock()
+			for command := range queued {
+				p.commands[command] = true
+			}
+			p.mu.Unlock()
+
+			errs.Printf("Generator %s failed %s", g.Command, err)
+			return errGeneratorFailed
+		}
+		delete(queued, g.Command)
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// gitEventName is the name of the event sent on the eventsChan to rebuild once a git
+// operation, i.e. switching branches, has completed. See WatchGit in the config file.
+const gitEventName = "(git operation)"
+
+// gitLockEventName is the name of the event sent by the .git directory's watcher when
+// git locks the index, meaning a git operation may be starting. Unlike gitEventName,
+// this doesn't cause a rebuild on its own since many git commands, i.e. `git add`,
+// lock the index without changing any files.
+const gitLockEventName = "(git index locked)"
+
+// gitSettleDelay is how long to wait after the last file change event during a git
+// operation before rebuilding. This is much longer than debounceDelay since git
+// operations on big repos can change files over several seconds.
+const gitSettleDelay = 1 * time.Second
+
+// git is the state used to handle git operations, set in watchGit().
+var git struct {
+	//dir is the repo's .git directory, blank if WatchGit is disabled or the
+	//directory wasn't found.
+	dir string
+
+	//watchNewDirectories adds directories created by the git operation to the
+	//watcher. This is nil when the watcher is recursive.
+	watchNewDirectories func()
+}
+
+// watchGit watches the repo's .git directory for HEAD changing, meaning a branch was
+// switched, or ORIG_HEAD changing, meaning a pull, merge, rebase, or reset moved the
+// branch. An event named gitEventName is sent on the returned channel for each change
+// so that the debouncer can wait for the operation to complete.
+//
+// HEAD is only written once git has changed the files, so the index being locked is
+// used to notice a git operation as it starts, before the files change, and an event
+// named gitLockEventName is sent. `go build` runs `git status` to stamp the binary
+// with version control info, which would lock the index on each build, so the go
+// commands fresher runs are told not to, see withGitEnv().
+//
+// A nil channel is returned if WatchGit is disabled or the .git directory wasn't
+// found.
+//
+// The .git directory is watched with its own watcher since .git is almost always
+// ignored, and shouldn't be watched recursively since its objects change constantly.
+func watchGit(watchNewDirectories func()) <-chan fsnotify.Event {
+	if !config.Data().WatchGit {
+		return nil
+	}
+
+	dir := findGitDir(config.Data().WorkingDir)
+	if dir == "" {
+		warn.Printf("WatchGit is set but a .git directory was not found, git operations won't be detected.")
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		errs.Printf("Could not watch .git directory %s", err)
+		return nil
+	}
+	err = watcher.Add(dir)
+	if err != nil {
+		errs.Printf("Could not watch .git directory %s", err)
+		watcher.Close()
+		return nil
+	}
+
+	git.dir = dir
+	git.watchNewDirectories = watchNewDirectories
+	events.Verbosef("Watching %s for git operations", dir)
+
+	c := make(chan fsnotify.Event, 1)
+	go func() {
+		for event := range watcher.Events {
+			switch filepath.Base(event.Name) {
+			case "HEAD", "ORIG_HEAD":
+				c <- fsnotify.Event{Name: gitEventName, Op: fsnotify.Write}
+			case "index.lock":
+				if event.Has(fsnotify.Create) {
+					c <- fsnotify.Event{Name: gitLockEventName, Op: fsnotify.Create}
+				}
+			}
+		}
+	}()
+
+	return c
+}
+
+// withGitEnv adds GIT_OPTIONAL_LOCKS=0 to env, or fresher's environment if env is
+// nil, when WatchGit is set. This stops the `git status` run by `go build`, to stamp
+// the binary with version control info, from locking the index and being detected as
+// a git operation. env is returned as-is otherwise.
+func withGitEnv(cfg *config.File, env []string) []string {
+	if !cfg.WatchGit {
+		return env
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+
+	return append(env, "GIT_OPTIONAL_LOCKS=0")
+}
+
+// findGitDir returns the .git directory for the repo containing dir, or a blank
+// string if dir isn't in a git repo. Parent directories are checked since WorkingDir
+// may be a subdirectory of the repo. In a worktree or submodule, .git is a file
+// pointing to the actual git directory.
+func findGitDir(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, ".git")
+		fi, err := os.Stat(path)
+		if err == nil && fi.IsDir() {
+			return path
+		}
+		if err == nil {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return ""
+			}
+
+			gitDir := strings.TrimSpace(string(b))
+			if !strings.HasPrefix(gitDir, "gitdir:") {
+				return ""
+			}
+			gitDir = strings.TrimSpace(strings.TrimPrefix(gitDir, "gitdir:"))
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+			return filepath.Clean(gitDir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// addNewDirectories walks the directory tree and adds directories that aren't in
+// watched, and aren't ignored, to the watcher. This is used after a git operation
+// since switching branches can create directories that fsnotify doesn't watch.
+// Removed directories are removed from the watcher by fsnotify.
+func addNewDirectories(watched []string, addDirectory func(path string) error) {
+	isWatched := make(map[string]bool, len(watched))
+	for _, dir := range watched {
+		isWatched[dir] = true
+	}
+
+	err := walkDirectories(config.Data().WorkingDir, func(path string) (bool, error) {
+		reason, err := ignoreDirectoryReason(path)
+		if err != nil {
+			return false, err
+		}
+		if reason != "" {
+			return hasUnignoredSubdirectories(path, reason), nil
+		}
+		if isWatched[path] {
+			return true, nil
+		}
+
+		events.Verbosef("Watching new directory %s", path)
+		err = addDirectory(path)
+		if err != nil {
+			errs.Printf("Could not watch %s %s", path, err)
+		}
+		return true, nil
+	})
+	if err != nil {
+		errs.Printf("Could not find new directories %s", err)
+	}
+}
+
+// isGitBusy returns true if a git operation is still in progress, meaning git is
+// holding the lock on the index.
+func isGitBusy() bool {
+	if git.dir == "" {
+		return false
+	}
+
+	_, err := os.Stat(filepath.Join(git.dir, "index.lock"))
+	return err == nil
+}
+
+// gitBranch returns the name of the checked out branch, or the abbreviated commit
+// hash if HEAD is detached. A blank string is returned if WorkingDir isn't in a git
+// repo.
+func gitBranch() string {
+	dir := findGitDir(config.Data().WorkingDir)
+	if dir == "" {
+		return ""
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	head := strings.TrimSpace(string(b))
+	if strings.HasPrefix(head, "ref:") {
+		ref := strings.TrimSpace(strings.TrimPrefix(head, "ref:"))
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if len(head) > 7 {
+		return head[:7]
+	}
+	return head
+}
@@ -0,0 +1,68 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestFindGitDir(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "cmd", "api")
+	err := os.MkdirAll(sub, 0755)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if dir := findGitDir(sub); dir != "" {
+		t.Fatal("No .git directory should be found.", dir)
+		return
+	}
+
+	//The .git directory should be found from a subdirectory of the repo.
+	err = os.Mkdir(filepath.Join(root, ".git"), 0755)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if dir := findGitDir(sub); dir != filepath.Join(root, ".git") {
+		t.Fatal("Unexpected .git directory.", dir)
+		return
+	}
+
+	//In a worktree, .git is a file pointing to the git directory.
+	worktree := filepath.Join(root, "cmd")
+	err = os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: ../.git/worktrees/cmd\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if dir := findGitDir(sub); dir != filepath.Join(root, ".git", "worktrees", "cmd") {
+		t.Fatal("Unexpected worktree git directory.", dir)
+		return
+	}
+}
+
+func TestWithGitEnv(t *testing.T) {
+	config.UseDefaults()
+	if env := withGitEnv(config.Data(), nil); env != nil {
+		t.Fatal("Environment should not be changed when WatchGit isn't set.", env)
+		return
+	}
+
+	cfg := config.Defaults()
+	cfg.WatchGit = true
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	env := withGitEnv(config.Data(), []string{"A=1"})
+	if len(env) != 2 || env[1] != "GIT_OPTIONAL_LOCKS=0" {
+		t.Fatal("Optional locks should be disabled.", env)
+		return
+	}
+}
@@ -0,0 +1,60 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/c9845/fresher/config"
+)
+
+// goCacheEnv is the GOCACHE and GOTMPDIR environment variables, set from GoCache and
+// GoTmpDir in the config file, given to each go command fresher runs. This is
+// populated in configureGoCache().
+var goCacheEnv []string
+
+// configureGoCache creates the GoCache and GoTmpDir directories, if set, and notes the
+// environment variables to give to go commands. Absolute paths are used since Go
+// requires GOCACHE and GOTMPDIR to be absolute.
+func configureGoCache() (err error) {
+	vars := []struct {
+		name string
+		dir  string
+	}{
+		{"GOCACHE", config.Data().GoCache},
+		{"GOTMPDIR", config.Data().GoTmpDir},
+	}
+
+	for _, v := range vars {
+		if v.dir == "" {
+			continue
+		}
+
+		dir, err := filepath.Abs(v.dir)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(dir, 0755)
+		if err != nil {
+			return err
+		}
+
+		goCacheEnv = append(goCacheEnv, v.name+"="+dir)
+		events.Verbosef("Building with %s=%s", v.name, dir)
+	}
+
+	return
+}
+
+// withGoCacheEnv returns env with the GOCACHE and GOTMPDIR from configureGoCache()
+// added. A nil env, meaning fresher's environment is used as-is, is returned as-is if
+// neither are set.
+func withGoCacheEnv(env []string) []string {
+	if len(goCacheEnv) == 0 {
+		return env
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+
+	return append(env, goCacheEnv...)
+}
@@ -0,0 +1,64 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestConfigureGoCache(t *testing.T) {
+	dir := t.TempDir()
+
+	cfg := config.Defaults()
+	cfg.GoCache = filepath.Join(dir, "cache")
+	cfg.GoTmpDir = filepath.Join(dir, "tmp")
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	goCacheEnv = nil
+	defer func() { goCacheEnv = nil }()
+
+	err = configureGoCache()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	for _, d := range []string{cfg.GoCache, cfg.GoTmpDir} {
+		if _, err := os.Stat(d); err != nil {
+			t.Fatal("Directory not created.", d, err)
+			return
+		}
+	}
+
+	env := withGoCacheEnv([]string{"GOOS=js"})
+	expected := []string{"GOOS=js", "GOCACHE=" + cfg.GoCache, "GOTMPDIR=" + cfg.GoTmpDir}
+	if len(env) != len(expected) {
+		t.Fatal("Unexpected env.", env)
+		return
+	}
+	for i := range expected {
+		if env[i] != expected[i] {
+			t.Fatal("Unexpected env.", env)
+			return
+		}
+	}
+
+	if !cfg.IsGoCacheDir(cfg.GoCache) || cfg.IsGoCacheDir(dir) {
+		t.Fatal("GoCache not detected.")
+		return
+	}
+}
+
+func TestWithGoCacheEnvUnset(t *testing.T) {
+	goCacheEnv = nil
+	if env := withGoCacheEnv(nil); env != nil {
+		t.Fatal("Env should be nil when GoCache and GoTmpDir are not set.", env)
+		return
+	}
+}
@@ -0,0 +1,142 @@
+package runner3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/c9845/fresher/config"
+)
+
+// goEnv is the output of `go env -json` for the variables checked when starting.
+type goEnv struct {
+	GOVERSION  string
+	GOROOT     string
+	GOPATH     string
+	GOMODCACHE string
+}
+
+// checkGoToolchain makes sure `go` can be run, and is new enough per the config file's
+// MinimumGoVersion field, before anything is built. This returns an error explaining
+// how to fix the problem rather than each build failing with an error from exec.
+// Problems with GOPATH or GOMODCACHE are logged as warnings since builds may still
+// work, i.e. if every module is vendored.
+func checkGoToolchain() (err error) {
+	path, err := exec.LookPath("go")
+	if err != nil {
+		return fmt.Errorf("could not find go on the PATH, install Go from https://go.dev/dl/ or add its bin directory to the PATH %w", err)
+	}
+
+	cmd := exec.Command(path, "env", "-json", "GOVERSION", "GOROOT", "GOPATH", "GOMODCACHE")
+	cmd.Dir = config.Data().WorkingDir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("could not run %s env, check GOROOT, GOFLAGS, and GOENV %s", path, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return fmt.Errorf("could not run %s %w", path, err)
+	}
+
+	var env goEnv
+	err = json.Unmarshal(out, &env)
+	if err != nil {
+		return fmt.Errorf("could not parse %s env output %w", path, err)
+	}
+
+	events.Verbosef("Using %s at %s", env.GOVERSION, path)
+
+	err = checkGoVersion(env.GOVERSION, config.Data().MinimumGoVersion)
+	if err != nil {
+		return
+	}
+
+	checkGoPaths(env)
+	return nil
+}
+
+// checkGoVersion returns an error if version, as output by `go env GOVERSION`, is
+// older than minimum. Development versions of Go are always allowed since their
+// version can't be compared.
+func checkGoVersion(version, minimum string) error {
+	if minimum == "" {
+		return nil
+	}
+
+	//GOVERSION was added in Go 1.16.
+	if version == "" {
+		return fmt.Errorf("go is older than MinimumGoVersion %s, install a newer version from https://go.dev/dl/", minimum)
+	}
+
+	v, ok := parseGoVersion(version)
+	if !ok {
+		warn.Verbosef("Could not compare Go version %s to MinimumGoVersion %s.", version, minimum)
+		return nil
+	}
+	m, _ := parseGoVersion(minimum)
+
+	if compareGoVersions(v, m) < 0 {
+		return fmt.Errorf("%s is older than MinimumGoVersion %s, install a newer version from https://go.dev/dl/ or put it first on the PATH", version, minimum)
+	}
+
+	return nil
+}
+
+// parseGoVersion returns the major, minor, and patch numbers from a Go version, i.e.
+// go1.21.3 or 1.21. Pre-release suffixes, i.e. rc1, are ignored. False is returned
+// for development versions.
+func parseGoVersion(version string) (parts [3]int, ok bool) {
+	version = strings.TrimPrefix(version, "go")
+	for i, field := range strings.SplitN(version, ".", 3) {
+		//Drop any pre-release suffix, i.e. 22rc1.
+		end := 0
+		for end < len(field) && field[end] >= '0' && field[end] <= '9' {
+			end++
+		}
+
+		n, err := strconv.Atoi(field[:end])
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+
+	return parts, true
+}
+
+// compareGoVersions returns -1 if a is older than b, 1 if a is newer, or 0 if they
+// are the same.
+func compareGoVersions(a, b [3]int) int {
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// checkGoPaths warns about GOPATH and GOMODCACHE values that will cause builds to
+// fail, or behave unexpectedly, when modules need to be downloaded.
+func checkGoPaths(env goEnv) {
+	if env.GOMODCACHE == "" {
+		warn.Printf("GOPATH and GOMODCACHE are not set, and there is no home directory to default to, so modules can't be downloaded. Set GOPATH or GOMODCACHE.")
+		return
+	}
+
+	if info, err := os.Stat(env.GOMODCACHE); err == nil && !info.IsDir() {
+		warn.Printf("GOMODCACHE %s is a file, not a directory, so modules can't be downloaded.", env.GOMODCACHE)
+	}
+
+	if env.GOPATH != "" && env.GOROOT != "" && filepath.Clean(env.GOPATH) == filepath.Clean(env.GOROOT) {
+		warn.Printf("GOPATH is set to GOROOT %s, set GOPATH to a different directory.", env.GOROOT)
+	}
+}
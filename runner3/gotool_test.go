@@ -0,0 +1,29 @@
+package runner3
+
+import "testing"
+
+func TestCheckGoVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		minimum string
+		ok      bool
+	}{
+		{"go1.21.3", "", true},
+		{"go1.21.3", "1.21", true},
+		{"go1.21.3", "1.21.3", true},
+		{"go1.21.3", "1.21.4", false},
+		{"go1.20", "1.21", false},
+		{"go1.22rc1", "1.21", true},
+		{"go1.9", "1.10", false},
+		{"devel go1.23-abc123", "1.21", true},
+		{"", "1.21", false},
+	}
+
+	for _, tt := range tests {
+		err := checkGoVersion(tt.version, tt.minimum)
+		if (err == nil) != tt.ok {
+			t.Fatal("Unexpected result.", tt.version, tt.minimum, err)
+			return
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package runner3
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// runHeaderData is given to the RunHeader template.
+type runHeaderData struct {
+	Build    int
+	Restarts int
+	File     string
+	Op       string
+	Time     time.Time
+	Branch   string
+}
+
+// runHeader returns the line logged after the binary is run to separate fresher's
+// logging from the binary's output. This is a line of dashes unless RunHeader is set
+// in the config file, in which case the template is executed with the build number,
+// the file change event that caused the rebuild, and the git branch so that each run
+// is easy to find when scrolling back through the logs.
+func runHeader(eventName, eventType string) string {
+	dashes := strings.Repeat("-", 50)
+
+	tmpl := config.Data().RunHeader
+	if tmpl == "" {
+		return dashes
+	}
+
+	t, err := template.New("header").Parse(tmpl)
+	if err != nil {
+		errs.Printf("Could not parse RunHeader %s", err)
+		return dashes
+	}
+
+	builds, restarts := stats.counts()
+	data := runHeaderData{
+		Build:    builds,
+		Restarts: restarts,
+		File:     eventName,
+		Op:       eventType,
+		Time:     time.Now(),
+		Branch:   gitBranch(),
+	}
+
+	var b bytes.Buffer
+	err = t.Execute(&b, data)
+	if err != nil {
+		errs.Printf("Could not execute RunHeader %s", err)
+		return dashes
+	}
+
+	return b.String()
+}
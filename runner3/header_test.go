@@ -0,0 +1,71 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestRunHeader(t *testing.T) {
+	dir := t.TempDir()
+	err := os.Mkdir(filepath.Join(dir, ".git"), 0755)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/feature/login\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	cfg := config.Defaults()
+	cfg.WorkingDir = dir
+	err = config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if h := runHeader("main.go", "WRITE"); h != strings.Repeat("-", 50) {
+		t.Fatal("A line of dashes should be logged by default.", h)
+		return
+	}
+
+	cfg.RunHeader = "--- {{.File}} {{.Op}} ({{.Branch}}) ---"
+	err = config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if h := runHeader("main.go", "WRITE"); h != "--- main.go WRITE (feature/login) ---" {
+		t.Fatal("Unexpected header.", h)
+		return
+	}
+
+	//A detached HEAD should use the abbreviated commit hash.
+	err = os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("3f2a9c1d8e7b6a5f4e3d2c1b0a9f8e7d6c5b4a39\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if b := gitBranch(); b != "3f2a9c1" {
+		t.Fatal("Unexpected detached branch.", b)
+		return
+	}
+
+	//An invalid template should fall back to dashes.
+	cfg.RunHeader = "{{.File"
+	err = config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if h := runHeader("main.go", "WRITE"); h != strings.Repeat("-", 50) {
+		t.Fatal("A line of dashes should be logged if the template is invalid.", h)
+		return
+	}
+}
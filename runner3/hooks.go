@@ -0,0 +1,138 @@
+package runner3
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// Names of the points in fresher's lifecycle hooks are run at. The name is given to
+// each hook command in the FRESHER_HOOK environment variable.
+const (
+	hookPreWatch         = "pre-watch"
+	hookPreBuild         = "pre-build"
+	hookPostBuildSuccess = "post-build-success"
+	hookPostBuildFailure = "post-build-failure"
+	hookPreRun           = "pre-run"
+	hookPostStop         = "post-stop"
+)
+
+// hookEvent describes what caused a hook to run. This is given to each hook command
+// as environment variables and as JSON on stdin. Fields not applicable to a hook are
+// left blank.
+//
+// The fields are exported, with json tags, so that the event can be output as JSON.
+type hookEvent struct {
+	Hook string    `json:"hook"`
+	Time time.Time `json:"time"`
+
+	//File and Op describe the file change event that triggered the build or run.
+	File string `json:"file,omitempty"`
+	Op   string `json:"op,omitempty"`
+
+	//ChangedFiles are the files changed since the last successful build, with the
+	//number of times each was changed. For PostBuildSuccess, these are the changes
+	//that were just built.
+	ChangedFiles map[string]int `json:"changedFiles,omitempty"`
+
+	//BuildDurationSeconds is how long the build took.
+	BuildDurationSeconds float64 `json:"buildDurationSeconds,omitempty"`
+
+	//BuildOutput is the stderr output from `go build`.
+	BuildOutput string `json:"buildOutput,omitempty"`
+
+	//Error and Errors describe why the build failed.
+	Error  string       `json:"error,omitempty"`
+	Errors []buildError `json:"errors,omitempty"`
+}
+
+// hookCommands returns the commands configured for a hook.
+func hookCommands(hook string) []string {
+	hooks := config.Data().Hooks
+	switch hook {
+	case hookPreWatch:
+		return hooks.PreWatch
+	case hookPreBuild:
+		return hooks.PreBuild
+	case hookPostBuildSuccess:
+		return hooks.PostBuildSuccess
+	case hookPostBuildFailure:
+		return hooks.PostBuildFailure
+	case hookPreRun:
+		return hooks.PreRun
+	case hookPostStop:
+		return hooks.PostStop
+	default:
+		return nil
+	}
+}
+
+// runHooks runs the commands configured for a hook, in order, waiting for each to
+// complete. Each command is given the event as environment variables and as JSON on
+// stdin. Output from each command is shown in the terminal. A failed command is
+// logged but doesn't stop the other commands, or fresher, from running.
+func runHooks(hook string, e hookEvent) {
+	commands := hookCommands(hook)
+	if len(commands) == 0 {
+		return
+	}
+
+	e.Hook = hook
+	e.Time = time.Now()
+	if e.ChangedFiles == nil {
+		e.ChangedFiles = status.snapshot().ChangedFiles
+	}
+
+	//The event is given as JSON on stdin so that scripts don't need to parse the
+	//environment variables.
+	input, err := json.Marshal(e)
+	if err != nil {
+		errs.Printf("Could not encode %s hook event %s", hook, err)
+		return
+	}
+
+	env := append(os.Environ(), e.env()...)
+	for _, command := range commands {
+		events.Verbosef("Running %s hook... %s", hook, command)
+
+		fields := strings.Fields(command)
+		cmd := exec.Command(fields[0], fields[1:]...)
+		cmd.Dir = config.Data().WorkingDir
+		cmd.Env = env
+		cmd.Stdin = bytes.NewReader(input)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		err := cmd.Run()
+		if err != nil {
+			errs.Printf("Hook %s %s failed %s", hook, command, err)
+		}
+	}
+}
+
+// env returns the environment variables describing the event, given to each hook
+// command.
+func (e hookEvent) env() []string {
+	env := []string{
+		"FRESHER_HOOK=" + e.Hook,
+		"FRESHER_FILE=" + e.File,
+		"FRESHER_OP=" + e.Op,
+		"FRESHER_BINARY=" + getPathToBuiltBinary(config.Data()),
+		"FRESHER_BUILD_ERRORS_LOG=" + filepath.Join(config.Data().TempDir, config.Data().BuildLogFilename),
+	}
+
+	if e.BuildDurationSeconds > 0 {
+		env = append(env, "FRESHER_BUILD_DURATION_SECONDS="+strconv.FormatFloat(e.BuildDurationSeconds, 'f', 3, 64))
+	}
+	if e.Error != "" {
+		env = append(env, "FRESHER_ERROR="+e.Error)
+	}
+
+	return env
+}
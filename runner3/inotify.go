@@ -0,0 +1,94 @@
+package runner3
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Paths to the inotify limits on Linux. Each watched directory uses one watch and
+// each fresher uses one instance. Both limits are per-user, not per-process, so
+// editors and other tools watching files count against the limits as well.
+const (
+	inotifyMaxUserWatches   = "/proc/sys/fs/inotify/max_user_watches"
+	inotifyMaxUserInstances = "/proc/sys/fs/inotify/max_user_instances"
+)
+
+// watchLimitWarnPercent is how much of the inotify watch limit fresher can use before
+// a warning is shown. Some room is left for other tools watching files.
+const watchLimitWarnPercent = 80
+
+// getWatchLimit returns the OS's limit on the number of watches, or 0 if the limit
+// cannot be determined. Only Linux is supported since inotify has a per-user limit
+// on the number of watches, max_user_watches, and each watched directory uses one.
+func getWatchLimit() int {
+	return readInotifyLimit(inotifyMaxUserWatches)
+}
+
+// readInotifyLimit reads an inotify limit from the given path, returning 0 if the
+// limit cannot be read, i.e.: not on Linux.
+func readInotifyLimit(path string) int {
+	if runtime.GOOS != "linux" {
+		return 0
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0
+	}
+
+	return limit
+}
+
+// warnWatchLimit warns when the number of watched directories is approaching the
+// inotify watch limit. Hitting the limit causes watching to fail, or file changes to
+// be silently missed, so the exact command to raise the limit is shown.
+func warnWatchLimit(watched, limit int) {
+	if limit <= 0 || watched*100 < limit*watchLimitWarnPercent {
+		return
+	}
+
+	warn.Printf("Watching %d directories, near the inotify limit of %d. Raise the limit with: %s", watched, limit, sysctlCommand("max_user_watches", watched*2))
+}
+
+// explainWatchError adds a hint about how to fix an error from the watcher when the
+// error is due to an inotify limit being hit. Errors from NewWatcher() with EMFILE
+// mean too many inotify instances are in use, errors from Add() with ENOSPC mean too
+// many watches are in use.
+func explainWatchError(err error) error {
+	if runtime.GOOS != "linux" {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, syscall.EMFILE):
+		limit := readInotifyLimit(inotifyMaxUserInstances)
+		return errors.New(err.Error() + ", inotify instance limit reached, raise the limit with: " + sysctlCommand("max_user_instances", limit*2))
+
+	case errors.Is(err, syscall.ENOSPC):
+		limit := getWatchLimit()
+		return errors.New(err.Error() + ", inotify watch limit reached, raise the limit with: " + sysctlCommand("max_user_watches", limit*2))
+	}
+
+	return err
+}
+
+// sysctlCommand returns the command to raise an inotify limit to at least n. Limits
+// are rounded up to a power of 2 since that is what most guides suggest and is easy
+// to recognize.
+func sysctlCommand(name string, n int) string {
+	limit := 128
+	for limit < n {
+		limit *= 2
+	}
+
+	return "sudo sysctl fs.inotify." + name + "=" + strconv.Itoa(limit)
+}
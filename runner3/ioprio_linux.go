@@ -0,0 +1,25 @@
+package runner3
+
+import "syscall"
+
+// Values for the ioprio_set syscall, see `man ioprio_set`. These aren't defined in the
+// syscall package.
+const (
+	ioprioWhoProcess  = 1
+	ioprioClassBE     = 2
+	ioprioClassShift  = 13
+	ioprioLowestLevel = 7
+)
+
+// lowerIOPriority lowers the disk priority of a process to the lowest level of the
+// best effort class, the same as `ionice -c2 -n7`. The idle class isn't used since
+// the build would never run while something else was using the disk.
+func lowerIOPriority(pid int) error {
+	prio := ioprioClassBE<<ioprioClassShift | ioprioLowestLevel
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build unix && !linux
+
+package runner3
+
+// lowerIOPriority does nothing since only Linux allows setting a process's disk
+// priority.
+func lowerIOPriority(pid int) error {
+	return nil
+}
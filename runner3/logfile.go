@@ -0,0 +1,134 @@
+package runner3
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+)
+
+// Writers the running binary's output is copied to. These default to fresher's
+// stdout and stderr but are replaced in configureLogFile() when the binary's output
+// should also be saved to the log file.
+var (
+	childStdout io.Writer = os.Stdout
+	childStderr io.Writer = os.Stderr
+)
+
+// rotatingFile is an io.Writer that writes to a file and rotates the file when it
+// grows beyond a maximum size. Only one previous file is kept, named with a ".1"
+// suffix, since the log file is meant for inspecting recent crashes, not as a long
+// term archive.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+// colorCodes matches the escape sequences used to colorize logs. These are removed
+// before writing to the log file since they are just noise when not in a terminal.
+var colorCodes = regexp.MustCompile("\033\\[[0-9;]*m")
+
+// newRotatingFile opens, or creates, the file at path for appending.
+func newRotatingFile(path string, maxSize int64) (r *rotatingFile, err error) {
+	r = &rotatingFile{
+		path:    path,
+		maxSize: maxSize,
+	}
+
+	err = r.open()
+	return
+}
+
+// open opens the file at the rotatingFile's path and notes its current size.
+func (r *rotatingFile) open() (err error) {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+
+	r.file = f
+	r.size = fi.Size()
+	return
+}
+
+// Write implements io.Writer. Color codes are stripped and the file is rotated
+// before writing if the write would push the file past its maximum size.
+func (r *rotatingFile) Write(p []byte) (n int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stripped := colorCodes.ReplaceAll(p, nil)
+
+	if r.maxSize > 0 && r.size+int64(len(stripped)) > r.maxSize {
+		err = r.rotate()
+		if err != nil {
+			return
+		}
+	}
+
+	written, err := r.file.Write(stripped)
+	r.size += int64(written)
+	if err != nil {
+		return
+	}
+
+	//Return the length of the original data, not the stripped data, since callers
+	//(i.e.: io.Copy) treat a short write as an error.
+	return len(p), nil
+}
+
+// rotate closes the current file, renames it with a ".1" suffix (replacing any
+// previously rotated file), and opens a new file at the path.
+func (r *rotatingFile) rotate() (err error) {
+	err = r.file.Close()
+	if err != nil {
+		return
+	}
+
+	err = os.Rename(r.path, r.path+".1")
+	if err != nil {
+		return
+	}
+
+	return r.open()
+}
+
+// configureLogFile sets up teeing of fresher's logging, and optionally the running
+// binary's output, to the log file in the temp directory. This is useful for
+// inspecting logs after the fact, for example when the binary crashes overnight and
+// the terminal's scrollback has been lost.
+func configureLogFile() (err error) {
+	cfg := config.Data()
+	if cfg.LogFile == "" {
+		return
+	}
+
+	path := filepath.Join(cfg.TempDir, cfg.LogFile)
+	maxSize := int64(cfg.LogFileMaxSizeMB) * 1024 * 1024
+	f, err := newRotatingFile(path, maxSize)
+	if err != nil {
+		return
+	}
+
+	logger.SetOutput(io.MultiWriter(logger.Writer(), f))
+
+	if cfg.LogFileIncludeOutput {
+		childStdout = io.MultiWriter(childStdout, f)
+		childStderr = io.MultiWriter(childStderr, f)
+	}
+
+	events.Verbosef("Logging to %s", path)
+	return
+}
@@ -13,7 +13,7 @@ import (
 // out from the binary being run logs.
 var (
 	events coloredLogger //for file changes, build, run.
-	warn   coloredLogger //verbose logging, more details about file changes, builds, etc.
+	warn   coloredLogger //warnings and more details about file changes, builds, etc.
 	errs   coloredLogger //errors
 )
 
@@ -48,11 +48,19 @@ func getColorCode(color string) string {
 	}
 }
 
+// resetColorCode is the escape sequence to return text to the terminal's default
+// color after using a color code from getColorCode().
+var resetColorCode = fmt.Sprintf("\033[%sm", "0")
+
 // coloredLogger stores details about the logger.
 type coloredLogger struct {
 	color     string
 	colorCode string
 	prefix    string
+
+	//level is the config.LogLevel... that logging via Printf is output at. Logging is
+	//only output if this level is enabled per the config file's LogLevel field.
+	level string
 }
 
 // logger handles outputing colored logs. Use standard logging format just to be
@@ -64,27 +72,56 @@ var loggerFlags = log.LstdFlags // use log.Ldate | log.Ltime | log.Lmicroseconds
 var logger = log.New(colorable.NewColorableStderr(), "", loggerFlags)
 
 // newLogger returns a coloredLogger for calling Printf on with the resulting log
-// colored and prefixed accordingly.
-func newLogger(prefix, color string) coloredLogger {
+// colored and prefixed accordingly. If colors are disabled, via the config file or
+// the NO_COLOR environmental variable, the logger will just add the prefix.
+func newLogger(prefix, color, level string) coloredLogger {
+	if !config.Data().UseColors() {
+		return coloredLogger{color, "", prefix, level}
+	}
+
 	colorCode := getColorCode(color)
-	return coloredLogger{color, colorCode, prefix}
+	return coloredLogger{color, colorCode, prefix, level}
 }
 
-// Printf calls log.Printf with color sequences surrounding some of the text.
+// Printf calls log.Printf with color sequences surrounding some of the text. Nothing
+// is logged if the logger's level is not enabled.
 func (c *coloredLogger) Printf(format string, v ...interface{}) {
-	resetCode := fmt.Sprintf("\033[%sm", "0")
+	if !config.Data().IsLogLevel(c.level) {
+		return
+	}
+
+	c.output(format, v...)
+}
 
-	format = fmt.Sprintf("%s%s |%s %s", c.colorCode, c.prefix, resetCode, format)
+// output handles the actual logging for Printf, Verbosef, and Tracef.
+func (c *coloredLogger) output(format string, v ...interface{}) {
+	if c.colorCode == "" {
+		format = fmt.Sprintf("%s | %s", c.prefix, format)
+		logger.Printf(format, v...)
+		return
+	}
+
+	format = fmt.Sprintf("%s%s |%s %s", c.colorCode, c.prefix, resetColorCode, format)
 	logger.Printf(format, v...)
 }
 
-// Verbosef calls Printf if, and only if, verbose logging is enabled. This alleviates
+// Verbosef logs if, and only if, the debug log level is enabled. This alleviates
 // us from having to put "if" blocks around Printf to check if verbose logging is
 // enabled.
 func (c *coloredLogger) Verbosef(format string, v ...interface{}) {
-	if !config.Data().Verbose {
+	if !config.Data().IsLogLevel(config.LogLevelDebug) {
+		return
+	}
+
+	c.output(format, v...)
+}
+
+// Tracef logs if, and only if, the trace log level is enabled. This is used for
+// very noisy logging, such as every file change event.
+func (c *coloredLogger) Tracef(format string, v ...interface{}) {
+	if !config.Data().IsLogLevel(config.LogLevelTrace) {
 		return
 	}
 
-	c.Printf(format, v...)
+	c.output(format, v...)
 }
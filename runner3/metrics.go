@@ -0,0 +1,88 @@
+package runner3
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// serveMetrics starts an HTTP server that exposes build statistics in the Prometheus
+// text exposition format. This is only started if MetricsAddress is set in the
+// config.
+//
+// The exposition format is written by hand, rather than using the Prometheus client
+// library, since the format is simple and we only expose a handful of metrics. This
+// keeps fresher's dependencies to a minimum.
+//
+// See https://prometheus.io/docs/instrumenting/exposition_formats/.
+func serveMetrics() {
+	addr := config.Data().MetricsAddress
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		stats.writeMetrics(w)
+	})
+
+	events.Printf("Serving metrics at http://%s/metrics", addr)
+
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			//Not exiting on error since metrics are not required for building and
+			//running the binary.
+			errs.Printf("Metrics server error %s", err)
+		}
+	}()
+}
+
+// writeMetrics writes the statistics in Prometheus text exposition format to w.
+func (s *buildStats) writeMetrics(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP fresher_builds_total Number of builds by result.")
+	fmt.Fprintln(w, "# TYPE fresher_builds_total counter")
+	fmt.Fprintf(w, "fresher_builds_total{result=\"success\"} %d\n", s.successes)
+	fmt.Fprintf(w, "fresher_builds_total{result=\"failure\"} %d\n", s.failures)
+	fmt.Fprintf(w, "fresher_builds_total{result=\"killed\"} %d\n", s.killed)
+
+	fmt.Fprintln(w, "# HELP fresher_build_failures_total Number of failed builds.")
+	fmt.Fprintln(w, "# TYPE fresher_build_failures_total counter")
+	fmt.Fprintf(w, "fresher_build_failures_total %d\n", s.failures)
+
+	fmt.Fprintln(w, "# HELP fresher_build_duration_seconds Duration of completed builds.")
+	fmt.Fprintln(w, "# TYPE fresher_build_duration_seconds histogram")
+	cumulative := 0
+	for i, upperBound := range buildDurationBuckets {
+		cumulative += s.durationBuckets[i]
+		fmt.Fprintf(w, "fresher_build_duration_seconds_bucket{le=\"%s\"} %d\n", strconv.FormatFloat(upperBound, 'f', -1, 64), cumulative)
+	}
+	cumulative += s.durationBuckets[len(buildDurationBuckets)]
+	fmt.Fprintf(w, "fresher_build_duration_seconds_bucket{le=\"+Inf\"} %d\n", cumulative)
+	fmt.Fprintf(w, "fresher_build_duration_seconds_sum %f\n", s.totalDuration.Seconds())
+	fmt.Fprintf(w, "fresher_build_duration_seconds_count %d\n", cumulative)
+
+	fmt.Fprintln(w, "# HELP fresher_restarts_total Number of times the binary was stopped and rerun.")
+	fmt.Fprintln(w, "# TYPE fresher_restarts_total counter")
+	fmt.Fprintf(w, "fresher_restarts_total %d\n", s.restarts)
+
+	fmt.Fprintln(w, "# HELP fresher_binary_size_bytes Size of the binary from the most recent successful build.")
+	fmt.Fprintln(w, "# TYPE fresher_binary_size_bytes gauge")
+	fmt.Fprintf(w, "fresher_binary_size_bytes %d\n", s.binarySize)
+
+	fmt.Fprintln(w, "# HELP fresher_watched_directories Number of directories watched for file changes.")
+	fmt.Fprintln(w, "# TYPE fresher_watched_directories gauge")
+	fmt.Fprintf(w, "fresher_watched_directories %d\n", s.watchedDirectories)
+
+	fmt.Fprintln(w, "# HELP fresher_uptime_seconds Time since fresher was started.")
+	fmt.Fprintln(w, "# TYPE fresher_uptime_seconds gauge")
+	fmt.Fprintf(w, "fresher_uptime_seconds %f\n", time.Since(s.startedAt).Seconds())
+}
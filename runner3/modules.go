@@ -0,0 +1,112 @@
+package runner3
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// missingModulePatterns match the errors `go build` outputs when an imported
+// package's module is missing from go.mod or go.sum, capturing the package. The error
+// depends on the -mod flag and GOPROXY, i.e.:
+//
+//	main.go:4:2: no required module provides package example.com/x; to add it:
+//	main.go:4:2: missing go.sum entry for module providing package example.com/x (imported by app); to add:
+//	main.go:4:2: cannot find module providing package example.com/x: import lookup disabled by -mod=readonly
+//	main.go:4:2: module example.com/x provides package example.com/x and is replaced but not required; to add it:
+var missingModulePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`no required module provides package (\S+);`),
+	regexp.MustCompile(`missing go\.sum entry for module providing package (\S+)`),
+	regexp.MustCompile(`cannot find module providing package (\S+):`),
+	regexp.MustCompile(`module \S+ provides package (\S+) and is replaced but not required`),
+}
+
+// goModUpdatesNeeded is output by `go build` when go.mod is out of date, i.e. a
+// requirement was removed by a teammate, rather than a package's module missing.
+const goModUpdatesNeeded = "go: updates to go.mod needed"
+
+// findMissingModules returns the packages whose modules are missing from go.mod or
+// go.sum, per the output of a failed build. ok is true if go.mod needs updating,
+// even if no packages were found.
+func findMissingModules(stderr string) (packages []string, ok bool) {
+	seen := map[string]bool{}
+	for _, line := range strings.Split(stderr, "\n") {
+		for _, re := range missingModulePatterns {
+			m := re.FindStringSubmatch(line)
+			if m == nil || seen[m[1]] {
+				continue
+			}
+
+			seen[m[1]] = true
+			packages = append(packages, m[1])
+		}
+	}
+
+	return packages, len(packages) > 0 || strings.Contains(stderr, goModUpdatesNeeded)
+}
+
+// handleMissingModules runs `go mod tidy` if a build failed since modules are
+// missing from go.mod or go.sum and OnMissingModules is "tidy". True is returned if
+// `go mod tidy` succeeded, meaning the binary should be rebuilt. Otherwise, the
+// command to run is given as a hint, see classifyBuildFailure().
+func handleMissingModules(cfg *config.File, stderr string) (rebuild bool) {
+	if cfg.OnMissingModules != config.OnMissingModulesTidy {
+		return false
+	}
+
+	packages, ok := findMissingModules(stderr)
+	if !ok {
+		return false
+	}
+
+	missing := "go.mod is out of date"
+	if len(packages) > 0 {
+		missing = "Modules missing for " + strings.Join(packages, ", ")
+	}
+
+	events.Printf("%s, running go mod tidy...", missing)
+	cmd := exec.Command("go", "mod", "tidy")
+	cmd.Dir = cfg.WorkingDir
+	cmd.Env = getBuildEnv(cfg)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		errs.Printf("go mod tidy failed %s", err)
+		return false
+	}
+
+	return true
+}
+
+// downloadModules runs `go mod download` before the first build when DownloadModules
+// is set, so that the first build isn't slowed down by downloading modules without
+// any sign of why. A failure is logged, not returned, since building will report
+// which modules are missing.
+func downloadModules() {
+	if !config.Data().DownloadModules {
+		return
+	}
+	if config.Data().GoMod == config.GoModVendor {
+		events.Verbosef("Not downloading modules, GoMod is %s", config.GoModVendor)
+		return
+	}
+
+	events.Printf("Downloading modules...")
+	start := time.Now()
+
+	cmd := exec.Command("go", "mod", "download")
+	cmd.Dir = config.Data().WorkingDir
+	cmd.Env = getBuildEnv(config.Data())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		warn.Printf("Could not download modules %s\n%s", err, strings.TrimSpace(string(out)))
+		return
+	}
+
+	events.Printf("Downloaded modules (took %s).", time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,54 @@
+package runner3
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindMissingModules(t *testing.T) {
+	tests := []struct {
+		stderr   string
+		packages []string
+		ok       bool
+	}{
+		{
+			"main.go:4:2: no required module provides package example.com/x; to add it:\n\tgo get example.com/x\n",
+			[]string{"example.com/x"},
+			true,
+		},
+		{
+			"main.go:4:2: missing go.sum entry for module providing package github.com/a/b (imported by app); to add:\n\tgo get app\n" +
+				"api.go:5:2: missing go.sum entry for module providing package github.com/a/b (imported by app/api); to add:\n\tgo get app/api\n",
+			[]string{"github.com/a/b"},
+			true,
+		},
+		{
+			"main.go:4:2: cannot find module providing package example.com/x: import lookup disabled by -mod=readonly\n",
+			[]string{"example.com/x"},
+			true,
+		},
+		{
+			"main.go:7:2: module example.com/lib provides package example.com/lib/v2 and is replaced but not required; to add it:\n\tgo get example.com/lib\n",
+			[]string{"example.com/lib/v2"},
+			true,
+		},
+		{
+			"go: updates to go.mod needed; to update it:\n\tgo mod tidy\n",
+			nil,
+			true,
+		},
+		{
+			"./main.go:10:2: undefined: x\n",
+			nil,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		packages, ok := findMissingModules(tt.stderr)
+		if ok != tt.ok || !reflect.DeepEqual(packages, tt.packages) {
+			t.Fatal("Unexpected result.", tt.stderr, packages, ok)
+			return
+		}
+	}
+}
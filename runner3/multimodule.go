@@ -0,0 +1,109 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+)
+
+// moduleListFormat is the `go list` template that outputs the directory of the module
+// each of the binary's packages is in, one per line. For a module replaced with a
+// local directory, or used via go.work, this is the local directory.
+const moduleListFormat = `{{with .Module}}{{.Dir}}{{end}}`
+
+// usedModules is the directories of the modules the binary uses, found after each
+// successful build. See SkipUnrelatedModules in the config file.
+var usedModules struct {
+	mu   sync.Mutex
+	dirs map[string]bool
+}
+
+// isInUnrelatedModule returns true if the file is in a module the binary doesn't use,
+// meaning the binary doesn't need to be rebuilt when the file changes. False is
+// returned if SkipUnrelatedModules is disabled, the modules used aren't known yet, or
+// the file isn't in a module.
+func isInUnrelatedModule(path string) bool {
+	if !config.Data().SkipUnrelatedModules {
+		return false
+	}
+
+	usedModules.mu.Lock()
+	dirs := usedModules.dirs
+	usedModules.mu.Unlock()
+	if len(dirs) == 0 {
+		return false
+	}
+
+	root := findModuleRoot(path)
+	if root == "" {
+		return false
+	}
+
+	return !dirs[root]
+}
+
+// findModuleRoot returns the absolute path to the directory of the nearest go.mod,
+// walking up from the file's directory. A blank string is returned if no go.mod is
+// found.
+func findModuleRoot(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+
+	dir := filepath.Dir(abs)
+	for {
+		if fi, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil && !fi.IsDir() {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// detectUsedModules finds the modules the binary uses, in the background, using `go
+// list`. This is run after each successful build since a module could have been added
+// to go.mod or go.work. Nothing is done if SkipUnrelatedModules is disabled.
+func detectUsedModules() {
+	if !config.Data().SkipUnrelatedModules {
+		return
+	}
+
+	go func() {
+		out, err := goListDeps(moduleListFormat)
+		if err != nil {
+			warn.Verbosef("Could not detect modules used by the binary %s", err)
+			return
+		}
+
+		dirs := parseModuleDirs(out)
+
+		usedModules.mu.Lock()
+		defer usedModules.mu.Unlock()
+		usedModules.dirs = dirs
+	}()
+}
+
+// parseModuleDirs returns the distinct module directories from the output of `go
+// list` using moduleListFormat. Packages from the standard library have no module
+// and output a blank line, which is skipped.
+func parseModuleDirs(out string) map[string]bool {
+	dirs := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		dirs[filepath.Clean(line)] = true
+	}
+
+	return dirs
+}
@@ -0,0 +1,71 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestIsInUnrelatedModule(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"api", "worker", "shared", filepath.Join("api", "handlers")} {
+		err := os.MkdirAll(filepath.Join(root, dir), 0755)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+	for _, dir := range []string{"api", "worker", "shared"} {
+		err := os.WriteFile(filepath.Join(root, dir, "go.mod"), []byte("module "+dir+"\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	if r := findModuleRoot(filepath.Join(root, "api", "handlers", "user.go")); r != filepath.Join(root, "api") {
+		t.Fatal("Nearest go.mod should be found.", r)
+		return
+	}
+	if r := findModuleRoot(filepath.Join(root, "README.md")); r != "" {
+		t.Fatal("No module should be found.", r)
+		return
+	}
+
+	out := filepath.Join(root, "api") + "\n\n" + filepath.Join(root, "shared") + "\n" + filepath.Join(root, "api") + "\n"
+	dirs := parseModuleDirs(out)
+	if len(dirs) != 2 || !dirs[filepath.Join(root, "api")] || !dirs[filepath.Join(root, "shared")] {
+		t.Fatal("Unexpected module directories.", dirs)
+		return
+	}
+
+	cfg := config.Defaults()
+	cfg.SkipUnrelatedModules = true
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	usedModules.dirs = dirs
+	defer func() { usedModules.dirs = nil }()
+
+	tests := []struct {
+		path      string
+		unrelated bool
+	}{
+		{filepath.Join(root, "api", "handlers", "user.go"), false},
+		{filepath.Join(root, "shared", "log.go"), false},
+		{filepath.Join(root, "worker", "main.go"), true},
+		{filepath.Join(root, "worker", "go.mod"), true},
+		{filepath.Join(root, "README.md"), false},
+	}
+	for _, tt := range tests {
+		if u := isInUnrelatedModule(tt.path); u != tt.unrelated {
+			t.Fatal("Unexpected result.", tt.path, u)
+			return
+		}
+	}
+}
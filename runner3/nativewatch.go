@@ -0,0 +1,23 @@
+package runner3
+
+import (
+	"errors"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// nativeWatcher is a recursive watcher using the OS's native API. A single watch on
+// the working directory reports changes to files in every subdirectory, rather than
+// needing a watch per directory as with fsnotify. This is much faster to set up, and
+// doesn't use up watches, on huge repos.
+//
+// The fields match fsnotify.Watcher so that events are handled the same way
+// regardless of which watcher is used.
+type nativeWatcher struct {
+	Events chan fsnotify.Event
+	Errors chan error
+}
+
+// errNativeWatchUnsupported is returned from newNativeWatcher() when a native
+// recursive watcher isn't implemented for this OS. fsnotify is used instead.
+var errNativeWatchUnsupported = errors.New("native watching not supported")
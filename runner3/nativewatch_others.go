@@ -0,0 +1,10 @@
+//go:build !windows
+
+package runner3
+
+// newNativeWatcher returns errNativeWatchUnsupported since a native recursive
+// watcher is only implemented for Windows. FSEvents on macOS requires cgo, which
+// fresher avoids so that it can be installed with just `go install`.
+func newNativeWatcher(root string) (w *nativeWatcher, err error) {
+	return nil, errNativeWatchUnsupported
+}
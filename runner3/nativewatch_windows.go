@@ -0,0 +1,107 @@
+//go:build windows
+
+package runner3
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// nativeWatchBufferSize is the size of the buffer ReadDirectoryChangesW writes
+// changes to. Changes are lost if more occur, between reads, than fit in the buffer.
+// 64KB is the largest size that works with network shares.
+const nativeWatchBufferSize = 64 * 1024
+
+// newNativeWatcher watches root, and all its subdirectories, using
+// ReadDirectoryChangesW with bWatchSubtree set.
+func newNativeWatcher(root string) (w *nativeWatcher, err error) {
+	p, err := syscall.UTF16PtrFromString(root)
+	if err != nil {
+		return
+	}
+
+	h, err := syscall.CreateFile(
+		p,
+		syscall.FILE_LIST_DIRECTORY,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil,
+		syscall.OPEN_EXISTING,
+		syscall.FILE_FLAG_BACKUP_SEMANTICS, //required to open a directory.
+		0,
+	)
+	if err != nil {
+		return
+	}
+
+	w = &nativeWatcher{
+		Events: make(chan fsnotify.Event, 100),
+		Errors: make(chan error, 1),
+	}
+
+	go w.readChanges(h, root)
+	return
+}
+
+// readChanges reads changes from the directory handle and sends them as events until
+// an error occurs. This blocks, so call it in a goroutine.
+func (w *nativeWatcher) readChanges(h syscall.Handle, root string) {
+	defer syscall.CloseHandle(h)
+
+	const mask = syscall.FILE_NOTIFY_CHANGE_FILE_NAME |
+		syscall.FILE_NOTIFY_CHANGE_DIR_NAME |
+		syscall.FILE_NOTIFY_CHANGE_SIZE |
+		syscall.FILE_NOTIFY_CHANGE_LAST_WRITE |
+		syscall.FILE_NOTIFY_CHANGE_CREATION
+
+	buf := make([]byte, nativeWatchBufferSize)
+	for {
+		var n uint32
+		err := syscall.ReadDirectoryChanges(h, &buf[0], uint32(len(buf)), true, mask, &n, nil, 0)
+		if err != nil {
+			w.Errors <- err
+			return
+		}
+
+		//Zero bytes returned means the buffer overflowed and the changes were lost.
+		if n == 0 {
+			w.Errors <- errors.New("native watcher buffer overflowed, some file changes were missed")
+			continue
+		}
+
+		//The buffer holds a list of FILE_NOTIFY_INFORMATION structs, each with a
+		//variable length, UTF-16, file name relative to root.
+		offset := uint32(0)
+		for {
+			info := (*syscall.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+			name := unsafe.Slice(&info.FileName, info.FileNameLength/2)
+
+			w.Events <- fsnotify.Event{
+				Name: filepath.Join(root, syscall.UTF16ToString(name)),
+				Op:   nativeActionToOp(info.Action),
+			}
+
+			if info.NextEntryOffset == 0 {
+				break
+			}
+			offset += info.NextEntryOffset
+		}
+	}
+}
+
+// nativeActionToOp converts a FILE_ACTION_... to the matching fsnotify.Op.
+func nativeActionToOp(action uint32) fsnotify.Op {
+	switch action {
+	case syscall.FILE_ACTION_ADDED, syscall.FILE_ACTION_RENAMED_NEW_NAME:
+		return fsnotify.Create
+	case syscall.FILE_ACTION_REMOVED:
+		return fsnotify.Remove
+	case syscall.FILE_ACTION_RENAMED_OLD_NAME:
+		return fsnotify.Rename
+	default:
+		return fsnotify.Write
+	}
+}
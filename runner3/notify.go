@@ -0,0 +1,120 @@
+package runner3
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/c9845/fresher/config"
+)
+
+// previousBuildFailed is set when a build fails and reset when a build succeeds. This
+// is used to notify the user when a build succeeds after a failure (a "recovery")
+// without notifying on every successful build, which would just be noise.
+var previousBuildFailed bool
+
+// handleBuildFailed is called in start() when a build fails. This alerts the user
+// per the config file's settings since the user may not be watching fresher's output.
+func handleBuildFailed(buildErrs []buildError) {
+	previousBuildFailed = true
+
+	message := "Build failed"
+	if len(buildErrs) > 0 {
+		message = fmt.Sprintf("Build failed: %s", buildErrs[0].String())
+	}
+
+	if config.Data().NotifyOnBuildResult {
+		sendDesktopNotification("fresher", message)
+	}
+
+	if config.Data().BellOnError {
+		ringBell()
+	}
+}
+
+// handleBuildSucceeded is called in start() when a build succeeds. The user is only
+// alerted if the previous build failed.
+func handleBuildSucceeded() {
+	if !previousBuildFailed {
+		return
+	}
+	previousBuildFailed = false
+
+	if config.Data().NotifyOnBuildResult {
+		sendDesktopNotification("fresher", "Build succeeded, binary is running again")
+	}
+
+	if config.Data().BellOnError && config.Data().BellOnRecovery {
+		ringBell()
+	}
+}
+
+// ringBell alerts the user audibly. This writes the terminal bell character, or runs
+// the command in the config file's BellCommand field if one is set (for example, to
+// play a sound file since many terminals have the bell muted).
+func ringBell() {
+	bellCommand := strings.Fields(config.Data().BellCommand)
+	if len(bellCommand) == 0 {
+		fmt.Fprint(os.Stderr, "\a")
+		return
+	}
+
+	go func() {
+		err := exec.Command(bellCommand[0], bellCommand[1:]...).Run()
+		if err != nil {
+			errs.Printf("Could not run BellCommand %s", err)
+		}
+	}()
+}
+
+// sendDesktopNotification shows a native desktop notification using the tools that
+// typically come with each OS. This is done instead of using a third-party library
+// to keep fresher's dependencies to a minimum.
+//
+// The notification is sent asynchronously since we don't want to delay building or
+// running the binary. Errors are only logged since a missed notification isn't an
+// end-of-the-world event.
+func sendDesktopNotification(title, message string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.Command("notify-send", title, message)
+
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, title)
+		cmd = exec.Command("osascript", "-e", script)
+
+	case "windows":
+		//Show a balloon tip from a temporary tray icon, since this works on all
+		//Windows versions without installing a PowerShell module.
+		script := fmt.Sprintf(`Add-Type -AssemblyName System.Windows.Forms; `+
+			`$n = New-Object System.Windows.Forms.NotifyIcon; `+
+			`$n.Icon = [System.Drawing.SystemIcons]::Information; `+
+			`$n.Visible = $true; `+
+			`$n.ShowBalloonTip(5000, '%s', '%s', 'None'); `+
+			`Start-Sleep -Seconds 5; $n.Dispose()`,
+			escapePowershell(title),
+			escapePowershell(message),
+		)
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", script)
+
+	default:
+		warn.Verbosef("Desktop notifications not supported on %s", runtime.GOOS)
+		return
+	}
+
+	go func() {
+		err := cmd.Run()
+		if err != nil {
+			errs.Printf("Could not send desktop notification %s", err)
+		}
+	}()
+}
+
+// escapePowershell escapes single quotes for use in a single quoted PowerShell
+// string.
+func escapePowershell(s string) string {
+	return strings.ReplaceAll(s, "'", "''")
+}
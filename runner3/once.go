@@ -0,0 +1,96 @@
+package runner3
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Once builds and runs the binary a single time, without watching for file changes,
+// and exits fresher with the binary's exit code. This is used by the -once flag so
+// that CI smoke tests and scripts can reuse the exact build configuration from the
+// config file rather than duplicating it in a `go build` command.
+//
+// Once never returns; fresher always exits when the binary exits.
+func Once() {
+	downloadModules()
+
+	code := buildAndRunOnce()
+	removePIDFile()
+	removeStatusFile()
+	os.Exit(code)
+}
+
+// buildAndRunOnce builds the binary with the activeBuilder and runs it with the
+// activeRunner, returning the exit code fresher should exit with. This is separate
+// from Once() so that it can be tested with a fake builder and runner.
+func buildAndRunOnce() int {
+	//Build the binary. There isn't a file change event that triggered the build,
+	//so the same event as used in Start() is used.
+	err := activeBuilder.Build(config.Data(), fsnotify.Event{Name: initialEventName, Op: fsnotify.Write})
+	if err != nil {
+		errs.Printf("Build Failed %s", err)
+		return 1
+	}
+
+	code := runOnce()
+	events.Printf("Binary exited with code %d", code)
+	return code
+}
+
+// runOnce runs the built binary and blocks until it exits, returning the binary's
+// exit code. An interrupt sent to fresher stops the binary, the same as when watching
+// for file changes.
+func runOnce() int {
+	commands, err := startRunCommands()
+	if err != nil {
+		errs.Printf("Binary not run, %s", err)
+		return 1
+	}
+	defer stopRunCommands(commands)
+
+	cmd := activeRunner.Command(config.Data())
+	events.Printf("Running...")
+
+	p, err := startProcess(cmd, "", nil)
+	if err != nil {
+		errs.Printf("Could not run binary %s", err)
+		return 1
+	}
+	setBinaryPID(cmd.Process.Pid, cmd.Path)
+
+	//Stop the binary if fresher is interrupted so that the binary isn't left running.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sig:
+			killProcessTree(cmd.Process)
+		case <-p.exited:
+		}
+	}()
+
+	<-p.exited
+	err = p.err
+
+	//A non-zero exit code isn't an error we need to log, the code is just returned.
+	//A binary killed by a signal has an exit code of -1, which isn't a valid exit
+	//code, so 1 is used instead.
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		errs.Printf("Binary exited %s", err)
+		return 1
+	}
+
+	code := cmd.ProcessState.ExitCode()
+	if code < 0 {
+		return 1
+	}
+
+	return code
+}
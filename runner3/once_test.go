@@ -0,0 +1,73 @@
+package runner3
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// fakeBuilder is a builder that doesn't need a Go toolchain. The events it was asked
+// to build for are saved so tests can check them.
+type fakeBuilder struct {
+	err    error
+	events []fsnotify.Event
+}
+
+func (b *fakeBuilder) Build(cfg *config.File, event fsnotify.Event) error {
+	b.events = append(b.events, event)
+	return b.err
+}
+
+// fakeRunner is a processRunner that runs a helper process, see TestHelperProcess,
+// rather than a built binary.
+type fakeRunner struct {
+	mode string
+}
+
+func (r fakeRunner) Command(cfg *config.File) *exec.Cmd {
+	return helperCommand(r.mode)
+}
+
+// useFakeEngine replaces the activeBuilder and activeRunner for the duration of a
+// test.
+func useFakeEngine(t *testing.T, b builder, r processRunner) {
+	prevBuilder, prevRunner := activeBuilder, activeRunner
+	activeBuilder, activeRunner = b, r
+	t.Cleanup(func() {
+		activeBuilder, activeRunner = prevBuilder, prevRunner
+	})
+}
+
+func TestBuildAndRunOnce(t *testing.T) {
+	config.UseDefaults()
+	withoutOutput(t)
+
+	//The binary's exit code is returned.
+	b := &fakeBuilder{}
+	useFakeEngine(t, b, fakeRunner{mode: "exit"})
+	if code := buildAndRunOnce(); code != 3 {
+		t.Fatal("Exit code of binary should have been returned.", code)
+		return
+	}
+	if len(b.events) != 1 || b.events[0].Name != initialEventName {
+		t.Fatal("Binary should have been built once for the initial event.", b.events)
+		return
+	}
+
+	//A failed build isn't run.
+	b = &fakeBuilder{err: errBuildFailed}
+	useFakeEngine(t, b, fakeRunner{mode: "succeed"})
+	if code := buildAndRunOnce(); code != 1 {
+		t.Fatal("Failed build should exit with 1.", code)
+		return
+	}
+
+	b = &fakeBuilder{}
+	useFakeEngine(t, b, fakeRunner{mode: "succeed"})
+	if code := buildAndRunOnce(); code != 0 {
+		t.Fatal("Successful run should exit with 0.", code)
+		return
+	}
+}
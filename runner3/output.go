@@ -0,0 +1,253 @@
+package runner3
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// outputMu is used to write whole lines of the running binary's stdout and stderr
+// one at a time, so that partial lines from each stream don't get jumbled together.
+var outputMu sync.Mutex
+
+// outputTimestampFormat matches the timestamp format of fresher's logging so that
+// the binary's output can easily be correlated with file change events.
+const outputTimestampFormat = "2006/01/02 15:04:05 "
+
+// outputFilter holds the compiled regular expressions from the config file's
+// OutputFilters field. This is populated in compileOutputFilters().
+var outputFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// compileOutputFilters compiles the OutputFilters regular expressions so that they
+// don't need to be compiled for each line of output. The patterns were validated
+// when the config file was read so errors should not occur here.
+func compileOutputFilters() (err error) {
+	for _, pattern := range config.Data().OutputFilters.Include {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		outputFilter.include = append(outputFilter.include, re)
+	}
+
+	for _, pattern := range config.Data().OutputFilters.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		outputFilter.exclude = append(outputFilter.exclude, re)
+	}
+
+	return
+}
+
+// isOutputFiltered returns true if the line of output from the running binary should
+// not be shown to the user. If any Include patterns are given, only lines matching
+// at least one Include pattern are shown. Lines matching any Exclude pattern are
+// never shown.
+func isOutputFiltered(line string) bool {
+	if len(outputFilter.include) > 0 {
+		included := false
+		for _, re := range outputFilter.include {
+			if re.MatchString(line) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return true
+		}
+	}
+
+	for _, re := range outputFilter.exclude {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// copyOutput copies the running binary's output from r to w. The output is handled
+// line by line when any of the OutputPrefix, OutputTimestamps, OutputLineBuffered,
+// or OutputFilters config file fields are set:
+//   - An OutputPrefix is added to each line so the binary's output can be told apart
+//     from fresher's logging. The prefix is colored differently for stdout and stderr
+//     so that errors from the binary stand out.
+//   - A timestamp is added to each line if OutputTimestamps is set.
+//   - Whole lines are written at once so stdout and stderr don't get jumbled.
+//   - Lines are hidden per the OutputFilters.
+//   - Panics in stderr are colored and framed if HighlightPanics is set.
+//
+// For a RunCommand, name is the command's name and is used as the prefix in place of
+// the OutputPrefix so that the output of each command can be told apart.
+//
+// If none of these fields are set, and name is blank, the output is copied as-is, the same as fresher
+// always has. This is a bit faster and doesn't delay output that doesn't end in a
+// newline (i.e.: prompts).
+//
+// This blocks until r is closed, i.e.: the binary exits, so call it in a goroutine.
+func copyOutput(w io.Writer, r io.Reader, isStderr bool, name string) {
+	cfg := config.Data()
+	filtering := len(outputFilter.include) > 0 || len(outputFilter.exclude) > 0
+	highlighting := isStderr && cfg.HighlightPanics
+	outputPrefix := cfg.OutputPrefix
+	if name != "" {
+		outputPrefix = name
+	}
+	if outputPrefix == "" && !cfg.OutputTimestamps && !cfg.OutputLineBuffered && !filtering && !highlighting {
+		io.Copy(w, r)
+		return
+	}
+
+	//Build the prefix once, not on every line.
+	prefix := formatOutputPrefix(outputPrefix, isStderr)
+
+	writeLine := func(line string) {
+		timestamp := ""
+		if cfg.OutputTimestamps {
+			timestamp = time.Now().Format(outputTimestampFormat)
+		}
+
+		outputMu.Lock()
+		io.WriteString(w, timestamp+prefix+line)
+		outputMu.Unlock()
+	}
+
+	var panics *panicHighlighter
+	if highlighting {
+		panics = newPanicHighlighter()
+	}
+
+	//Read line by line so that the prefix can be added to the start of each line.
+	//ReadString is used, rather than a bufio.Scanner, since a Scanner fails on very
+	//long lines.
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+
+		//Panics are never filtered so that crashes are always shown.
+		show := !(filtering && isOutputFiltered(line))
+		if len(line) > 0 && panics != nil {
+			if panics.ends(line) {
+				writeLine(panics.frame())
+				panics.report()
+				panics.clear()
+			}
+
+			started := panics.panicking()
+
+			var panicking bool
+			line, panicking = panics.format(line)
+			show = show || panicking
+
+			if panicking && !started {
+				writeLine(panics.frame())
+			}
+		}
+
+		if len(line) > 0 && show {
+			writeLine(line)
+		}
+		if err != nil {
+			if panics != nil && panics.panicking() {
+				writeLine(panics.frame())
+				panics.report()
+			}
+			return
+		}
+	}
+}
+
+// formatOutputPrefix returns the prefix added to each line of output, i.e. "app | ",
+// colored differently for stdout and stderr so that errors stand out. A blank string
+// is returned if name is blank.
+func formatOutputPrefix(name string, isStderr bool) string {
+	if name == "" {
+		return ""
+	}
+
+	cfg := config.Data()
+	color := cfg.Colors.Output
+	if isStderr {
+		color = cfg.Colors.OutputErrors
+	}
+	if cfg.UseColors() {
+		return fmt.Sprintf("%s%s |%s ", getColorCode(color), name, resetColorCode)
+	}
+
+	return fmt.Sprintf("%s | ", name)
+}
+
+// buildOutputName is the prefix added to each line of output from `go build` so that
+// it can be told apart from fresher's logging and the binary's output.
+const buildOutputName = "build"
+
+// buildProgressPrefixes are the starts of lines `go build` writes to stderr while
+// preparing to build, i.e. downloading modules, that are shown as they are written
+// even when the build isn't verbose so that a long build doesn't appear to hang.
+var buildProgressPrefixes = []string{
+	"go: downloading ",
+	"go: extracting ",
+	"go: finding ",
+}
+
+// streamBuildOutput copies the output from `go build` in r to w line by line, as it is
+// written, with a prefix added to each line. Whole lines are written at once so that
+// lines from stdout and stderr don't get jumbled together. If capture isn't nil, the
+// output is also saved to it as-is.
+//
+// stdout is always shown. stderr is only shown if verbose is true, or for
+// progress lines (see buildProgressPrefixes), since errors from a failed build are
+// parsed and output once the build completes, see printBuildErrors().
+//
+// This blocks until r is closed, i.e.: `go build` exits.
+func streamBuildOutput(w io.Writer, r io.Reader, isStderr, verbose bool, capture *bytes.Buffer) {
+	prefix := formatOutputPrefix(buildOutputName, isStderr)
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if capture != nil {
+			capture.WriteString(line)
+		}
+		if isStderr && strings.HasPrefix(line, "go: downloading ") && status.setFetchingModules() {
+			events.Printf("Fetching dependencies, downloading modules...")
+		}
+
+		if len(line) > 0 && (!isStderr || verbose || isBuildProgress(line)) {
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+
+			outputMu.Lock()
+			io.WriteString(w, prefix+line)
+			outputMu.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// isBuildProgress returns true if the line from `go build`'s stderr notes progress
+// rather than an error, see buildProgressPrefixes.
+func isBuildProgress(line string) bool {
+	for _, p := range buildProgressPrefixes {
+		if strings.HasPrefix(line, p) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package runner3
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestStreamBuildOutput(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.Colors.Disabled = true
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	stderr := "go: downloading example.com/x v1.0.0\n# app\n./main.go:4:2: undefined: x"
+
+	var shown, captured bytes.Buffer
+	streamBuildOutput(&shown, strings.NewReader(stderr), true, false, &captured)
+
+	//All of stderr should be captured, as-is, for the build errors log.
+	if captured.String() != stderr {
+		t.Fatal("Unexpected captured output.", captured.String())
+		return
+	}
+
+	//Only progress should be shown since errors are output once the build fails.
+	if shown.String() != "build | go: downloading example.com/x v1.0.0\n" {
+		t.Fatal("Unexpected output shown.", shown.String())
+		return
+	}
+
+	//stdout is always shown, with a trailing newline added if missing.
+	shown.Reset()
+	streamBuildOutput(&shown, strings.NewReader("line 1\nline 2"), false, false, nil)
+	if shown.String() != "build | line 1\nbuild | line 2\n" {
+		t.Fatal("Unexpected stdout shown.", shown.String())
+		return
+	}
+}
+
+func TestStreamBuildOutputFetching(t *testing.T) {
+	config.UseDefaults()
+	withoutOutput(t)
+
+	status.setBuilding()
+	defer status.setBuildResult(nil, nil)
+
+	var shown bytes.Buffer
+	streamBuildOutput(&shown, strings.NewReader("# app\n"), true, false, nil)
+	if s := status.snapshot(); s.state() != "building" {
+		t.Fatal("Build should not be fetching modules.", s.state())
+		return
+	}
+
+	streamBuildOutput(&shown, strings.NewReader("go: downloading example.com/x v1.0.0\n"), true, false, nil)
+	if s := status.snapshot(); s.state() != "fetching" {
+		t.Fatal("Build should be fetching modules.", s.state())
+		return
+	}
+
+	status.setBuildResult(nil, nil)
+	if s := status.snapshot(); s.FetchingModules {
+		t.Fatal("Fetching modules should be cleared once the build completes.")
+		return
+	}
+}
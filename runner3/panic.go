@@ -0,0 +1,177 @@
+package runner3
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/c9845/fresher/config"
+)
+
+// panicPrefixes are the prefixes of the first line the Go runtime outputs when a
+// goroutine panics or the runtime hits a fatal error, i.e. concurrent map writes.
+var panicPrefixes = []string{"panic: ", "fatal error: "}
+
+// maxPanicLines is the most lines treated as part of a single panic. This stops a
+// binary that printed something that looked like a panic, but kept running, from
+// having all of its following output colored and kept in memory.
+const maxPanicLines = 1000
+
+// panicHighlighter detects a panic in the running binary's stderr, line by line, and
+// colors the panic and stack trace so that crashes stand out. Once a panic is seen,
+// the following lines are treated as part of the panic until the panicking
+// goroutine's stack trace ends with a blank line, see ends(), or the binary exits.
+type panicHighlighter struct {
+	color string
+	reset string
+
+	//lines are the lines of the panic and stack trace, without newlines.
+	lines []string
+
+	//inTrace is set once the panicking goroutine's stack trace has started.
+	inTrace bool
+}
+
+// newPanicHighlighter returns a panicHighlighter using the Errors color.
+func newPanicHighlighter() *panicHighlighter {
+	h := &panicHighlighter{}
+	if cfg := config.Data(); cfg.UseColors() {
+		h.color = getColorCode(cfg.Colors.Errors)
+		h.reset = resetColorCode
+	}
+
+	return h
+}
+
+// format returns the line as it should be output and if the line is part of a panic.
+// Lines that are part of a panic are colored.
+func (h *panicHighlighter) format(line string) (out string, panicking bool) {
+	if !h.panicking() && !isPanicLine(line) {
+		return line, false
+	}
+
+	text := strings.TrimSuffix(line, "\n")
+	h.lines = append(h.lines, text)
+	if strings.HasPrefix(text, "goroutine ") {
+		h.inTrace = true
+	}
+
+	//Keep the newline outside of the color so that an unterminated last line
+	//doesn't gain one.
+	return h.color + text + h.reset + line[len(text):], true
+}
+
+// panicking returns true once a panic has been seen.
+func (h *panicHighlighter) panicking() bool {
+	return len(h.lines) > 0
+}
+
+// ends returns true if the line is after the end of the panic, meaning it isn't part
+// of the panic. This is the blank line after the panicking goroutine's stack trace,
+// the runtime may print other goroutines' stack traces after it, or any line once
+// maxPanicLines have been seen.
+func (h *panicHighlighter) ends(line string) bool {
+	if !h.panicking() {
+		return false
+	}
+
+	return (h.inTrace && strings.TrimSpace(line) == "") || len(h.lines) >= maxPanicLines
+}
+
+// clear forgets the panic once it has ended so that another panic can be detected.
+func (h *panicHighlighter) clear() {
+	h.lines = nil
+	h.inTrace = false
+}
+
+// frame returns the line output before and after a panic.
+func (h *panicHighlighter) frame() string {
+	return h.color + strings.Repeat("=", 50) + h.reset + "\n"
+}
+
+// report logs where the panic occurred and opens the file in the user's editor, if
+// enabled. This is called once the panic ends or the binary's stderr is closed, i.e.
+// the binary exited.
+func (h *panicHighlighter) report() {
+	file, line, ok := panicLocation(h.lines)
+	if !ok {
+		return
+	}
+	errs.Printf("Binary panicked at %s:%d", file, line)
+
+	if config.Data().OnPanicOpenEditor {
+		openEditor([]buildError{{File: file, Line: line, Message: panicMessage(h.lines[0])}})
+	}
+}
+
+// isPanicLine returns true if the line is the first line of a panic.
+func isPanicLine(line string) bool {
+	for _, prefix := range panicPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// panicMessage returns the panic's message from the first line of a panic.
+func panicMessage(line string) string {
+	for _, prefix := range panicPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return strings.TrimPrefix(line, prefix)
+		}
+	}
+
+	return line
+}
+
+// panicLocation returns the file and line a panic occurred at from the panic's stack
+// trace. This is the first frame of the panicking goroutine that isn't in the runtime.
+//
+// Each frame of a stack trace is two lines, the function and then the file and line:
+//
+//	goroutine 1 [running]:
+//	main.main()
+//		/path/to/main.go:10 +0x25
+func panicLocation(lines []string) (file string, line int, ok bool) {
+	for i, l := range lines {
+		if !strings.HasPrefix(l, "goroutine ") || !strings.HasSuffix(l, ":") {
+			continue
+		}
+
+		//Only the first goroutine is checked, it is the goroutine that panicked.
+		for j := i + 1; j+1 < len(lines) && lines[j] != ""; j += 2 {
+			function := lines[j]
+			if strings.HasPrefix(function, "panic(") || strings.HasPrefix(function, "runtime.") {
+				continue
+			}
+
+			return parseFrameLocation(lines[j+1])
+		}
+
+		break
+	}
+
+	return "", 0, false
+}
+
+// parseFrameLocation returns the file and line from the second line of a stack
+// trace frame, i.e. "\t/path/to/main.go:10 +0x25".
+func parseFrameLocation(s string) (file string, line int, ok bool) {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, " +0x"); i >= 0 {
+		s = s[:i]
+	}
+
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return "", 0, false
+	}
+
+	line, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+
+	return s[:i], line, true
+}
@@ -0,0 +1,152 @@
+package runner3
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+// testPanic is the stderr output of a binary that called panic().
+const testPanic = `starting
+panic: boom
+
+goroutine 1 [running]:
+panic({0x4a1f20?, 0x4e6f30?})
+	/usr/local/go/src/runtime/panic.go:804 +0x168
+main.handle(...)
+	/home/user/app/handler.go:42
+main.main()
+	/home/user/app/main.go:10 +0x25
+exit status 2
+`
+
+// testNilPanic is the stderr output of a binary that dereferenced a nil pointer.
+const testNilPanic = `panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x45a2b4]
+
+goroutine 7 [running]:
+main.(*server).serve(0x0)
+	C:/Users/user/app/server.go:17 +0x14
+created by main.main in goroutine 1
+	C:/Users/user/app/main.go:9 +0x25
+`
+
+func TestPanicLocation(t *testing.T) {
+	tests := []struct {
+		output string
+		file   string
+		line   int
+	}{
+		{testPanic, "/home/user/app/handler.go", 42},
+		{testNilPanic, "C:/Users/user/app/server.go", 17},
+	}
+
+	for _, tt := range tests {
+		h := &panicHighlighter{}
+		for _, line := range strings.SplitAfter(tt.output, "\n") {
+			h.format(line)
+		}
+
+		file, line, ok := panicLocation(h.lines)
+		if !ok {
+			t.Fatal("Location not found.", tt.file)
+			return
+		}
+		if file != tt.file || line != tt.line {
+			t.Fatal("Unexpected location.", file, line)
+			return
+		}
+	}
+}
+
+func TestPanicHighlighterFormat(t *testing.T) {
+	h := &panicHighlighter{color: "<", reset: ">"}
+
+	out, panicking := h.format("starting\n")
+	if out != "starting\n" || panicking {
+		t.Fatal("Line before panic should not be modified.", out)
+		return
+	}
+
+	out, panicking = h.format("panic: boom\n")
+	if out != "<panic: boom>\n" || !panicking {
+		t.Fatal("Panic should be colored.", out)
+		return
+	}
+
+	out, panicking = h.format("main.main()")
+	if out != "<main.main()>" || !panicking {
+		t.Fatal("Lines after panic should be colored.", out)
+		return
+	}
+
+	if panicMessage(h.lines[0]) != "boom" {
+		t.Fatal("Unexpected panic message.", panicMessage(h.lines[0]))
+		return
+	}
+}
+
+func TestCopyOutputPanicEnds(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.HighlightPanics = true
+	cfg.Colors.Disabled = true
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//The runtime prints other goroutines' stack traces after the panicking
+	//goroutine's, i.e. with GOTRACEBACK=all, and output after those isn't part of
+	//the panic.
+	trace := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:10 +0x25\n"
+	after := "\ngoroutine 2 [select]:\nmain.worker()\n\t/app/worker.go:5 +0x10\nstill running\n"
+
+	var out bytes.Buffer
+	copyOutput(&out, strings.NewReader("starting\n"+trace+after), true, "")
+
+	frame := (&panicHighlighter{}).frame()
+	expected := "starting\n" + frame + trace + frame + after
+	if out.String() != expected {
+		t.Fatal("Panic should be framed until the end of its stack trace.", out.String())
+		return
+	}
+}
+
+func TestPanicHighlighterEnds(t *testing.T) {
+	h := &panicHighlighter{}
+	if h.ends("\n") {
+		t.Fatal("Nothing to end before a panic.")
+		return
+	}
+
+	//A blank line before the stack trace starts is part of the panic.
+	h.format("panic: boom\n")
+	if h.ends("\n") {
+		t.Fatal("Blank line before the stack trace should not end the panic.")
+		return
+	}
+	h.format("\n")
+	h.format("goroutine 1 [running]:\n")
+	if !h.ends("\n") {
+		t.Fatal("Blank line after the stack trace should end the panic.")
+		return
+	}
+
+	//Lines are capped in case the binary kept running.
+	h.clear()
+	h.format("fatal error: not really\n")
+	for i := 1; i < maxPanicLines; i++ {
+		if h.ends("more output\n") {
+			t.Fatal("Panic ended too early.", i)
+			return
+		}
+		h.format("more output\n")
+	}
+	if !h.ends("more output\n") {
+		t.Fatal("Panic should end at maxPanicLines.")
+		return
+	}
+}
@@ -0,0 +1,153 @@
+package runner3
+
+import (
+	"sync"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchPause tracks if handling file changes is paused, i.e. during a git rebase or a
+// large refactor, so that the binary isn't rebuilt for every change. Watching is
+// paused and resumed by typing "p" followed by enter or via the control API.
+//
+// While paused, the debouncer holds events rather than sending them on the
+// eventsChan, see hold(). Requests to rebuild or restart, i.e. via the control API,
+// aren't held since they are made on purpose.
+type watchPause struct {
+	mu     sync.Mutex
+	paused bool
+
+	//queued is the last event held while paused, sent on the eventsChan when
+	//resumed. This is nil if no files changed, or PausedChanges is "drop".
+	queued *fsnotify.Event
+
+	//held is the number of events held while paused, used for logging.
+	held int
+
+	//rebuild is true if any event held while paused requires a rebuild. The queued
+	//event alone may only require a rerun, i.e. an .html file changed after a .go
+	//file.
+	rebuild bool
+}
+
+// pausing is the package level pause state.
+var pausing watchPause
+
+// pause stops handling file changes. False is returned if already paused.
+func (p *watchPause) pause() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		return false
+	}
+
+	p.paused = true
+	p.queued = nil
+	p.held = 0
+	p.rebuild = false
+	status.setPaused(true)
+	return true
+}
+
+// resume resumes handling file changes. The event queued while paused, if any, is
+// returned so it can be sent on the eventsChan. If an earlier held event required a
+// rebuild, but the queued event doesn't, a rebuildEventName event is returned in its
+// place so that the earlier changes aren't lost. False is returned if not paused.
+func (p *watchPause) resume() (queued *fsnotify.Event, held int, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return nil, 0, false
+	}
+
+	queued, held = p.queued, p.held
+	if queued != nil && p.rebuild && !isRebuildRequired(*queued) {
+		queued = &fsnotify.Event{Name: rebuildEventName, Op: fsnotify.Write}
+	}
+
+	p.paused = false
+	p.queued = nil
+	p.held = 0
+	p.rebuild = false
+	status.setPaused(false)
+	return queued, held, true
+}
+
+// hold returns true if the event should not be sent since handling file changes is
+// paused. The event is queued, replacing any previously queued event since a single
+// rebuild handles every change, unless PausedChanges is "drop".
+func (p *watchPause) hold(event fsnotify.Event) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return false
+	}
+
+	p.held++
+	if config.Data().PausedChanges != config.PausedChangesDrop {
+		p.queued = &event
+		p.rebuild = p.rebuild || isRebuildRequired(event)
+	}
+	return true
+}
+
+// isPaused returns true if handling file changes is paused.
+func (p *watchPause) isPaused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.paused
+}
+
+// pauseWatching pauses handling file changes and logs that watching is paused.
+func pauseWatching() {
+	if !pausing.pause() {
+		return
+	}
+
+	warn.Printf("Watching paused, file changes will be %s. Type p and press enter to resume.", pausedChangesVerb())
+}
+
+// resumeWatching resumes handling file changes and rebuilds, if changes were queued
+// while paused.
+func resumeWatching() {
+	queued, held, ok := pausing.resume()
+	if !ok {
+		return
+	}
+
+	if queued == nil {
+		if held > 0 {
+			events.Printf("Watching resumed, dropped %s made while paused.", pluralize(held, "change"))
+		} else {
+			events.Printf("Watching resumed.")
+		}
+		return
+	}
+
+	events.Printf("Watching resumed, %s made while paused.", pluralize(held, "change"))
+	sendEvent(*queued)
+}
+
+// togglePause pauses handling file changes if not paused, otherwise resumes.
+func togglePause() {
+	if pausing.isPaused() {
+		resumeWatching()
+		return
+	}
+
+	pauseWatching()
+}
+
+// pausedChangesVerb returns how file changes are handled while paused, for logging.
+func pausedChangesVerb() string {
+	if config.Data().PausedChanges == config.PausedChangesDrop {
+		return "ignored"
+	}
+
+	return "queued"
+}
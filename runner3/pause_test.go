@@ -0,0 +1,91 @@
+package runner3
+
+import (
+	"testing"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestWatchPause(t *testing.T) {
+	config.UseDefaults()
+
+	var p watchPause
+	event := fsnotify.Event{Name: "main.go", Op: fsnotify.Write}
+	if p.hold(event) {
+		t.Fatal("Event should not be held when not paused.")
+		return
+	}
+
+	if !p.pause() {
+		t.Fatal("Pausing should succeed.")
+		return
+	}
+	if p.pause() {
+		t.Fatal("Pausing twice should be ignored.")
+		return
+	}
+	if s := status.snapshot(); s.state() != "paused" {
+		t.Fatal("Status should be paused.", s.state())
+		return
+	}
+
+	if !p.hold(fsnotify.Event{Name: "other.go", Op: fsnotify.Write}) || !p.hold(event) {
+		t.Fatal("Events should be held when paused.")
+		return
+	}
+
+	queued, held, ok := p.resume()
+	if !ok {
+		t.Fatal("Resuming should succeed.")
+		return
+	}
+	if queued == nil || *queued != event || held != 2 {
+		t.Fatal("The last held event should be queued.", queued, held)
+		return
+	}
+	if _, _, ok := p.resume(); ok {
+		t.Fatal("Resuming twice should be ignored.")
+		return
+	}
+	if s := status.snapshot(); s.Paused {
+		t.Fatal("Status should not be paused.")
+		return
+	}
+
+	//A rebuild is queued if any held event required one, even if the last held
+	//event only requires a rerun.
+	p.pause()
+	p.hold(event)
+	p.hold(fsnotify.Event{Name: "index.html", Op: fsnotify.Write})
+	queued, _, _ = p.resume()
+	if queued == nil || queued.Name != rebuildEventName {
+		t.Fatal("A rebuild should be queued.", queued)
+		return
+	}
+
+	p.pause()
+	p.hold(fsnotify.Event{Name: "index.html", Op: fsnotify.Write})
+	queued, _, _ = p.resume()
+	if queued == nil || queued.Name != "index.html" {
+		t.Fatal("Only a rerun should be queued.", queued)
+		return
+	}
+
+	//Changes are counted, but not queued, when dropping.
+	cfg := config.Defaults()
+	cfg.PausedChanges = config.PausedChangesDrop
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	p.pause()
+	p.hold(event)
+	queued, held, _ = p.resume()
+	if queued != nil || held != 1 {
+		t.Fatal("No event should be queued when dropping.", queued, held)
+		return
+	}
+}
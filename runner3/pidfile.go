@@ -0,0 +1,196 @@
+package runner3
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// pidFileData is the data saved to the PID file. Both fresher's PID and the running
+// binary's PID are saved so that a binary left running by a fresher that crashed, or
+// was killed, can be found and stopped.
+//
+// The path to each process's executable is saved as well since PIDs are reused by the
+// OS. A PID is only acted on if the process is still running the same executable, see
+// isSameProcess().
+type pidFileData struct {
+	Fresher     int    `json:"fresher"`
+	FresherPath string `json:"fresherPath,omitempty"`
+	Binary      int    `json:"binary,omitempty"`
+	BinaryPath  string `json:"binaryPath,omitempty"`
+}
+
+// pidFileMu protects writing to the PID file since the binary's PID is updated each
+// time the binary is run.
+var pidFileMu sync.Mutex
+
+// errAlreadyRunning is returned when another fresher is running in the same
+// directory and OnAlreadyRunning is set to refuse.
+var errAlreadyRunning = errors.New("fresher is already running in this directory, stop it or set OnAlreadyRunning to takeover")
+
+// getPIDFilePath returns the path to the PID file, or a blank string if the PID
+// file is disabled.
+func getPIDFilePath() string {
+	if config.Data().PIDFile == "" {
+		return ""
+	}
+
+	return filepath.Join(config.Data().TempDir, config.Data().PIDFile)
+}
+
+// acquirePIDFile handles making sure only one fresher runs per directory. This reads
+// the PID file left by a previous fresher, if any, and:
+//   - If the previous fresher is still running, either returns an error or stops the
+//     previous fresher and its binary, per the OnAlreadyRunning config field.
+//   - If the previous fresher is not running but its binary is, the binary is
+//     stopped. This happens when fresher crashes or is killed without being able to
+//     stop the binary, which usually leaves a port in use.
+//
+// Then, the PID file is written with this fresher's PID.
+func acquirePIDFile() (err error) {
+	path := getPIDFilePath()
+	if path == "" {
+		return
+	}
+
+	var previous pidFileData
+	b, err := os.ReadFile(path)
+	if err == nil {
+		//Ignore errors since a corrupt PID file just means there is nothing to stop.
+		json.Unmarshal(b, &previous)
+	} else if !os.IsNotExist(err) {
+		return
+	}
+
+	if previous.Fresher > 0 && previous.Fresher != os.Getpid() && isSameProcess(previous.Fresher, previous.FresherPath) {
+		if config.Data().OnAlreadyRunning != config.OnAlreadyRunningTakeover {
+			return errAlreadyRunning
+		}
+
+		warn.Printf("Taking over from fresher already running with PID %d", previous.Fresher)
+		killProcess(previous.Fresher)
+
+		//Give the previous fresher a moment to exit so that the ports used by the
+		//binary are freed.
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	if previous.Binary > 0 && isSameProcess(previous.Binary, previous.BinaryPath) {
+		warn.Printf("Stopping binary left running by a previous fresher, PID %d", previous.Binary)
+		killProcess(previous.Binary)
+	}
+
+	return writePIDFile(pidFileData{Fresher: os.Getpid(), FresherPath: fresherExecutable()})
+}
+
+// setBinaryPID saves the PID, and path to the executable, of the running binary to the
+// PID file.
+func setBinaryPID(pid int, path string) {
+	if getPIDFilePath() == "" {
+		return
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	err := writePIDFile(pidFileData{Fresher: os.Getpid(), FresherPath: fresherExecutable(), Binary: pid, BinaryPath: path})
+	if err != nil {
+		errs.Printf("Could not write PID file %s", err)
+	}
+}
+
+// writePIDFile saves the data to the PID file.
+func writePIDFile(d pidFileData) (err error) {
+	pidFileMu.Lock()
+	defer pidFileMu.Unlock()
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return
+	}
+
+	return os.WriteFile(getPIDFilePath(), b, 0644)
+}
+
+// removePIDFile deletes the PID file when fresher exits.
+func removePIDFile() {
+	path := getPIDFilePath()
+	if path == "" {
+		return
+	}
+
+	pidFileMu.Lock()
+	defer pidFileMu.Unlock()
+
+	os.Remove(path)
+}
+
+// fresherExecutable returns the path to fresher's executable, or a blank string if it
+// can't be determined in which case a stale PID file is never acted on.
+func fresherExecutable() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+
+	return exe
+}
+
+// isSameProcess returns true if the process with the given PID is running and is
+// running the executable at path. This prevents stopping an unrelated process that was
+// given a PID saved in the PID file after fresher crashed or the computer restarted.
+// False is returned if the process's executable can't be determined.
+func isSameProcess(pid int, path string) bool {
+	if pid <= 0 || path == "" || !isProcessRunning(pid) {
+		return false
+	}
+
+	exe, err := processExecutable(pid)
+	if err != nil || exe == "" {
+		return false
+	}
+
+	//Only the name of the executable is known on some OSes, see processExecutable().
+	if !strings.ContainsAny(exe, `/\`) {
+		return exe == filepath.Base(path)
+	}
+
+	return isSamePath(exe, path)
+}
+
+// isSamePath returns true if the two paths refer to the same file, resolving symlinks
+// where possible. Paths are compared case insensitively on Windows.
+func isSamePath(a, b string) bool {
+	if resolved, err := filepath.EvalSymlinks(a); err == nil {
+		a = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(b); err == nil {
+		b = resolved
+	}
+
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+
+	return a == b
+}
+
+// killProcess stops the process with the given PID. Errors are ignored since the
+// process may have exited on its own.
+func killProcess(pid int) {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+
+	killProcessTree(p)
+}
@@ -0,0 +1,94 @@
+package runner3
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestIsSameProcess(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if !isSameProcess(os.Getpid(), exe) {
+		t.Fatal("Should match this process's executable.", exe)
+		return
+	}
+	if isSameProcess(os.Getpid(), exe+"-other") {
+		t.Fatal("Should not match a different executable.")
+		return
+	}
+	if isSameProcess(os.Getpid(), "") {
+		t.Fatal("Should not match without a path, i.e. an old PID file.")
+		return
+	}
+}
+
+func TestAcquirePIDFileReusedPID(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available on Windows")
+		return
+	}
+
+	cfg := config.Defaults()
+	cfg.TempDir = t.TempDir()
+	cfg.PIDFile = "fresher.pid"
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	cmd := exec.Command("sleep", "10")
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer cmd.Process.Kill()
+
+	exited := make(chan bool)
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	//The PID was reused by an unrelated process, it must not be stopped.
+	err = writePIDFile(pidFileData{Binary: cmd.Process.Pid, BinaryPath: "/not/the/binary"})
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = acquirePIDFile()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	select {
+	case <-exited:
+		t.Fatal("Unrelated process should not have been stopped.")
+		return
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	//The binary left running by a previous fresher is stopped.
+	setBinaryPID(cmd.Process.Pid, cmd.Path)
+	err = acquirePIDFile()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	select {
+	case <-exited:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Binary left running should have been stopped.")
+		return
+	}
+}
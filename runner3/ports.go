@@ -0,0 +1,214 @@
+package runner3
+
+import (
+	"net"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// addressInUse matches the error output when a binary cannot listen on a port since
+// another process is already listening on it, for example "listen tcp :8080: bind:
+// address already in use" on Linux/macOS or "...Only one usage of each socket address
+// (protocol/network address/port) is normally permitted." on Windows.
+var addressInUse = regexp.MustCompile(`:(\d+): bind: (?:address already in use|Only one usage of each socket address)`)
+
+// findPortInUse returns the port that the binary could not listen on, or 0 if the
+// output doesn't note a port conflict.
+func findPortInUse(lines []string) int {
+	for i := len(lines) - 1; i >= 0; i-- {
+		matches := addressInUse.FindStringSubmatch(lines[i])
+		if matches == nil {
+			continue
+		}
+
+		port, _ := strconv.Atoi(matches[1])
+		return port
+	}
+
+	return 0
+}
+
+// handlePortConflict checks if the binary exited because a port it uses was already
+// in use. If so, the process holding the port is looked up and logged, since a
+// binary left running from a previous session (or another app entirely) holding a
+// port is a common and annoying problem.
+//
+// If the port is listed in the config file's KillPortConflicts field, the process
+// holding the port is stopped and the binary is rerun. True is returned if the
+// binary is being rerun.
+func handlePortConflict(lines []string) (rerun bool) {
+	port := findPortInUse(lines)
+	if port == 0 {
+		return
+	}
+
+	pids, description := findPortHolders(port)
+	if len(pids) == 0 {
+		errs.Printf("Port %d is already in use, could not determine which process is using it", port)
+		return
+	}
+
+	errs.Printf("Port %d is already in use by:\n%s", port, description)
+
+	if !isPortToKill(port) {
+		return
+	}
+
+	for _, pid := range pids {
+		warn.Printf("Stopping PID %d using port %d", pid, port)
+		killProcess(pid)
+	}
+
+	eventsChan <- fsnotify.Event{
+		Name: restartEventName,
+		Op:   fsnotify.Write,
+	}
+	return true
+}
+
+// How long, and how often, to check if the ports in WaitForPorts have been released.
+const (
+	portReleaseTimeout  = 5 * time.Second
+	portReleaseInterval = 50 * time.Millisecond
+)
+
+// waitForPortsReleased waits until each port can be listened on, meaning the old
+// binary's listener has been released by the OS, so that the rebuilt binary doesn't
+// fail to start with "address already in use". Waiting gives up after a timeout since
+// the port may be held by another process, which is handled by handlePortConflict().
+func waitForPortsReleased(ports []int) {
+	deadline := time.Now().Add(portReleaseTimeout)
+	for _, port := range ports {
+		for !isPortFree(port) {
+			if time.Now().After(deadline) {
+				warn.Printf("Port %d was not released within %s, running anyway.", port, portReleaseTimeout)
+				return
+			}
+
+			events.Tracef("Waiting for port %d to be released...", port)
+			time.Sleep(portReleaseInterval)
+		}
+	}
+}
+
+// isPortFree returns true if the port can be listened on.
+func isPortFree(port int) bool {
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return false
+	}
+
+	l.Close()
+	return true
+}
+
+// isPortToKill returns true if the port is listed in KillPortConflicts.
+func isPortToKill(port int) bool {
+	for _, p := range config.Data().KillPortConflicts {
+		if p == port {
+			return true
+		}
+	}
+
+	return false
+}
+
+// findPortHolders returns the PIDs of the processes listening on the port and a
+// human readable description of the processes. This uses the tools that typically
+// come with each OS: lsof on Linux/macOS, falling back to ss on Linux, and netstat on
+// Windows.
+func findPortHolders(port int) (pids []int, description string) {
+	if runtime.GOOS == "windows" {
+		return findPortHoldersNetstat(port)
+	}
+
+	out, err := exec.Command("lsof", "-nP", "-iTCP:"+strconv.Itoa(port), "-sTCP:LISTEN").Output()
+	if err == nil {
+		return parseLsof(string(out)), strings.TrimSpace(string(out))
+	}
+
+	out, err = exec.Command("ss", "-ltnpH", "sport = :"+strconv.Itoa(port)).Output()
+	if err == nil {
+		return parseSS(string(out)), strings.TrimSpace(string(out))
+	}
+
+	return
+}
+
+// parseLsof returns the PIDs from the output of lsof. The PID is the second column,
+// after the command name, of each line after the header.
+func parseLsof(out string) (pids []int) {
+	for i, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) < 2 {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[1])
+		if err == nil && !isIntInSlice(pids, pid) {
+			pids = append(pids, pid)
+		}
+	}
+
+	return
+}
+
+// ssPID matches the PID in the process column of ss's output, i.e.
+// users:(("app",pid=1234,fd=3)).
+var ssPID = regexp.MustCompile(`pid=(\d+)`)
+
+// parseSS returns the PIDs from the output of ss.
+func parseSS(out string) (pids []int) {
+	for _, matches := range ssPID.FindAllStringSubmatch(out, -1) {
+		pid, err := strconv.Atoi(matches[1])
+		if err == nil && !isIntInSlice(pids, pid) {
+			pids = append(pids, pid)
+		}
+	}
+
+	return
+}
+
+// findPortHoldersNetstat returns the PIDs, and a description, of the processes
+// listening on the port using netstat on Windows. The PID is the last column.
+func findPortHoldersNetstat(port int) (pids []int, description string) {
+	out, err := exec.Command("netstat", "-ano", "-p", "TCP").Output()
+	if err != nil {
+		return
+	}
+
+	suffix := ":" + strconv.Itoa(port)
+	var matched []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[3] != "LISTENING" || !strings.HasSuffix(fields[1], suffix) {
+			continue
+		}
+
+		pid, err := strconv.Atoi(fields[4])
+		if err == nil && !isIntInSlice(pids, pid) {
+			pids = append(pids, pid)
+			matched = append(matched, strings.TrimSpace(line))
+		}
+	}
+
+	return pids, strings.Join(matched, "\n")
+}
+
+// isIntInSlice checks if needle is in haystack.
+func isIntInSlice(haystack []int, needle int) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,171 @@
+package runner3
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stopTimeout is how long to wait for the binary to exit after it is stopped, before
+// the rebuilt binary is run anyway.
+const stopTimeout = 5 * time.Second
+
+// outputDrainTimeout is how long output is still read after the binary exits. Output
+// is only still open after the binary exits if a process started by the binary is
+// still running and was given the binary's stdout or stderr.
+const outputDrainTimeout = 1 * time.Second
+
+// process is a running binary. The goroutines started for a process, to copy its
+// output and wait for it to exit, all return once the process exits so that nothing
+// is left running between runs.
+type process struct {
+	cmd       *exec.Cmd
+	startedAt time.Time
+
+	//name is the name of the RunCommand the process is running, or blank for the
+	//binary.
+	name string
+
+	//stopped is set when the process is stopped via stop(), rather than exiting on
+	//its own.
+	stopped atomic.Bool
+
+	//waited is set once Wait() has returned, meaning the process has exited and its
+	//PID may be reused by another process.
+	waited atomic.Bool
+
+	//exited is closed once the process has exited and its output has been read. err
+	//is the error returned by Wait() and is set before exited is closed.
+	exited chan struct{}
+	err    error
+}
+
+// startProcess starts cmd with its output copied to fresher's output. The most recent
+// output is also saved to recentOutput so that we can diagnose why the binary exited,
+// if it does. If saveTo isn't nil, the output is also copied to it as-is, without
+// any OutputPrefix, timestamps, or colors.
+//
+// For a RunCommand, name is the command's name and is shown before each line of
+// output in place of the OutputPrefix. A RunCommand's output isn't saved to
+// recentOutput since it isn't the binary's output.
+//
+// Pipes are created here, rather than using exec.Cmd's StdoutPipe(), so that the
+// pipes can be closed once the process exits. Otherwise, reading output, and Wait(),
+// would block until every process started by the binary that shares its output
+// exits.
+func startProcess(cmd *exec.Cmd, name string, saveTo io.Writer) (p *process, err error) {
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
+		return
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+
+	err = cmd.Start()
+
+	//The write ends are only used by the process. fresher's copies are closed so
+	//that reading returns EOF once the process exits.
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdoutR.Close()
+		stderrR.Close()
+		return nil, err
+	}
+
+	p = &process{
+		cmd:       cmd,
+		startedAt: time.Now(),
+		name:      name,
+		exited:    make(chan struct{}),
+	}
+
+	var saved io.Writer = io.Discard
+	if name == "" {
+		recentOutput.reset()
+		saved = recentOutput
+	}
+	if saveTo != nil {
+		saved = io.MultiWriter(saved, saveTo)
+	}
+
+	var outputDone sync.WaitGroup
+	outputDone.Add(2)
+	go func() {
+		copyOutput(childStderr, io.TeeReader(stderrR, saved), true, name)
+		outputDone.Done()
+	}()
+	go func() {
+		copyOutput(childStdout, io.TeeReader(stdoutR, saved), false, name)
+		outputDone.Done()
+	}()
+
+	go p.wait(&outputDone, stdoutR, stderrR)
+	return p, nil
+}
+
+// wait waits for the process to exit, then for its output to be read, and closes
+// exited. If output is still open after outputDrainTimeout, the pipes are closed so
+// that reading stops.
+func (p *process) wait(outputDone *sync.WaitGroup, pipes ...*os.File) {
+	p.err = p.cmd.Wait()
+	p.waited.Store(true)
+
+	drained := make(chan struct{})
+	go func() {
+		outputDone.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(outputDrainTimeout):
+		warn.Verbosef("%s exited but its output is still open, a process it started is probably still running.", p.describe())
+	}
+
+	//Closing the pipes stops copyOutput(). On Windows, reads from a pipe aren't
+	//interrupted by closing, so the copying stops when the process holding the
+	//output open exits.
+	for _, f := range pipes {
+		f.Close()
+	}
+
+	close(p.exited)
+}
+
+// stop kills the process, and any processes it started, and waits for it to exit. A
+// timeout is used in case the process can't be killed, so that the rebuilt binary
+// is run anyway.
+//
+// Nothing is killed if the process already exited since its PID may have been reused,
+// and killing by PID, i.e. taskkill on Windows, would kill an unrelated process.
+func (p *process) stop() {
+	if !p.waited.Load() {
+		p.stopped.Store(true)
+		killProcessTree(p.cmd.Process)
+	}
+
+	select {
+	case <-p.exited:
+	case <-time.After(stopTimeout):
+		warn.Printf("%s did not exit within %s of being stopped.", p.describe(), stopTimeout)
+	}
+}
+
+// describe returns the name of the process for logging.
+func (p *process) describe() string {
+	if p.name == "" {
+		return "Binary"
+	}
+
+	return "Run command " + p.name
+}
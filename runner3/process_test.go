@@ -0,0 +1,206 @@
+package runner3
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// helperEnv is set when the test binary is run as a helper process, see
+// TestHelperProcess.
+const helperEnv = "FRESHER_TEST_HELPER"
+
+// TestHelperProcess isn't a real test. It is run as a child process, by
+// helperCommand(), to act as the binary being run.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv(helperEnv) {
+	case "exit":
+		fmt.Println("exiting")
+		os.Exit(3)
+	case "succeed":
+		os.Exit(0)
+	case "sleep":
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	case "linger":
+		time.Sleep(orphanLinger)
+		os.Exit(0)
+	case "grandchild":
+		//Start a child process that keeps file descriptor 3 open, so the test
+		//can tell when it exits, then write to it once the child has started.
+		held := os.NewFile(3, "held")
+		cmd := helperCommand("sleep")
+		cmd.ExtraFiles = []*os.File{held}
+		cmd.Start()
+		held.Write([]byte("started"))
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	case "orphan":
+		//Start a child process that keeps this process's output open, then exit
+		//without waiting for it.
+		cmd := helperCommand("linger")
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Start()
+		os.Exit(0)
+	}
+}
+
+// orphanLinger is how long the child process started by the "orphan" helper keeps
+// running. This must be longer than outputDrainTimeout.
+const orphanLinger = 5 * time.Second
+
+// helperCommand returns a command that runs the test binary as a helper process.
+func helperCommand(mode string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), helperEnv+"="+mode)
+	return cmd
+}
+
+// withoutOutput discards output copied from helper processes for the duration of
+// a test.
+func withoutOutput(t *testing.T) {
+	stdout, stderr := childStdout, childStderr
+	childStdout, childStderr = io.Discard, io.Discard
+	t.Cleanup(func() {
+		childStdout, childStderr = stdout, stderr
+	})
+}
+
+// waitForGoroutines fails the test if the number of goroutines doesn't drop back to
+// n, meaning goroutines were leaked.
+func waitForGoroutines(t *testing.T, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("Goroutines leaked, %d running, expected %d.\n%s", runtime.NumGoroutine(), n, buf[:runtime.Stack(buf, true)])
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProcessExits(t *testing.T) {
+	withoutOutput(t)
+	before := runtime.NumGoroutine()
+
+	p, err := startProcess(helperCommand("exit"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	select {
+	case <-p.exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Process did not exit.")
+		return
+	}
+
+	if p.cmd.ProcessState.ExitCode() != 3 {
+		t.Fatal("Unexpected exit code.", p.cmd.ProcessState.ExitCode())
+		return
+	}
+	if p.stopped.Load() {
+		t.Fatal("Process exited on its own, it should not be marked as stopped.")
+		return
+	}
+	if last := recentOutput.last(1); len(last) != 1 || last[0] != "exiting" {
+		t.Fatal("Output not saved.", last)
+		return
+	}
+
+	waitForGoroutines(t, before)
+}
+
+func TestProcessStop(t *testing.T) {
+	withoutOutput(t)
+	before := runtime.NumGoroutine()
+
+	p, err := startProcess(helperCommand("sleep"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	p.stop()
+
+	select {
+	case <-p.exited:
+	default:
+		t.Fatal("stop() should wait for the process to exit.")
+		return
+	}
+	if !p.stopped.Load() {
+		t.Fatal("Process should be marked as stopped.")
+		return
+	}
+
+	waitForGoroutines(t, before)
+}
+
+func TestProcessStopExited(t *testing.T) {
+	withoutOutput(t)
+
+	p, err := startProcess(helperCommand("exit"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	<-p.exited
+
+	//The process's PID may have been reused, so nothing should be killed.
+	p.stop()
+	if p.stopped.Load() {
+		t.Fatal("Process already exited, it should not have been killed.")
+		return
+	}
+}
+
+func TestProcessOrphanedOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Reads from a pipe aren't interrupted by closing on Windows.")
+	}
+
+	withoutOutput(t)
+	before := runtime.NumGoroutine()
+
+	p, err := startProcess(helperCommand("orphan"), "", nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//The process exits right away, but its child keeps the output open. Waiting
+	//should give up on the output, before the child exits, shortly after the
+	//process exits.
+	select {
+	case <-p.exited:
+	case <-time.After(orphanLinger - time.Second):
+		t.Fatal("Waiting did not stop after the process exited.")
+		return
+	}
+
+	waitForGoroutines(t, before)
+}
+
+func TestProcessManyRuns(t *testing.T) {
+	withoutOutput(t)
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 10; i++ {
+		p, err := startProcess(helperCommand("exit"), "", nil)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		<-p.exited
+	}
+
+	waitForGoroutines(t, before)
+}
@@ -0,0 +1,186 @@
+package runner3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// findReplacedModuleDirs returns the directories of modules replaced with a local path
+// in the binary's go.mod. Directories within WorkingDir are skipped since they are
+// already watched.
+func findReplacedModuleDirs() []string {
+	modDir := findModuleRoot(filepath.Join(config.Data().WorkingDir, config.Data().EntryPoint, "go.mod"))
+	if modDir == "" {
+		return nil
+	}
+
+	replaced, err := readLocalReplaces(modDir)
+	if err != nil {
+		warn.Printf("Could not read replaced modules from go.mod, not watching. %s", err)
+		return nil
+	}
+
+	workingDir, err := filepath.Abs(config.Data().WorkingDir)
+	if err != nil {
+		return nil
+	}
+
+	var dirs []string
+	for _, dir := range replaced {
+		if isWithinDirectory(dir, workingDir) || isWithinDirectory(workingDir, dir) {
+			continue
+		}
+
+		fi, err := os.Stat(dir)
+		if err != nil || !fi.IsDir() {
+			warn.Printf("Replaced module %s not found, not watching.", dir)
+			continue
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	return dirs
+}
+
+// goModJSON is the output of `go mod edit -json`. Only the fields used are listed.
+type goModJSON struct {
+	Replace []struct {
+		New struct {
+			Path    string
+			Version string
+		}
+	}
+}
+
+// readLocalReplaces returns the directories, made absolute using modDir, of the
+// replace directives in modDir's go.mod that point at a local path, i.e.
+// "example.com/lib => ../lib" but not "example.com/lib => example.com/fork v1.2.0".
+// go.mod is read with `go mod edit -json`, rather than parsed here, so that every
+// form of replace directive is handled the same as the go command does.
+func readLocalReplaces(modDir string) (dirs []string, err error) {
+	cmd := exec.Command("go", "mod", "edit", "-json")
+	cmd.Dir = modDir
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("go mod edit failed %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, err
+	}
+
+	var mod goModJSON
+	err = json.Unmarshal(out, &mod)
+	if err != nil {
+		return
+	}
+
+	//A replacement without a version is a local path, relative to the module's
+	//directory if not absolute.
+	for _, r := range mod.Replace {
+		if r.New.Version != "" {
+			continue
+		}
+
+		path := filepath.FromSlash(r.New.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(modDir, path)
+		}
+		dirs = append(dirs, filepath.Clean(path))
+	}
+
+	return
+}
+
+// isWithinDirectory returns true if path is dir or is inside dir.
+func isWithinDirectory(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// replacedDirectoryIgnoreReason returns why a directory within a replaced module
+// should not be watched, or a blank string if the directory should be watched. The
+// replaced module's directory is treated as if it were the WorkingDir so that entries
+// in DirectoriesToIgnore, i.e. ".git", are ignored the same way.
+func replacedDirectoryIgnoreReason(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return ""
+	}
+
+	if rel != "." && config.Data().IsDirectoryToIgnore(filepath.Join(config.Data().WorkingDir, rel)) {
+		return ignoreReasonConfig
+	}
+	if config.Data().IsAutoIgnoredDirectory(path) {
+		return ignoreReasonAuto
+	}
+
+	return ""
+}
+
+// watchReplacedModules watches the directories of modules replaced with a local path
+// in go.mod, see WatchReplacedModules in the config file. A separate fsnotify watcher
+// is used, regardless of WatchBackend, since the native watcher only watches
+// WorkingDir. The returned channel is nil if there are no replaced modules to watch.
+func watchReplacedModules() (<-chan fsnotify.Event, error) {
+	if !config.Data().WatchReplacedModules {
+		return nil, nil
+	}
+
+	dirs := findReplacedModuleDirs()
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, explainWatchError(err)
+	}
+
+	for _, root := range dirs {
+		root := root
+		err = walkDirectories(root, func(path string) (bool, error) {
+			if reason := replacedDirectoryIgnoreReason(root, path); reason != "" {
+				warn.Tracef("IGNORING %s (%s)", path, reason)
+				watching.recordIgnored(reason)
+				return false, nil
+			}
+
+			events.Tracef("Watching %s", path)
+			err := watcher.Add(path)
+			if err != nil {
+				return false, explainWatchError(err)
+			}
+
+			watching.recordWatched()
+			return true, nil
+		})
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+
+		events.Printf("Watching replaced module %s", root)
+	}
+
+	go func() {
+		for err := range watcher.Errors {
+			errs.Printf("watcher error %s", err)
+		}
+	}()
+
+	return watcher.Events, nil
+}
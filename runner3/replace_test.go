@@ -0,0 +1,85 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadLocalReplaces(t *testing.T) {
+	modDir := filepath.Join(t.TempDir(), "app")
+	abs := filepath.Join(t.TempDir(), "tools")
+	err := os.MkdirAll(modDir, 0755)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	gomod := `module example.com/app
+
+go 1.19
+
+require example.com/lib v1.0.0
+
+replace example.com/lib => ../lib // patched locally
+
+replace (
+	example.com/fork => example.com/other v1.2.0
+	example.com/util v1.0.0 => ./third_party/util
+	example.com/tools => "` + filepath.ToSlash(abs) + `"
+)
+`
+	err = os.WriteFile(filepath.Join(modDir, "go.mod"), []byte(gomod), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	dirs, err := readLocalReplaces(modDir)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	expected := []string{
+		filepath.Join(modDir, "..", "lib"),
+		filepath.Join(modDir, "third_party", "util"),
+		abs,
+	}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Fatal("Unexpected directories.", dirs)
+		return
+	}
+
+	//An invalid go.mod should return an error rather than be guessed at.
+	err = os.WriteFile(filepath.Join(modDir, "go.mod"), []byte("replace example.com/lib =>\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	_, err = readLocalReplaces(modDir)
+	if err == nil {
+		t.Fatal("Error should be returned for an invalid go.mod.")
+		return
+	}
+}
+
+func TestIsWithinDirectory(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "app")
+
+	tests := []struct {
+		path   string
+		within bool
+	}{
+		{root, true},
+		{filepath.Join(root, "lib"), true},
+		{filepath.Join(root, "..", "lib"), false},
+		{root + "-lib", false},
+	}
+	for _, tt := range tests {
+		if w := isWithinDirectory(tt.path, root); w != tt.within {
+			t.Fatal("Unexpected result.", tt.path, w)
+			return
+		}
+	}
+}
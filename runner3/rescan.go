@@ -0,0 +1,207 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+	"github.com/fsnotify/fsnotify"
+)
+
+// rescanner periodically walks the directory tree and compares each watched file's
+// modification time and size against the previous walk. Changes the watcher missed
+// are sent as events. This is needed in environments that drop file change events,
+// such as WSL2 accessing files under /mnt or SMB shares.
+//
+// File changes the watcher did catch are noted via noteEvent() so that they aren't
+// reported again, causing a second rebuild, on the next rescan.
+type rescanner struct {
+	mu    sync.Mutex
+	files map[string]fileState
+	dirs  map[string]bool
+
+	//addDirectory is called for each new directory found, to add the directory to
+	//the watcher. This is nil when the watcher is recursive.
+	addDirectory func(path string) error
+}
+
+// fileState is what is compared between rescans to determine if a file changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// rescan is the package level rescanner. This is only used if RescanIntervalSeconds
+// is set; see startRescan().
+var rescan = &rescanner{}
+
+// startRescan starts rescanning the directory tree at the RescanIntervalSeconds
+// interval. Missed file changes are sent on the returned channel. A nil channel is
+// returned if rescanning is disabled.
+func startRescan(addDirectory func(path string) error) <-chan fsnotify.Event {
+	seconds := config.Data().RescanIntervalSeconds
+	if seconds <= 0 {
+		return nil
+	}
+
+	rescan.addDirectory = addDirectory
+	missed := make(chan fsnotify.Event, 100)
+	interval := time.Duration(seconds) * time.Second
+	events.Verbosef("Rescanning for missed file changes every %s", interval)
+
+	go func() {
+		//The first scan is just to know the state of each file to compare against.
+		files, dirs, err := scanFiles()
+		if err != nil {
+			errs.Printf("Rescan error %s", err)
+		}
+		rescan.mu.Lock()
+		rescan.files, rescan.dirs = files, dirs
+		rescan.mu.Unlock()
+
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			for _, event := range rescan.diff() {
+				events.Verbosef("Rescan found missed change %s (%s)", event.Name, event.Op.String())
+				missed <- event
+			}
+		}
+	}()
+
+	return missed
+}
+
+// diff rescans the directory tree and returns an event for each file that was
+// created, modified, or removed since the previous scan. New directories are added to
+// the watcher.
+func (r *rescanner) diff() (missed []fsnotify.Event) {
+	files, dirs, err := scanFiles()
+	if err != nil {
+		errs.Printf("Rescan error %s", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for path, state := range files {
+		previous, ok := r.files[path]
+		switch {
+		case !ok:
+			missed = append(missed, fsnotify.Event{Name: path, Op: fsnotify.Create})
+		case !previous.modTime.Equal(state.modTime) || previous.size != state.size:
+			missed = append(missed, fsnotify.Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+	for path := range r.files {
+		if _, ok := files[path]; !ok {
+			missed = append(missed, fsnotify.Event{Name: path, Op: fsnotify.Remove})
+		}
+	}
+
+	if r.addDirectory != nil {
+		for dir := range dirs {
+			if r.dirs[dir] {
+				continue
+			}
+
+			events.Verbosef("Rescan found new directory %s", dir)
+			err := r.addDirectory(dir)
+			if err != nil {
+				errs.Printf("Could not watch %s %s", dir, err)
+			}
+		}
+	}
+
+	r.files, r.dirs = files, dirs
+	return
+}
+
+// noteEvent updates the state of a file when the watcher sends an event for it so
+// that the change isn't reported again by the next rescan.
+//
+// The path is cleaned since the watcher's paths may differ from the paths found when
+// scanning, i.e.: "./main.go" versus "main.go".
+func (r *rescanner) noteEvent(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.files == nil {
+		return
+	}
+
+	path = filepath.Clean(path)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		delete(r.files, path)
+		return
+	}
+
+	r.files[path] = fileState{modTime: fi.ModTime(), size: fi.Size()}
+}
+
+// scanFiles returns the state of each file with a watched extension, and the list of
+// directories, that aren't ignored.
+func scanFiles() (files map[string]fileState, dirs map[string]bool, err error) {
+	files = map[string]fileState{}
+	dirs = map[string]bool{}
+	var mu sync.Mutex
+
+	err = walkDirectories(config.Data().WorkingDir, func(path string) (bool, error) {
+		reason, err := ignoreDirectoryReason(path)
+		if err != nil {
+			return false, err
+		}
+		if reason != "" {
+			return hasUnignoredSubdirectories(path, reason), nil
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return false, err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		dirs[path] = true
+		for _, e := range entries {
+			if e.IsDir() || config.Data().IsEditorTempFile(e.Name()) {
+				continue
+			}
+			if !config.Data().IsExtensionToWatch(filepath.Ext(e.Name())) && !isEmbeddedFile(filepath.Join(path, e.Name())) {
+				continue
+			}
+
+			fi, err := e.Info()
+			if err != nil {
+				//File was removed between reading the directory and now.
+				continue
+			}
+
+			files[filepath.Join(path, e.Name())] = fileState{modTime: fi.ModTime(), size: fi.Size()}
+		}
+
+		return true, nil
+	})
+
+	return
+}
+
+// mergeEvents returns a channel that receives the events from both a and b. This is
+// used to handle events from the watcher and the rescanner in the same way.
+func mergeEvents(a, b <-chan fsnotify.Event) <-chan fsnotify.Event {
+	merged := make(chan fsnotify.Event, 1)
+	forward := func(c <-chan fsnotify.Event) {
+		for event := range c {
+			merged <- event
+		}
+	}
+
+	go forward(a)
+	go forward(b)
+	return merged
+}
@@ -0,0 +1,36 @@
+package runner3
+
+import (
+	"github.com/c9845/fresher/config"
+)
+
+// isRestartAllowed returns true if the binary should be restarted for the event per
+// the config file's RestartPolicy field. This is only checked once the binary has
+// been started, the first run is always allowed.
+//
+// An explicit request, i.e. typing "r" or using the control API, always restarts the
+// binary. Otherwise, with "on-success" the binary is only restarted if it isn't
+// running, and with "manual" the binary is never restarted.
+func isRestartAllowed(eventName string, running *process) bool {
+	if eventName == restartEventName || eventName == rebuildEventName {
+		return true
+	}
+
+	switch config.Data().RestartPolicy {
+	case config.RestartPolicyManual:
+		return false
+	case config.RestartPolicyOnSuccess:
+		if running == nil {
+			return true
+		}
+
+		select {
+		case <-running.exited:
+			return true
+		default:
+			return false
+		}
+	default:
+		return true
+	}
+}
@@ -0,0 +1,45 @@
+package runner3
+
+import (
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestIsRestartAllowed(t *testing.T) {
+	running := &process{exited: make(chan struct{})}
+	exited := &process{exited: make(chan struct{})}
+	close(exited.exited)
+
+	tests := []struct {
+		policy    string
+		eventName string
+		running   *process
+		allowed   bool
+	}{
+		{config.RestartPolicyAlways, "main.go", running, true},
+		{config.RestartPolicyOnSuccess, "main.go", running, false},
+		{config.RestartPolicyOnSuccess, "main.go", exited, true},
+		{config.RestartPolicyOnSuccess, "main.go", nil, true},
+		{config.RestartPolicyOnSuccess, restartEventName, running, true},
+		{config.RestartPolicyManual, "main.go", exited, false},
+		{config.RestartPolicyManual, autoRestartEventName, exited, false},
+		{config.RestartPolicyManual, restartEventName, running, true},
+		{config.RestartPolicyManual, rebuildEventName, running, true},
+	}
+
+	for _, tt := range tests {
+		cfg := config.Defaults()
+		cfg.RestartPolicy = tt.policy
+		err := config.Use(cfg)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+
+		if allowed := isRestartAllowed(tt.eventName, tt.running); allowed != tt.allowed {
+			t.Fatal("Unexpected result.", tt.policy, tt.eventName, allowed)
+			return
+		}
+	}
+}
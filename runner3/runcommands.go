@@ -0,0 +1,131 @@
+package runner3
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// How long, and how often, to check if a RunCommand with a ReadyAddress is ready.
+const (
+	readyTimeout  = 30 * time.Second
+	readyInterval = 100 * time.Millisecond
+)
+
+// startRunCommands runs the RunCommands, in order, before the binary is run. Commands
+// with Once are run to completion and must succeed. If a command fails, the commands
+// already started are stopped and an error is returned so that the binary isn't run.
+//
+// The long running commands are returned so that they can be stopped, along with the
+// binary, when the binary is rerun.
+func startRunCommands() (running []*process, err error) {
+	cfg := config.Data()
+	for _, r := range cfg.RunCommands {
+		fields := strings.Fields(r.Command)
+		cmd := exec.Command(fields[0], fields[1:]...)
+		cmd.Dir = cfg.WorkingDir
+		cmd.Env = getRunCommandEnv(cfg, r)
+		setProcessGroup(cmd)
+
+		events.Verbosef("Running %s... %s", r.Name, r.Command)
+		p, err := startProcess(cmd, r.Name, nil)
+		if err != nil {
+			stopRunCommands(running)
+			return nil, fmt.Errorf("run command %s could not be started %w", r.Name, err)
+		}
+
+		if r.Once {
+			<-p.exited
+			if p.err != nil {
+				stopRunCommands(running)
+				return nil, fmt.Errorf("run command %s failed %w", r.Name, p.err)
+			}
+			continue
+		}
+
+		//Wait for the command to be ready before running the commands, or the
+		//binary, that depend on it.
+		if r.ReadyAddress != "" {
+			err = waitForReady(p, r.ReadyAddress)
+			if err != nil {
+				stopRunCommands(append(running, p))
+				return nil, fmt.Errorf("run command %s not ready %w", r.Name, err)
+			}
+		}
+
+		//Note when a long running command exits on its own since the binary may
+		//not work without it. The binary is left running.
+		go func() {
+			<-p.exited
+			if p.stopped.Load() {
+				return
+			}
+			if p.err != nil {
+				warn.Printf("Run command %s exited %s", p.name, p.err)
+			} else {
+				warn.Printf("Run command %s exited", p.name)
+			}
+		}()
+		running = append(running, p)
+	}
+
+	return running, nil
+}
+
+// waitForReady waits until a connection to address succeeds, meaning the process is
+// ready. An error is returned if the process exits, or isn't ready within
+// readyTimeout, since whatever depends on the process would fail.
+func waitForReady(p *process, address string) error {
+	events.Verbosef("Waiting for %s to be ready at %s...", p.name, address)
+
+	timeout := time.After(readyTimeout)
+	for {
+		conn, err := net.DialTimeout("tcp", address, readyInterval)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+
+		select {
+		case <-p.exited:
+			return errors.New("exited before it was ready")
+		case <-timeout:
+			return fmt.Errorf("after %s, %s not accepting connections", readyTimeout, address)
+		case <-time.After(readyInterval):
+		}
+	}
+}
+
+// stopRunCommands stops the long running RunCommands, in the reverse order they were
+// started, so that commands started later that may depend on earlier commands are
+// stopped first.
+func stopRunCommands(running []*process) {
+	for i := len(running) - 1; i >= 0; i-- {
+		running[i].stop()
+	}
+}
+
+// getRunCommandEnv returns the environment a RunCommand is run with, the binary's
+// environment plus the path to the built binary and the Env set for the command.
+func getRunCommandEnv(cfg *config.File, r config.RunCommand) []string {
+	env := append(getBinaryEnv(cfg), "FRESHER_BINARY="+getPathToBuiltBinary(cfg))
+
+	//Sorted so the environment is the same each time the command is run.
+	keys := []string{}
+	for k := range r.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		env = append(env, k+"="+r.Env[k])
+	}
+
+	return env
+}
@@ -0,0 +1,141 @@
+package runner3
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestStartRunCommands(t *testing.T) {
+	withoutOutput(t)
+
+	helper := os.Args[0] + " -test.run=^TestHelperProcess$"
+	useRunCommands := func(modes ...string) {
+		cfg := config.Defaults()
+		cfg.TempDir = t.TempDir()
+		for _, mode := range modes {
+			cfg.RunCommands = append(cfg.RunCommands, config.RunCommand{
+				Name:    mode,
+				Command: helper,
+				Env:     map[string]string{helperEnv: mode},
+				Once:    mode != "sleep",
+			})
+		}
+
+		err := config.Use(cfg)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	//A Once command that succeeds should be run to completion, and long running
+	//commands left running.
+	useRunCommands("sleep", "succeed")
+	running, err := startRunCommands()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(running) != 1 || running[0].name != "sleep" {
+		t.Fatal("Expected long running command to be returned.", len(running))
+		return
+	}
+	stopRunCommands(running)
+	select {
+	case <-running[0].exited:
+	default:
+		t.Fatal("Long running command not stopped.")
+		return
+	}
+
+	//A Once command that fails should stop the commands already started.
+	before := runtime.NumGoroutine()
+	useRunCommands("sleep", "exit", "sleep")
+	running, err = startRunCommands()
+	if err == nil || running != nil {
+		t.Fatal("Expected error when Once command fails.")
+		return
+	}
+	waitForGoroutines(t, before)
+}
+
+func TestWaitForReady(t *testing.T) {
+	withoutOutput(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	address := l.Addr().String()
+
+	//A process listening on the address is ready.
+	p, err := startProcess(helperCommand("sleep"), "sleep", nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = waitForReady(p, address)
+	p.stop()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//A process that exits before listening is never ready.
+	l.Close()
+	p, err = startProcess(helperCommand("exit"), "exit", nil)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	err = waitForReady(p, address)
+	if err == nil {
+		t.Fatal("Expected error when process exits before it is ready.")
+		return
+	}
+}
+
+func TestShutdownStopsRunCommands(t *testing.T) {
+	withoutOutput(t)
+	t.Cleanup(func() {
+		statusFile.mu.Lock()
+		statusFile.removed = false
+		statusFile.mu.Unlock()
+	})
+
+	cfg := config.Defaults()
+	cfg.TempDir = t.TempDir()
+	cfg.RunCommands = []config.RunCommand{{
+		Name:    "sleep",
+		Command: os.Args[0] + " -test.run=^TestHelperProcess$",
+		Env:     map[string]string{helperEnv: "sleep"},
+	}}
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	running, err := startRunCommands()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	setCurrent(nil, running)
+	defer setCurrent(nil, nil)
+
+	//RunCommands don't get the interrupt sent to fresher, so they must be stopped
+	//when fresher exits.
+	shutdown()
+	select {
+	case <-running[0].exited:
+	default:
+		t.Fatal("RunCommand should be stopped on shutdown.")
+		return
+	}
+}
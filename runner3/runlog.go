@@ -0,0 +1,107 @@
+package runner3
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// Format of the names of the files each run's output is saved to, see RunLogsToKeep
+// in the config file.
+const (
+	runLogPrefix = "run-"
+	runLogSuffix = ".log"
+)
+
+// lastRunLog is the number of the most recent run log file. This is found from the
+// files already in the TempDir, when the first run log is created, so that numbering
+// continues from the previous time fresher was run.
+var lastRunLog = -1
+
+// openRunLog creates the file the next run's output is saved to and deletes old run
+// log files beyond RunLogsToKeep. Nil is returned if run logs are disabled or the file
+// could not be created; not saving a run's output shouldn't stop the binary running.
+//
+// This is only called from start() so numbering doesn't need to be synchronized.
+func openRunLog() *os.File {
+	keep := config.Data().RunLogsToKeep
+	if keep == 0 {
+		return nil
+	}
+
+	dir := config.Data().TempDir
+	existing := runLogNumbers(dir)
+	if lastRunLog < 0 {
+		lastRunLog = 0
+		if len(existing) > 0 {
+			lastRunLog = existing[len(existing)-1]
+		}
+	}
+	lastRunLog++
+
+	path := filepath.Join(dir, runLogName(lastRunLog))
+	f, err := os.Create(path)
+	if err != nil {
+		errs.Printf("Could not create run log %s", err)
+		return nil
+	}
+	fmt.Fprintf(f, "# Run %d started %s\n", lastRunLog, time.Now().Format(time.RFC3339))
+	events.Verbosef("Saving output to %s", path)
+
+	//Delete the oldest run logs, keeping the one just created.
+	if extra := len(existing) + 1 - keep; extra > 0 {
+		for _, n := range existing[:extra] {
+			os.Remove(filepath.Join(dir, runLogName(n)))
+		}
+	}
+
+	return f
+}
+
+// closeRunLog notes how the run ended and closes the run log file.
+func closeRunLog(f *os.File, err error, ranFor time.Duration) {
+	if f == nil {
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(f, "# Exited after %s, %s\n", ranFor.Round(time.Millisecond), err)
+	} else {
+		fmt.Fprintf(f, "# Exited after %s\n", ranFor.Round(time.Millisecond))
+	}
+	f.Close()
+}
+
+// runLogName returns the name of the nth run log file.
+func runLogName(n int) string {
+	return fmt.Sprintf("%s%04d%s", runLogPrefix, n, runLogSuffix)
+}
+
+// runLogNumbers returns the numbers of the run log files in dir, oldest first.
+func runLogNumbers(dir string) (numbers []int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, runLogPrefix) || !strings.HasSuffix(name, runLogSuffix) {
+			continue
+		}
+
+		var n int
+		_, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, runLogPrefix), runLogSuffix), "%d", &n)
+		if err == nil && runLogName(n) == name {
+			numbers = append(numbers, n)
+		}
+	}
+
+	sort.Ints(numbers)
+	return
+}
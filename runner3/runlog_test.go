@@ -0,0 +1,52 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestOpenRunLog(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Defaults()
+	cfg.TempDir = dir
+	cfg.RunLogsToKeep = 2
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Numbering should continue from files left by a previous run of fresher. Other
+	//files should be ignored.
+	for _, name := range []string{"run-0007.log", "run-0003.log", "run-x.log", "other.log"} {
+		err := os.WriteFile(filepath.Join(dir, name), nil, 0644)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+	lastRunLog = -1
+
+	for i := 0; i < 2; i++ {
+		f := openRunLog()
+		if f == nil {
+			t.Fatal("Run log not created.")
+			return
+		}
+		closeRunLog(f, nil, time.Second)
+	}
+
+	if n := runLogNumbers(dir); !reflect.DeepEqual(n, []int{8, 9}) {
+		t.Fatal("Unexpected run logs kept.", n)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other.log")); err != nil {
+		t.Fatal("Other files should not be deleted.", err)
+		return
+	}
+}
@@ -11,15 +11,22 @@ How watching/building/running works:
 package runner3
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
-	"io/fs"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/c9845/fresher/config"
@@ -32,33 +39,27 @@ var (
 	//builder. Messages are in the format `"file: DELETE|MODIFY|...`.
 	//See: https://pkg.go.dev/github.com/fsnotify/fsnotify#Event.String
 	eventsChan = make(chan fsnotify.Event, 1)
-
-	//stopChan is for terminating the built and running binary when the binary is
-	//rebuilt. This prevents multiple copies of the binary from running concurrently.
-	stopChan = make(chan bool)
-
-	//killBuildingChan is used to signal to build() that the `go build...` command should
-	//be terminated. This is used when another file change event has occured while
-	//build() is running that will just cause build() to run again. There is no sense
-	//in completing the currently running build since build() will just be called
-	//again immediately after completing. Killing off the running build just saves a
-	//bit of time.
-	killBuildingChan = make(chan bool, 1)
 )
 
 // Configure handles some initialization steps before watching for file changes and
 // handling building and running the binary.
 func Configure() (err error) {
 	//Set up logging.
-	events = newLogger("fresher", "blue")
-	warn = newLogger("fresher", "yellow")
-	errs = newLogger("fresher", "red")
+	colors := config.Data().Colors
+	events = newLogger("fresher", colors.Events, config.LogLevelInfo)
+	warn = newLogger("fresher", colors.Warnings, config.LogLevelWarn)
+	errs = newLogger("fresher", colors.Errors, config.LogLevelError)
 
 	//Set the number of maximum file descriptors that can be opened by this process.
 	//This is needed for watching a HUGE amount of files. Windows is not applicable.
-	err = setRLimit()
-	if err != nil {
-		return
+	//Failing to raise the limit isn't fatal since most repos don't need a high limit.
+	if maxOpenFiles := config.Data().MaxOpenFiles; maxOpenFiles > 0 {
+		current, err := setRLimit(uint64(maxOpenFiles))
+		if err != nil {
+			warn.Printf("Could not raise open files limit to %d, limit is %d. %s", maxOpenFiles, current, err)
+		} else if current < uint64(maxOpenFiles) {
+			warn.Printf("Open files limit raised to %d, not %d. Watching may fail in big repos.", current, maxOpenFiles)
+		}
 	}
 
 	//Create the temp directory to store the build binary and error logs.
@@ -67,25 +68,204 @@ func Configure() (err error) {
 		return
 	}
 
+	//Make sure another fresher isn't already running in this directory and stop any
+	//binary left running by a previous fresher.
+	err = acquirePIDFile()
+	if err != nil {
+		return
+	}
+	defer func() {
+		//Don't leave the PID file behind if fresher won't start.
+		if err != nil {
+			removePIDFile()
+		}
+	}()
+
+	//Create the GoCache and GoTmpDir directories, if set, since Go requires GOTMPDIR
+	//to exist.
+	err = configureGoCache()
+	if err != nil {
+		return
+	}
+
+	if cfg := config.Data(); len(cfg.BuildConfigs) > 0 {
+		events.Printf("Using build config %s, available build configs are %s", cfg.BuildConfig, cfg.BuildConfigNames())
+	}
+
+	//Set up saving logs to a file, if enabled. This must be done after the temp
+	//directory is created since the log file is stored in it.
+	err = configureLogFile()
+	if err != nil {
+		return
+	}
+
+	//Don't ask to confirm rebuilds if the answer can't be typed.
+	checkConfirmInput()
+
+	//Compile the regular expressions used to filter the binary's output.
+	err = compileOutputFilters()
+	if err != nil {
+		return
+	}
+
+	//Make sure the binary can be built before watching for changes.
+	err = checkGoToolchain()
+	if err != nil {
+		return
+	}
+
+	//Choose how the binary is built and run.
+	activeBuilder = newBuilder()
+	activeRunner = newProcessRunner()
+
 	//Debug logging.
 	warn.Verbosef("Watching extensions: %s", config.Data().ExtensionsToWatch)
 	warn.Verbosef("Ignoring directories: %s", config.Data().DirectoriesToIgnore)
 
+	//Start the metrics server, if enabled.
+	serveMetrics()
+
+	//Start the static file server, if enabled.
+	serveStatic()
+
+	//Start the debug server, if enabled.
+	serveDebug()
+
+	//Start the WebAssembly server, if enabled.
+	err = serveWASM()
+	if err != nil {
+		return
+	}
+
+	//Open the event stream, if enabled.
+	err = configureEventStream()
+	if err != nil {
+		return
+	}
+
+	//Start the control API, if enabled.
+	err = serveControl()
+	if err != nil {
+		return
+	}
+
+	//Start saving fresher's status to a file, if enabled.
+	startStatusFile()
+
 	return
 }
 
 // Watch handles setting up the watcher of file changes. The watcher is populated with
 // a list of directories to watch, not individual files. Some directories are ignored
-// per the config file field DirectoriesToIgnore.
+// per the config file field DirectoriesToIgnore. If the WatchBackend config file
+// field is "native", and supported on this OS, the working directory is watched
+// recursively instead and events from ignored directories are skipped.
 //
 // When a file change event occurs, the event is sent on the eventsChan which will be
 // recevied in start() and is used to trigger the binary being built via build().
 func Watch() (err error) {
-	//Initialize the watcher.
-	watcher, err := fsnotify.NewWatcher()
+	runHooks(hookPreWatch, hookEvent{})
+
+	//Initialize the watcher. A native, recursive, watcher is used if requested and
+	//supported on this OS. Otherwise, fsnotify is used to watch each directory.
+	var fileEvents <-chan fsnotify.Event
+	var watchErrors <-chan error
+	var addDirectory func(path string) error
+	var watchNewDirectories func()
+	native := false
+	if config.Data().WatchBackend == config.WatchBackendNative {
+		nw, err := newNativeWatcher(config.Data().WorkingDir)
+		if err == errNativeWatchUnsupported {
+			warn.Printf("Native watching is not supported on %s, using fsnotify.", runtime.GOOS)
+		} else if err != nil {
+			return err
+		} else {
+			fileEvents, watchErrors = nw.Events, nw.Errors
+			native = true
+
+			events.Printf("Watching %s recursively using the native backend", config.Data().WorkingDir)
+			emit(streamWatchReady, streamEvent{})
+		}
+	}
+	if !native {
+		watcher, err := watchDirectories()
+		if err != nil {
+			return err
+		}
+		fileEvents, watchErrors = watcher.Events, watcher.Errors
+
+		addDirectory = func(path string) error {
+			err := watcher.Add(path)
+			if err == nil {
+				watching.recordWatched()
+			}
+			return err
+		}
+		watchNewDirectories = func() {
+			addNewDirectories(watcher.WatchList(), addDirectory)
+		}
+	}
+
+	//Watch modules replaced with a local path in go.mod, if enabled.
+	replaced, err := watchReplacedModules()
 	if err != nil {
 		return err
 	}
+	if replaced != nil {
+		fileEvents = mergeEvents(fileEvents, replaced)
+	}
+
+	//Periodically rescan for file changes the watcher missed, if enabled.
+	if missed := startRescan(addDirectory); missed != nil {
+		fileEvents = mergeEvents(fileEvents, missed)
+	}
+
+	//Watch for git operations that change many files at once, if enabled.
+	if gitEvents := watchGit(watchNewDirectories); gitEvents != nil {
+		fileEvents = mergeEvents(fileEvents, gitEvents)
+	}
+
+	//Watch for file change events. When an event does occur, make sure it is a
+	//file write (not CHMOD or something else) and that the file that was changed has
+	//an extension that we watch for (i.e.: no sense in sending events to rebuild
+	//binary if a .docx file was changed).
+	go watchEvents(fileEvents, watchErrors, newDebouncer(clk, native))
+
+	//Watcher is set up to watch for changes in directories.
+	//goroutine watching for file change events will continue running.
+
+	return
+}
+
+// eventOpNames maps fsnotify's operations to the names used in EventOps.
+var eventOpNames = map[fsnotify.Op]string{
+	fsnotify.Write:  config.EventOpWrite,
+	fsnotify.Create: config.EventOpCreate,
+	fsnotify.Remove: config.EventOpRemove,
+	fsnotify.Rename: config.EventOpRename,
+}
+
+// isEventOpToWatch returns true if the operation of a file change event is listed in
+// EventOps. An event can have more than one operation, i.e.: CREATE|WRITE, in which
+// case any of the operations being listed is enough.
+func isEventOpToWatch(op fsnotify.Op) bool {
+	for o, name := range eventOpNames {
+		if op.Has(o) && config.Data().IsEventOpToWatch(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchDirectories creates an fsnotify watcher and adds each directory that isn't
+// ignored to it. fsnotify isn't recursive, so each directory is watched separately.
+func watchDirectories() (watcher *fsnotify.Watcher, err error) {
+	//Initialize the watcher.
+	watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, explainWatchError(err)
+	}
 
 	//Add paths to watcher of the directories to watch for file changes. We watch
 	//directories, not individual files, for changes.
@@ -93,127 +273,177 @@ func Watch() (err error) {
 	//This works by walking the directory starting at the working directory, typically
 	//the directory fresher is being run in, checking if each directory should be
 	//watched or ignored (as set in config file), and adding the directory to the
-	//watcher.
-	err = filepath.WalkDir(config.Data().WorkingDir, func(path string, d fs.DirEntry, err error) error {
-		//Handle errors related to the path. See fs.WalkDirFunc for more info.
+	//watcher. Directories are walked, and added to the watcher, in parallel since
+	//this can take many seconds on huge repos.
+	//
+	//Additions aren't batched since fsnotify has no way to add many directories at
+	//once; each Add() is a single inotify_add_watch, or equivalent, call. Adding from
+	//each of the walk's goroutines is the closest equivalent.
+	var watchedDirectories atomic.Int64
+	err = walkDirectories(config.Data().WorkingDir, func(path string) (bool, error) {
+		//Ignore directory if it is the temp directory or is in the list of ignored
+		//directories.
+		reason, err := ignoreDirectoryReason(path)
 		if err != nil {
-			return err
+			return false, err
 		}
+		if reason != "" {
+			warn.Tracef("IGNORING %s (%s)", path, reason)
+			watching.recordIgnored(reason)
 
-		//Only watch directories, not individual files.
-		if !d.IsDir() {
-			return nil
+			//Keep walking into the ignored directory if a subdirectory of it is
+			//un-ignored with a "!" entry in DirectoriesToIgnore.
+			return hasUnignoredSubdirectories(path, reason), nil
 		}
 
-		//Ignore directory if it is the temp directory where built binaries are stored
-		//before running. No need to watch this directory since it stores temp data
-		//from fresher.
-		yes, err := config.Data().IsTempDir(path)
-		if err != nil {
-			return err
+		//Stop adding directories once MaxWatchedDirectories is reached. The count
+		//is incremented before adding the directory, since directories are added
+		//in parallel, and undone if the limit was reached.
+		n := watchedDirectories.Add(1)
+		if max := config.Data().MaxWatchedDirectories; max > 0 && n > int64(max) {
+			watchedDirectories.Add(-1)
+			watching.recordIgnored(ignoreReasonMaxWatched)
+			return false, nil
 		}
-		if yes {
-			return fs.SkipDir
+
+		//Add path to watcher.
+		events.Tracef("Watching %s", path)
+		err = watcher.Add(path)
+		if err != nil {
+			return false, explainWatchError(err)
 		}
 
-		//Ignore directory if it is in list of ignored directories. Ignored directories
-		//listed in config file are based off of the WorkingDir. The path in the
-		//WalkDirFunc here is also based off of the WorkingDir, so therefore we can
-		//easily compare without having to handle absolute paths.
-		if config.Data().IsDirectoryToIgnore(path) {
-			warn.Verbosef("IGNORING %s", path)
+		watching.recordWatched()
+		return true, nil
+	})
+	if err != nil {
+		return
+	}
 
-			return fs.SkipDir
+	//Watch the directory the trigger file is in, if needed. The trigger file may be
+	//in a directory that is otherwise ignored, such as the temp directory.
+	if triggerFilePath := getTriggerFilePath(); triggerFilePath != "" {
+		err = watcher.Add(filepath.Dir(triggerFilePath))
+		if err != nil {
+			return
 		}
+	}
+	watched := int(watchedDirectories.Load())
+	stats.recordWatchedDirectories(watched)
+	watching.setLimit(getWatchLimit())
+	events.Printf("%s", watching.summary())
+	warnWatchLimit(watched, getWatchLimit())
+	warnWatchGuards()
+	emit(streamWatchReady, streamEvent{Directories: watched})
 
-		//Add path to watcher.
-		events.Verbosef("Watching %s", path)
-		err = watcher.Add(path)
-		return err
-	})
-	if err != nil && err != fs.SkipDir {
+	return
+}
+
+// Reasons a directory is not watched, see ignoreDirectoryReason().
+const (
+	ignoreReasonTempDir    = "TempDir"
+	ignoreReasonGoCache    = "GoCache"
+	ignoreReasonConfig     = "DirectoriesToIgnore"
+	ignoreReasonAuto       = "AutoIgnore"
+	ignoreReasonDepth      = "MaxWatchDepth"
+	ignoreReasonMaxWatched = "MaxWatchedDirectories"
+)
+
+// ignoreDirectoryReason returns why a directory should not be watched, or a blank
+// string if the directory should be watched. This is used when walking the directory
+// tree in Watch() and DryRun().
+func ignoreDirectoryReason(path string) (reason string, err error) {
+	//Ignore directory if it is the temp directory where built binaries are stored
+	//before running. No need to watch this directory since it stores temp data
+	//from fresher.
+	yes, err := config.Data().IsTempDir(path)
+	if err != nil {
 		return
 	}
+	if yes {
+		return ignoreReasonTempDir, nil
+	}
 
-	//Watch for file change events. When an event does occur, make sure it is a
-	//file write (not CHMOD or something else) and that the file that was changed has
-	//an extension that we watch for (i.e.: no sense in sending events to rebuild
-	//binary if a .docx file was changed).
-	go func() {
-		//Handle double-save events that can sometimes occur. Usually due to "save
-		//new file and rename" method of saving files by text editors/OSes. This is
-		//particularly helpful on Windows as duplicate events occur for each file
-		//save a human initiates.
-		//
-		//This works by setting a timer when a file change event occurs (see time.Reset
-		//below) when a file change event occurs. While the timer is running, before
-		//it expires, other file change events are still received. However, only the
-		//last event is "remembered". After the timer expires, the "remembered" last
-		//event is sent on the events channel causing the rebuild and/or rerun to
-		//occur.
-		//
-		//Taken from: https://github.com/fsnotify/fsnotify/issues/122#issuecomment-1065925569
-		//
-		//Note the immediately below NewTimer related code. This just initiates the
-		//timer and reads the first expiration so the timer can be reset when events
-		//occur.
-		var lastEvent fsnotify.Event
-		timer := time.NewTimer(time.Millisecond)
-		<-timer.C
+	//Ignore directory if Go writes to it while building.
+	if config.Data().IsGoCacheDir(path) {
+		return ignoreReasonGoCache, nil
+	}
 
-		for {
-			select {
-			case err := <-watcher.Errors:
-				if err != nil {
-					errs.Printf("watcher error %s", err)
-				}
+	//Ignore directory if it is in list of ignored directories. Ignored directories
+	//listed in config file are based off of the WorkingDir. The path in the
+	//WalkDirFunc here is also based off of the WorkingDir, so therefore we can
+	//easily compare without having to handle absolute paths.
+	if config.Data().IsDirectoryToIgnore(path) {
+		return ignoreReasonConfig, nil
+	}
 
-			case event := <-watcher.Events:
-				//Ignore event on certain events.
-				if event.Op == fsnotify.Chmod {
-					continue
-				}
+	//Ignore directory if it is a common build output, dependency, etc. directory or
+	//has a .fresherignore file in it.
+	if config.Data().IsAutoIgnoredDirectory(path) {
+		return ignoreReasonAuto, nil
+	}
 
-				//Skip sending event if a non-watched file is changed.
-				if !config.Data().IsExtensionToWatch(filepath.Ext(event.Name)) {
-					continue
-				}
+	//Ignore directory if it is nested too deeply. This prevents watching the entire
+	//filesystem when fresher is accidentally run in the wrong directory, i.e.: $HOME.
+	if max := config.Data().MaxWatchDepth; max > 0 && directoryDepth(path) > max {
+		return ignoreReasonDepth, nil
+	}
 
-				//Store the event and wait a short while to catch duplicate events.
-				lastEvent = event
-				timer.Reset(time.Millisecond * 50)
+	return "", nil
+}
 
-			case <-timer.C:
-				eventName := lastEvent.Name
-				eventType := lastEvent.Op.String()
+// hasUnignoredSubdirectories returns true if a directory that was ignored for the given
+// reason must still be walked since a "!" entry in DirectoriesToIgnore un-ignores one
+// of its subdirectories. Only directories ignored via DirectoriesToIgnore can have
+// subdirectories un-ignored.
+func hasUnignoredSubdirectories(path, reason string) bool {
+	return reason == ignoreReasonConfig && config.Data().HasUnignoredSubdirectories(path)
+}
 
-				events.Verbosef("Sending Event... %s (%s)", eventName, eventType)
+// directoryDepth returns how deeply nested a directory is within WorkingDir. The
+// WorkingDir itself has a depth of 0, each subdirectory has a depth of 1, etc.
+func directoryDepth(path string) int {
+	rel, err := filepath.Rel(config.Data().WorkingDir, path)
+	if err != nil || rel == "." {
+		return 0
+	}
 
-				//Cause binary to be rebuilt and/or rerun.
-				eventsChan <- lastEvent
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
 
-				//Check if binary is currently being built and stop the build if this
-				//event will just result in a rebuild. This saves a bit of time since
-				//we don't build the binary twice (once is ongoing and again for the
-				//new event) and have to wait for the first build to complete before
-				//the second build starts.
-				//
-				//This is not checked in start() since start blocks when build() is
-				//running and thus will not be able to receive a new event until build()
-				//is complete, therefore building can never be killed!
-				rebuildRequired := config.Data().IsRebuildExtension(filepath.Ext(eventName))
-				if buildCmdRunning && rebuildRequired {
-					killBuildingChan <- true
-				}
-			}
+// warnWatchGuards warns if directories weren't watched due to MaxWatchDepth or
+// MaxWatchedDirectories. Most likely fresher is being run in the wrong directory, or
+// a huge directory (i.e.: node_modules) needs to be added to DirectoriesToIgnore.
+func warnWatchGuards() {
+	s := watching.snapshot()
+	if n := s.Ignored[ignoreReasonDepth]; n > 0 {
+		warn.Printf("%d directories nested more than MaxWatchDepth (%d) deep are not watched. Is fresher being run in the right directory?", n, config.Data().MaxWatchDepth)
+	}
+	if n := s.Ignored[ignoreReasonMaxWatched]; n > 0 {
+		warn.Printf("Stopped watching directories at MaxWatchedDirectories (%d), %d not watched. Is fresher being run in the right directory? If so, add large directories to DirectoriesToIgnore or raise MaxWatchedDirectories.", config.Data().MaxWatchedDirectories, n)
+	}
+}
 
+// isInIgnoredDirectory returns true if the path is within a directory that should not
+// be watched. This is used to filter events from the native watcher which, since it
+// is recursive, can't skip ignored directories when being set up.
+func isInIgnoredDirectory(path string) bool {
+	root := filepath.Clean(config.Data().WorkingDir)
+	for dir := filepath.Dir(path); dir != root && dir != "."; dir = filepath.Dir(dir) {
+		//Directories ignored via DirectoriesToIgnore are checked below since a
+		//subdirectory may be un-ignored.
+		reason, _ := ignoreDirectoryReason(dir)
+		if reason != "" && reason != ignoreReasonConfig {
+			return true
 		}
-	}()
 
-	//Watcher is set up to watch for changes in directories.
-	//goroutine watching for file change events will continue running.
+		//Reached the root of the filesystem.
+		if dir == filepath.Dir(dir) {
+			break
+		}
+	}
 
-	return
+	return config.Data().IsDirectoryToIgnore(filepath.Dir(path))
 }
 
 // start watches for file change events and runs the commands to build and run the
@@ -225,6 +455,11 @@ func start() {
 	//on a build error.
 	started := false
 
+	//running is the most recently run binary, and commands the long running
+	//RunCommands started with it, stopped before the binary is rerun.
+	var running *process
+	var commands []*process
+
 	//Wait for file change events to rebuild and rerun the binary. This waits for
 	//file change events sent on the eventsChan as set up in Watch().
 	go func() {
@@ -232,8 +467,21 @@ func start() {
 			//Get event.
 			event := <-eventsChan
 			eventName := event.Name
+
+			//Clear output from the previous run. The initial build's terminal isn't
+			//cleared so that fresher's startup logging, i.e. config warnings, is seen.
+			if started {
+				clearScreen()
+			}
+
 			eventType := event.Op.String()
 			events.Printf("Got Event... %s (%s)", eventName, eventType)
+			emit(streamFileChanged, streamEvent{File: eventName, Op: eventType})
+
+			//A file change may have fixed whatever was causing the binary to crash.
+			if eventName != autoRestartEventName {
+				resetCrashLoop()
+			}
 
 			//Track if build is successful so we know to stop watching and building.
 			buildSuccessful := false
@@ -242,50 +490,166 @@ func start() {
 			//rebuild if a .go file changes (unless the binary is using embedded
 			//files). This is simply a performance improver since we do not need to
 			//rebuild the binary if, say, an HTML file is changed.
-			rebuildRequired := config.Data().IsRebuildExtension(filepath.Ext(eventName))
+			//
+			//The binary is always built if it hasn't been started yet since there
+			//is no binary to rerun, unless SkipInitialBuild is set and the binary
+			//built before fresher started is being run.
+			rebuildRequired := isRebuildRequired(event) || (!started && eventName != initialRunEventName)
 			if rebuildRequired {
 				//Binary should be rebuilt.
 
 				//Get build delay so that we don't rebuild too fast. This helps improve
 				//performance a bit when multiple file events occur in rapid succession
 				//since the binary won't be built, the build cancelled (see
-				//killBuildingChan channel), then the build starting again, etc.
+				//killBuild()), then the build starting again, etc.
 				//
 				//The build delay should be low enough not to induce too much latency
 				//before building but long enough to catch rapid file saves.
-				delay := time.Duration(config.Data().BuildDelayMilliseconds) * time.Millisecond
-				events.Verbosef("Waiting %s before rebuilding...", delay)
-				time.Sleep(delay)
-				events.Verbosef("Waiting %s before rebuilding...done", delay)
-
-				//Clear the error log since we are rebuilding the binary.
-				err := deleteBuildErrorsLog()
-				if err != nil && !os.IsNotExist(err) {
-					errs.Printf("Error deleting build log %s", err)
-					//not exiting on error since this isn't an end-of-the-world event.
+				//
+				//The initial build isn't delayed since there are no rapid file saves
+				//to wait for when fresher is just starting.
+				if delay := buildDelay(eventName); delay > 0 {
+					events.Verbosef("Waiting %s before rebuilding...", delay)
+					clk.Sleep(delay)
+					events.Verbosef("Waiting %s before rebuilding...done", delay)
+				}
+
+				//Clear the error log since we are rebuilding the binary. The log is
+				//kept when appending since the user wants a history of failures.
+				if config.Data().BuildLogMode != config.BuildLogModeAppend {
+					err := deleteBuildErrorsLog()
+					if err != nil && !os.IsNotExist(err) {
+						errs.Printf("Error deleting build log %s", err)
+						//not exiting on error since this isn't an end-of-the-world event.
+					}
+				}
+
+				//Show what changed since the last successful build. This is most
+				//useful when builds keep failing and edits pile up.
+				if changes := status.changesSummary(); changes != "" {
+					events.Printf("Changed since last successful build: %s", changes)
 				}
 
+				//Note the changes being built before building since the changes
+				//are cleared once the build succeeds.
+				changedFiles := status.snapshot().ChangedFiles
+				checkFormatting(changedFiles)
+				runHooks(hookPreBuild, hookEvent{File: eventName, Op: eventType})
+
+				//Stop warming the build cache, cross checking, and benchmarks, if
+				//still running so that they don't compete with building.
+				stopWarmingBuildCache()
+				stopCrossCheck()
+				benchmarks.stop()
+
 				//Build the binary. Same as running `go build`.
-				err = build(event)
+				buildStart := time.Now()
+				status.setBuilding()
+				emit(streamBuildStarted, streamEvent{File: eventName, Op: eventType})
+				err := generators.run()
+				if err == nil {
+					err = activeBuilder.Build(config.Data(), event)
+				} else {
+					lastBuildErrors = nil
+					lastBuildOutput = ""
+				}
+				buildDuration := time.Since(buildStart)
+				stats.recordBuild(buildDuration, err)
+				status.setBuildResult(err, lastBuildErrors)
+
+				//Classify why the build failed so that a hint on how to fix it can
+				//be given.
+				var failure, hint string
+				if err != nil && err != errBuildKilled {
+					failure, hint = classifyBuildFailure(err, lastBuildOutput, lastBuildErrors)
+				}
+
+				switch {
+				case err == errBuildKilled:
+					emit(streamBuildKilled, streamEvent{File: eventName, Op: eventType})
+				case err != nil:
+					emit(streamBuildFailed, streamEvent{File: eventName, Op: eventType, DurationSeconds: buildDuration.Seconds(), Error: err.Error(), Errors: lastBuildErrors, Failure: failure})
+					runHooks(hookPostBuildFailure, hookEvent{File: eventName, Op: eventType, ChangedFiles: changedFiles, BuildDurationSeconds: buildDuration.Seconds(), BuildOutput: lastBuildOutput, Error: err.Error(), Errors: lastBuildErrors})
+				default:
+					emit(streamBuildSucceeded, streamEvent{File: eventName, Op: eventType, DurationSeconds: buildDuration.Seconds()})
+					runHooks(hookPostBuildSuccess, hookEvent{File: eventName, Op: eventType, ChangedFiles: changedFiles, BuildDurationSeconds: buildDuration.Seconds(), BuildOutput: lastBuildOutput})
+				}
+				if err != errBuildKilled {
+					events.Printf("%s", stats.summary())
+				}
+
 				if err == errBuildKilled {
 					buildSuccessful = false
 				} else if err != nil {
 					errs.Printf("Build Failed %s", err)
-					if !started {
+					if hint != "" && !isRepeatedFailure() {
+						warn.Printf("Hint: %s", hint)
+					}
+					openEditor(lastBuildErrors)
+					handleBuildFailed(lastBuildErrors)
+
+					if !started && eventName == initialEventName {
 						//Build failed and the binary never stared running, exit fresher.
 						//This should only occur when fresher just starts and builds
-						//the binary for the first time.
+						//the binary for the first time. With SkipInitialRun, there is
+						//no initial build so fresher keeps waiting for the code to be
+						//fixed.
+						stats.report()
+						removePIDFile()
+						removeStatusFile()
 						os.Exit(1)
 					}
 				} else {
 					buildSuccessful = true
+					reportBinarySize()
+					handleBuildSucceeded()
+					detectEmbeddedPaths()
+					detectUsedModules()
+					startCrossCheck()
+					benchmarks.queue()
 				}
 			}
 
+			//Warm the build cache for the rest of the module now that the binary is
+			//built, or was found already built, so warming doesn't slow the initial
+			//build down.
+			if eventName == initialEventName || eventName == initialRunEventName {
+				startWarmingBuildCache()
+			}
+
 			//Handle logging when binary was previously built successfully but failed
 			//building this time. The currently running binary will continue running.
 			if rebuildRequired && !buildSuccessful {
-				errs.Printf("Rebuild failed or killed, previous build still running.")
+				if started {
+					errs.Printf("Rebuild failed or killed, previous build still running.")
+				} else {
+					errs.Printf("Build failed or killed, waiting for a file change.")
+				}
+				continue
+			}
+
+			//A WebAssembly binary isn't run, the browser just needs to reload it.
+			if usingWASM() {
+				reloadWASM()
+				started = true
+				continue
+			}
+
+			//Keep the binary running, rather than restarting it, if the changed
+			//template is broken since the binary would likely fail using it.
+			if started && !rebuildRequired {
+				err := checkTemplate(eventName)
+				if err != nil {
+					errs.Printf("Binary not restarted, template check failed. %s", err)
+					continue
+				}
+			}
+
+			//Leave the binary running, i.e. while it is being stepped through with a
+			//debugger, if the RestartPolicy doesn't allow restarting it now. The
+			//next restart runs the latest build.
+			if started && !isRestartAllowed(eventName, running) {
+				warn.Printf("Binary not restarted, RestartPolicy is %s. Type r and press enter to restart.", config.Data().RestartPolicy)
 				continue
 			}
 
@@ -299,18 +663,60 @@ func start() {
 					events.Verbosef("Running rebuilt binary...")
 				}
 
-				stopChan <- true
+				//Stop the old binary, and wait for it to exit, so that multiple
+				//copies of the binary aren't running at once and the rebuilt
+				//binary isn't run while the old binary is still holding onto
+				//resources, i.e. a port.
+				if running != nil {
+					ranFor := time.Since(running.startedAt)
+					select {
+					case <-running.exited:
+					default:
+						events.Printf("Stopping binary after %s, %s (%s)", ranFor.Round(time.Millisecond), eventName, eventType)
+					}
+
+					running.stop()
+					emit(streamRunStopped, streamEvent{File: eventName, Op: eventType, DurationSeconds: ranFor.Seconds()})
+				}
+				stopRunCommands(commands)
+				stats.recordRestart()
+
+				//Give the old binary's resources time to be released before the
+				//rebuilt binary is run.
+				if delay := config.Data().RunDelayMilliseconds; delay > 0 {
+					events.Verbosef("Waiting %dms before running...", delay)
+					clk.Sleep(time.Duration(delay) * time.Millisecond)
+				}
+				waitForPortsReleased(config.Data().WaitForPorts)
 			} else {
 				events.Verbosef("Running first build of binary...")
 			}
 
 			//Run the newly built binary or restart a previously built binary if a
 			//file was changed that doesn't require a rebuild (i.e.: html).
-			run()
+			runHooks(hookPreRun, hookEvent{File: eventName, Op: eventType})
+
+			//Run the RunCommands, i.e. a database migration, that must run before
+			//the binary. If a command fails, the binary isn't run since it would
+			//likely fail too. A file change will try again.
+			var err error
+			commands, err = startRunCommands()
+			if err != nil {
+				errs.Printf("Binary not run, %s", err)
+				running = nil
+				status.setExited()
+				started = true
+				continue
+			}
+
+			running = run()
+			setCurrent(running, commands)
+			status.setRunning()
+			emit(streamRunStarted, streamEvent{File: eventName, Op: eventType})
 
 			//Add logging line to separate fresher logging output from built
 			//binary's logging output.
-			events.Printf(strings.Repeat("-", 50))
+			events.Printf("%s", runHeader(eventName, eventType))
 
 			//Note that binary is started. This way if a subsequent build fails, the
 			//running binary won't be stopped.
@@ -328,23 +734,19 @@ func deleteBuildErrorsLog() (err error) {
 	return
 }
 
-// buildCmdRunning is used to monitor the state of whether or not the `go build`
-// command is running. This is set in build() when .Start() is called and reset when
-// after .Wait() stops blocking.
-//
-// This is read in start() to check if the binary is currently being built when a new
-// file change event occurs and determines if a message is sent on the killBuildingChan.
-var buildCmdRunning bool = false
-
 // errors returned from build()
 var (
 	//errBuildFailed is returned when a build fails.
 	errBuildFailed = errors.New("build failed")
 
-	//errBuildKilled is returned when a build is killed in the middle of building due
-	//to a message on the killBuildingChan channel. This isn't really an error since
+	//errBuildKilled is returned when a build is killed in the middle of building by
+	//killBuild(). This isn't really an error since
 	//the binary will just be rebuilt (similar error in usage as fs.SkipDir).
 	errBuildKilled = errors.New("build killed")
+
+	//errBuildTimedOut is returned when a build is killed since it took longer than
+	//BuildTimeoutSeconds. Unlike errBuildKilled, this is handled as a failed build.
+	errBuildTimedOut = errors.New("build timed out")
 )
 
 // build builds the binary. This runs `go build` and outputs a binary to the temp
@@ -354,45 +756,19 @@ var (
 // True is returned when build is successful.
 //
 // build() is called in start().
-func build(event fsnotify.Event) (err error) {
+func build(cfg *config.File, event fsnotify.Event) (err error) {
 	//Debugging.
 	eventName := event.Name
 	eventType := event.Op.String()
 
-	//Get path and name to output built binary as. This is a file located in the
-	//temp directory.
-	pathToBuiltBinary := getPathToBuiltBinary()
-
 	//Build arguments passed to "go" command.
-	args := []string{
-		"build",
-		"-o", pathToBuiltBinary,
-	}
-
-	//Handle other go build flags.
-	if len(config.Data().GoTags) > 0 {
-		args = append(args, "-tags", config.Data().GoTags)
-	}
-
-	if len(config.Data().GoLdflags) > 0 {
-		args = append(args, "-ldflags", config.Data().GoLdflags)
-	}
-
-	if config.Data().GoTrimpath {
-		args = append(args, "-trimpath")
-	}
-
-	//Get path to entry point of app. This is typically just the repository root,
-	//but could be a subdirectory as well.
-	entryPoint := config.Data().EntryPoint
-
-	//Add the entry point to build the binary from.
-	args = append(args, entryPoint)
+	args := getBuildArgs(cfg)
 
 	//Initialize the command, but do not run it.
 	buildStartTime := time.Now()
 	cmd := exec.Command("go", args...)
-	if config.Data().Verbose {
+	cmd.Env = getBuildEnv(cfg)
+	if cfg.IsLogLevel(config.LogLevelDebug) {
 		events.Verbosef("Building... %s %s", "go", strings.Join(args, " "))
 	} else {
 		events.Printf("Building... %s (%s)", eventName, eventType)
@@ -411,83 +787,77 @@ func build(event fsnotify.Event) (err error) {
 		return
 	}
 
-	//Start handler to kill builds if needed. This is used to stop builds when another
-	//file change will cause build() to be run again. Since we are just going to
-	//build the binary again almost instantly after this build completes, we can kill
-	//off the running build.
-	//
-	//We use cancelKiller to stop the goroutine once a build completes successfully,
-	//this way we don't have this goroutine sitting around needlessly after a build
-	//is completed.
-	//
-	//buildKilled is used to return a specific error when a build is killed.
-	cancelKiller := make(chan bool, 1)
-	buildKilled := false
-	go func() {
-		select {
-		case x := <-killBuildingChan:
-			if x {
-				//Not using errs/warn/events logger here on purpose. I think it causes
-				//a panic when fresher is left running, a computer sleeps, and then
-				//wakes back up. Using log.Println() seems to alleviate the issue.
-				log.Println("Building...killed")
-
-				err := cmd.Process.Kill()
-				if err != nil {
-					log.Printf("Killing build error %s", err)
-				}
-
-				buildKilled = true
-			}
-
-		case <-cancelKiller:
-			//Terminate this goroutine since build was completed. This was we don't
-			//end up with endlessly running goroutines.
-		}
-	}()
-
-	//Run the command, go build...
-	buildCmdRunning = true
-	err = cmd.Start()
+	//Run the command, go build... The build is killed, by killBuild(), if another
+	//file change event will just cause the binary to be built again.
+	err = startBuild(cmd)
 	if err != nil {
 		return
 	}
 
-	//Copy output for stdout to fresher's stdout. This way user sees output from
-	//building.
-	_, err = io.Copy(os.Stdout, stdout)
-	if err != nil {
-		return
+	//Kill the build if it takes too long, i.e. a hung cgo compile. The output is
+	//closed since processes started by `go build` may keep it open, which would
+	//block reading the output until they exit.
+	if timeout := cfg.BuildTimeoutSeconds; timeout > 0 {
+		timer := time.AfterFunc(time.Duration(timeout)*time.Second, func() {
+			if timeoutBuild(cmd) {
+				stdout.Close()
+				stderr.Close()
+			}
+		})
+		defer timer.Stop()
 	}
 
 	//Capture stderr since it might have a bunch of diagnostic info about why built
 	//failed. Stderr is saved to error file so that it is easier to inspect then
 	//reading in terminal output.
-	errBuf, err := io.ReadAll(stderr)
-	if err != nil {
-		errs.Printf("Error capturing stderr %s", err)
-	}
+	//
+	//Stderr is read at the same time as stdout since verbose output can fill the
+	//pipe's buffer, blocking `go build` before it closes stdout. Output is shown
+	//line by line as it is written so the user can watch long builds progress, see
+	//streamBuildOutput().
+	verbose := isBuildVerbose(cfg)
+	var stderrBuf bytes.Buffer
+	stderrDone := make(chan struct{})
+	go func() {
+		streamBuildOutput(os.Stderr, stderr, true, verbose, &stderrBuf)
+		close(stderrDone)
+	}()
+
+	streamBuildOutput(os.Stdout, stdout, false, verbose, nil)
+	<-stderrDone
+	errBuf := stderrBuf.Bytes()
 
 	//Wait for command to finish. Have to handle build being killed by us!
+	//
+	//A failed build, i.e.: a compile error, causes `go build` to exit with a
+	//non-zero status code and an error from Wait(). We still want to handle the
+	//output in stderr in this case since it describes why the build failed.
 	err = cmd.Wait()
-	if err != nil && buildKilled {
-		return errBuildKilled
-	} else if err != nil {
+	if stopped := finishBuild(); stopped == errBuildTimedOut {
+		lastBuildErrors = nil
+		lastBuildOutput = string(errBuf)
+		saveBuildErrorsLog(string(errBuf), event)
+		return stopped
+	} else if stopped != nil {
+		return stopped
+	} else if err != nil && len(errBuf) == 0 {
 		return
 	}
 
-	//Build is complete. Stop the goroutine the monitors if the build should be stopped
-	//while it is running (in case another file change event occured while building
-	//that would just cause the binary to be built again anyway).
-	buildCmdRunning = false
-	cancelKiller <- true
-
 	//If an error occured, write the output to a log file. There could be useful info
-	//such as stack traces or other logging to identify issue in this error.
-	if len(errBuf) > 0 {
-		saveBuildErrorsLog(string(errBuf))
+	//such as stack traces or other logging to identify issue in this error. The
+	//errors are also parsed and shown to the user so they don't have to go looking
+	//through the log file.
+	lastBuildErrors = nil
+	lastBuildOutput = string(errBuf)
+	if len(errBuf) > 0 && err != nil {
+		saveBuildErrorsLog(string(errBuf), event)
+
+		lastBuildErrors = parseBuildErrors(string(errBuf))
+		reportBuildFailure(string(errBuf), lastBuildErrors)
 		return errBuildFailed
 	}
+	repeatedFailure.output, repeatedFailure.count = "", 0
 
 	//Extra logging.
 	events.Verbosef("Building... %s %s (Took %s)", "go", strings.Join(args, " "), time.Since(buildStartTime))
@@ -496,101 +866,377 @@ func build(event fsnotify.Event) (err error) {
 	return
 }
 
+// getBuildArgs returns the arguments passed to the "go" command to build the binary.
+func getBuildArgs(cfg *config.File) []string {
+	//Get path and name to output built binary as. This is a file located in the
+	//temp directory.
+	pathToBuiltBinary := getPathToBuiltBinary(cfg)
+
+	args := []string{
+		"build",
+		"-o", pathToBuiltBinary,
+	}
+
+	//Handle other go build flags.
+	args = append(args, getBuildFlags(cfg)...)
+
+	//Show which packages are recompiled, and how, to diagnose slow builds.
+	if isBuildVerbose(cfg) {
+		args = append(args, "-v", "-x")
+	}
+
+	//Get path to entry point of app. This is typically just the repository root,
+	//but could be a subdirectory as well. Add the entry point to build the binary
+	//from.
+	args = append(args, cfg.EntryPoint)
+
+	return args
+}
+
+// getBuildFlags returns the flags, set in the config file, that are passed to `go
+// build`.
+func getBuildFlags(cfg *config.File) (flags []string) {
+	if tags := cfg.BuildTags(); len(tags) > 0 {
+		flags = append(flags, "-tags", tags)
+	}
+
+	if ldflags := cfg.BuildLdflags(); len(ldflags) > 0 {
+		flags = append(flags, "-ldflags", ldflags)
+	}
+
+	if cfg.GoTrimpath {
+		flags = append(flags, "-trimpath")
+	}
+
+	if vcs := getBuildVCSFlag(cfg); vcs != "" {
+		flags = append(flags, vcs)
+	}
+
+	if mod := cfg.GoMod; mod != "" {
+		flags = append(flags, "-mod="+mod)
+	}
+
+	if p := cfg.BuildParallelism; p > 0 {
+		flags = append(flags, "-p", strconv.Itoa(p))
+	}
+
+	return
+}
+
+// buildDelay returns how long to wait before building for the event. See
+// BuildDelayMilliseconds in the config file.
+func buildDelay(eventName string) time.Duration {
+	if eventName == initialEventName {
+		return 0
+	}
+
+	return time.Duration(config.Data().BuildDelayMilliseconds) * time.Millisecond
+}
+
+// startBuildCommand starts a `go build`, or similar, command at a lower priority if
+// BuildLowPriority is set.
+func startBuildCommand(cmd *exec.Cmd) error {
+	if !config.Data().BuildLowPriority {
+		return cmd.Start()
+	}
+
+	return startLowPriority(cmd)
+}
+
+// isBuildVerbose returns true if the output from `go build` should be verbose and
+// shown as the binary is built.
+func isBuildVerbose(cfg *config.File) bool {
+	return cfg.BuildVerbose || cfg.IsLogLevel(config.LogLevelTrace)
+}
+
+// getBuildEnv returns the environment `go build` is run with. This is nil, meaning
+// fresher's environment, unless the binary is built for somewhere other than the host.
+func getBuildEnv(cfg *config.File) []string {
+	switch {
+	case cfg.WASMAddress != "":
+		return withBuildConfigEnv(cfg, withGoCacheEnv(withGitEnv(cfg, wasmBuildEnv())))
+	case cfg.DockerService != "":
+		return withBuildConfigEnv(cfg, withGoCacheEnv(withGitEnv(cfg, dockerBuildEnv())))
+	default:
+		return withBuildConfigEnv(cfg, withGoCacheEnv(withGitEnv(cfg, nil)))
+	}
+}
+
 // getPathToBuiltBinary returns the path to where the build binary will be saved.
 // Basically, append BuildName to TempDir and add .exe if needed.
-func getPathToBuiltBinary() string {
-	path := filepath.Join(config.Data().TempDir, config.Data().BuildName)
-	if runtime.GOOS == "windows" && filepath.Ext(path) != ".exe" {
+func getPathToBuiltBinary(cfg *config.File) string {
+	path := filepath.Join(cfg.TempDir, cfg.BuildName)
+	if cfg.WASMAddress != "" {
+		return path + ".wasm"
+	}
+	if runtime.GOOS == "windows" && cfg.DockerService == "" && filepath.Ext(path) != ".exe" {
 		path += ".exe"
 	}
 
 	return path
 }
 
+// getBinaryEnv returns the environment the binary is run with, fresher's environment
+// plus the Env set in the config file and the Env of the build config in use.
+func getBinaryEnv(cfg *config.File) []string {
+	env := os.Environ()
+
+	//Sorted so the environment is the same each time the binary is run.
+	keys := []string{}
+	for k := range cfg.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		env = append(env, k+"="+cfg.Env[k])
+	}
+
+	return withBuildConfigEnv(cfg, env)
+}
+
 // saveBuildErrorsLog saves the stderr output from `go build` when build() is called
 // to a file. This file is deleted each time a build is attempted via
-// deleteBuildErrorsLog which is called in start().
-func saveBuildErrorsLog(message string) {
+// deleteBuildErrorsLog which is called in start(), unless BuildLogMode is set to
+// append in which case the output is appended to the file with a header noting when
+// the failure occured and which file change triggered the build.
+func saveBuildErrorsLog(message string, event fsnotify.Event) {
 	//Get path to log file.
 	pathToFile := filepath.Join(config.Data().TempDir, config.Data().BuildLogFilename)
 
-	//Create the file.
-	f, err := os.Create(pathToFile)
+	//Overwrite mode, the default, just saves the latest output.
+	if config.Data().BuildLogMode != config.BuildLogModeAppend {
+		err := os.WriteFile(pathToFile, []byte(message), 0644)
+		if err != nil {
+			errs.Printf("Could not write log file %s", err)
+			//not exiting on error since we don't do anything with error anyway.
+		}
+		return
+	}
+
+	//Append mode keeps a history of failures. Each failure is prefixed with a header
+	//so that failures can be told apart.
+	header := fmt.Sprintf(
+		"%s %s (%s: %s)\n",
+		buildLogHeaderPrefix,
+		time.Now().Format(time.RFC3339),
+		event.Op.String(),
+		event.Name,
+	)
+
+	f, err := os.OpenFile(pathToFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
-		errs.Printf("Could not create log file %s", err)
-		//not exiting on error since we don't do anything with error anyway.
+		errs.Printf("Could not open log file %s", err)
+		return
 	}
-	defer f.Close()
 
-	//Write to file
-	_, err = f.WriteString(message)
+	_, err = f.WriteString(header + message + "\n")
+	f.Close()
 	if err != nil {
 		errs.Printf("Could not write log file %s", err)
-		//not exiting on error since we don't do anything with error anyway.
+		return
+	}
+
+	//Make sure the log file doesn't grow endlessly.
+	maxSize := int64(config.Data().BuildLogMaxSizeKB) * 1024
+	err = trimBuildErrorsLog(pathToFile, maxSize)
+	if err != nil {
+		errs.Printf("Could not trim log file %s", err)
+	}
+}
+
+// buildLogHeaderPrefix is written at the start of each failure's header in the build
+// errors log when BuildLogMode is append. This is used to find where each failure
+// starts when trimming the log.
+const buildLogHeaderPrefix = "=====>"
+
+// trimBuildErrorsLog removes the oldest failures from the build errors log so that
+// the log is no larger than maxSize. Whole failures are removed, based on the header
+// written in saveBuildErrorsLog(), so that a partial failure isn't left at the top of
+// the file. A maxSize of 0 means the log is never trimmed.
+func trimBuildErrorsLog(path string, maxSize int64) (err error) {
+	if maxSize <= 0 {
+		return
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return
 	}
+	if int64(len(b)) <= maxSize {
+		return
+	}
+
+	//Keep the newest maxSize bytes, then skip forward to the next header.
+	b = b[int64(len(b))-maxSize:]
+	i := strings.Index(string(b), buildLogHeaderPrefix)
+	if i > 0 {
+		b = b[i:]
+	}
+
+	return os.WriteFile(path, b, 0644)
 }
 
-// run runs the binary build in build().
+// run runs the binary build in build(). The returned process is used to stop the
+// binary when it is rebuilt.
 //
 // run() is called in start().
-func run() {
-	//Get path to built binary.
-	pathToBuiltBinary := getPathToBuiltBinary()
-
+func run() *process {
 	//Initialize the command, but do not run it.
-	cmd := exec.Command(pathToBuiltBinary)
-	if config.Data().Verbose {
-		events.Printf("Running... %s", pathToBuiltBinary)
+	cfg := config.Data()
+	if cfg.IsLogLevel(config.LogLevelDebug) {
+		events.Printf("Running... %s", getPathToBuiltBinary(cfg))
 	} else {
 		events.Printf("Running...")
 	}
-	if len(config.Data().Args) > 0 {
-		cmd.Args = append(cmd.Args, config.Data().Args...)
+	cmd := activeRunner.Command(cfg)
+
+	//Run the command/binary. Output from the binary is copied to fresher's output
+	//so the user can see any output from running the binary to diagnose issues.
+	//The output is also saved to this run's log file, if enabled.
+	runLog := openRunLog()
+	var saveTo io.Writer
+	if runLog != nil {
+		saveTo = runLog
 	}
 
-	//Set up logging for when the command runs. We want to capture the output logging
-	//and output it to the user running fresher. This is so the user can see any output
-	//from running the binary to diagnose issues.
-	stderr, err := cmd.StderrPipe()
+	p, err := startProcess(cmd, "", saveTo)
 	if err != nil {
+		removePIDFile()
+		removeStatusFile()
 		log.Fatalln(err)
 	}
+	setBinaryPID(cmd.Process.Pid, cmd.Path)
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Fatalln(err)
-	}
+	//Handle the binary exiting on its own, i.e. it crashed or a port was in use.
+	go func() {
+		<-p.exited
+		closeRunLog(runLog, p.err, time.Since(p.startedAt))
+		runHooks(hookPostStop, hookEvent{})
+		if !p.stopped.Load() {
+			status.setExited()
+			handleBinaryExited(p.err, time.Since(p.startedAt))
+		}
+	}()
 
-	//Run the command/binary.
-	err = cmd.Start()
-	if err != nil {
-		log.Fatalln(err)
+	return p
+}
+
+// current is the binary, and the long running RunCommands, most recently started by
+// start(). This is used to stop them if fresher exits due to an error, see Start().
+var current struct {
+	mu       sync.Mutex
+	binary   *process
+	commands []*process
+}
+
+// setCurrent notes the binary, and RunCommands, most recently started.
+func setCurrent(binary *process, commands []*process) {
+	current.mu.Lock()
+	defer current.mu.Unlock()
+
+	current.binary = binary
+	current.commands = commands
+}
+
+// stopCurrent stops the binary, and RunCommands, most recently started, if any.
+func stopCurrent() {
+	current.mu.Lock()
+	defer current.mu.Unlock()
+
+	if current.binary != nil {
+		current.binary.stop()
 	}
+	stopRunCommands(current.commands)
+}
 
-	//Copy output from the command to output from fresher. This way the output from
-	//the binary is displayed to the user in real time.
-	go io.Copy(os.Stderr, stderr)
-	go io.Copy(os.Stdout, stdout)
+// shutdown cleans up before fresher exits. The binary and RunCommands are stopped
+// since RunCommands are run in their own process group and would be left running,
+// the interrupt from the terminal isn't sent to them.
+func shutdown() {
+	stopTUI()
+	stopCurrent()
+	removePIDFile()
+	removeStatusFile()
+}
 
-	//Stop the running binary if it has been rebuilt and will be rerun. This prevents
-	//multiple built binaries from running at one time.
+// Start watches for file changes, see Watch(), and calls start() to handle building
+// and running the binary. Watching is set up at the same time as the first build
+// since walking the directory tree can take many seconds on huge repos and there is
+// no reason to wait on it before building.
+//
+// Start blocks until fresher is stopped. If watching fails, the binary is stopped, the
+// PID and status files are removed, and the error is returned.
+func Start() (err error) {
+	watchErr := make(chan error, 1)
 	go func() {
-		<-stopChan
-		cmd.Process.Kill()
+		watchErr <- Watch()
 	}()
-}
 
-// Start calls start() to handle building the running the binary.
-func Start() {
 	start()
 
+	//Rebuild when requested via a signal.
+	watchRebuildSignal()
+
+	//Handle commands typed in the terminal, i.e. printing the watcher stats.
+	watchKeypresses()
+
+	//Run asset commands as front end assets change.
+	startAssets()
+
+	//Check for vulnerabilities as dependencies change.
+	startVulnCheck()
+
+	//Run benchmarks after each successful build.
+	startBenchmarks()
+
+	//Download modules before the first build, if enabled, so that the first build
+	//isn't slow for no apparent reason.
+	downloadModules()
+
 	//Send an event to build and run the binary for the first time when fresher
-	//starts. "/" is just a random string to trigger building.
-	eventsChan <- fsnotify.Event{
-		Name: "/",
-		Op:   fsnotify.Write,
+	//starts, unless the user only wants to build once a file changes.
+	//
+	//With SkipInitialBuild, the already built binary is run instead of building an
+	//identical binary. The binary is built if it doesn't exist.
+	initialEvent := fsnotify.Event{Name: initialEventName, Op: fsnotify.Write}
+	if config.Data().SkipInitialBuild {
+		if _, err := os.Stat(getPathToBuiltBinary(config.Data())); err == nil {
+			initialEvent.Name = initialRunEventName
+		} else {
+			warn.Printf("Built binary %s not found, building.", getPathToBuiltBinary(config.Data()))
+		}
+	}
+
+	if config.Data().SkipInitialRun {
+		events.Printf("Waiting for a file change to build and run...")
+		startWarmingBuildCache()
+	} else {
+		eventsChan <- initialEvent
 	}
 
 	//Block indefintely to continuously watch for file changes and rebuild as needed.
-	<-make(chan int)
+	//When fresher is stopped (i.e.: CTRL+C), log out the build statistics so the
+	//user can see how builds performed over the life of fresher.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	for {
+		select {
+		case <-sig:
+			shutdown()
+			events.Printf(strings.Repeat("-", 50))
+			stats.report()
+			os.Exit(0)
+
+		case err = <-watchErr:
+			if err == nil {
+				//Watcher is set up, stop checking for an error.
+				watchErr = nil
+				continue
+			}
+
+			shutdown()
+			return err
+		}
+	}
 }
@@ -3,17 +3,143 @@
 package runner3
 
 import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
-func setRLimit() (err error) {
+// setRLimit raises the limit on open file descriptors to limit. If the hard limit is
+// lower than limit and raising the hard limit isn't permitted, i.e.: not running as
+// root, the soft limit is raised to the hard limit instead. The limit in effect is
+// returned so that the caller can warn if it is lower than requested.
+//
+// The limit is never lowered since that could break watching in big repos that
+// worked before MaxOpenFiles was configurable.
+func setRLimit(limit uint64) (current uint64, err error) {
 	var rLimit syscall.Rlimit
-	rLimit.Max = 10000
-	rLimit.Cur = 10000
-	err = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rLimit)
+	err = syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rLimit)
 	if err != nil {
 		return
 	}
+	if uint64(rLimit.Cur) >= limit {
+		return uint64(rLimit.Cur), nil
+	}
+
+	//Try raising both the soft and hard limits.
+	raised := rLimit
+	setRlimitValue(&raised.Cur, limit)
+	if uint64(raised.Max) < limit {
+		setRlimitValue(&raised.Max, limit)
+	}
+	err = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &raised)
+	if err == nil {
+		return limit, nil
+	}
+
+	//Fall back to raising the soft limit as high as the hard limit allows.
+	raised = rLimit
+	raised.Cur = raised.Max
+	err = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &raised)
+	if err != nil {
+		return uint64(rLimit.Cur), err
+	}
+
+	return uint64(raised.Cur), nil
+}
+
+// setRlimitValue sets a syscall.Rlimit field to n. This is needed since the type of
+// the fields differs between OSes (uint64 on most, int64 on FreeBSD).
+func setRlimitValue[T ~int64 | ~uint64](field *T, n uint64) {
+	*field = T(n)
+}
+
+// notifyRebuildSignal relays SIGUSR1 to c. True is returned since rebuilding via a
+// signal is supported.
+func notifyRebuildSignal(c chan os.Signal) bool {
+	signal.Notify(c, syscall.SIGUSR1)
+	return true
+}
+
+// isProcessRunning returns true if a process with the given PID exists. Sending
+// signal 0 checks for the process's existence without affecting it.
+func isProcessRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// processExecutable returns the path to the executable the process with the given PID
+// is running. /proc is used on Linux. Other OSes, i.e. macOS, use `ps` which may only
+// report the executable's name.
+func processExecutable(pid int) (string, error) {
+	exe, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(pid), "exe"))
+	if err == nil {
+		//The binary is replaced each time it is rebuilt.
+		return strings.TrimSuffix(exe, " (deleted)"), nil
+	}
+
+	out, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// killProcessTree stops the process. If the process was started in its own process
+// group, see setProcessGroup(), the whole group is killed so that processes it started
+// are stopped too. Otherwise, only the process itself is killed and processes it
+// started are reparented and keep running once it exits.
+func killProcessTree(p *os.Process) error {
+	pgid, err := syscall.Getpgid(p.Pid)
+	if err == nil && pgid == p.Pid {
+		return syscall.Kill(-pgid, syscall.SIGKILL)
+	}
+
+	return p.Kill()
+}
+
+// setProcessGroup starts the command in its own process group so that
+// killProcessTree() stops the processes it starts too, i.e. a shell script's
+// children.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// buildNiceness is the nice value builds are run at when BuildLowPriority is set. 10
+// is what `nice` uses by default.
+const buildNiceness = 10
+
+// startLowPriority starts the command and lowers its priority. The priority is
+// lowered right after starting, rather than via a wrapper like `nice`, so that no
+// other tools need to be installed. The compilers run by `go build` inherit the
+// priority since they are started after `go build` has loaded the packages to build.
+func startLowPriority(cmd *exec.Cmd) error {
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	pid := cmd.Process.Pid
+	err = syscall.Setpriority(syscall.PRIO_PROCESS, pid, buildNiceness)
+	if err != nil {
+		warn.Verbosef("Could not lower build priority %s", err)
+	}
+
+	err = lowerIOPriority(pid)
+	if err != nil {
+		warn.Verbosef("Could not lower build disk priority %s", err)
+	}
 
-	return
+	return nil
 }
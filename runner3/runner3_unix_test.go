@@ -0,0 +1,55 @@
+//go:build unix
+
+package runner3
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestKillProcessTreeGroup(t *testing.T) {
+	withoutOutput(t)
+
+	//The pipe is held open by the helper process and its child, reading returns
+	//EOF once both have exited.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer r.Close()
+
+	cmd := helperCommand("grandchild")
+	cmd.ExtraFiles = []*os.File{w}
+	setProcessGroup(cmd)
+	p, err := startProcess(cmd, "grandchild", nil)
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Wait for the child process to start.
+	_, err = r.Read(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	p.stop()
+
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, r)
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Child process of the stopped process is still running.")
+		return
+	}
+}
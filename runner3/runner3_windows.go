@@ -2,6 +2,89 @@
 
 package runner3
 
-func setRLimit() (err error) {
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+// belowNormalPriorityClass is the Windows BELOW_NORMAL_PRIORITY_CLASS process
+// creation flag. This isn't defined in the syscall package.
+const belowNormalPriorityClass = 0x00004000
+
+// setRLimit does nothing since Windows doesn't limit open file descriptors the same
+// way. The requested limit is returned so that no warning is shown.
+func setRLimit(limit uint64) (current uint64, err error) {
+	return limit, nil
+}
+
+// notifyRebuildSignal does nothing since Windows doesn't have SIGUSR1. False is
+// returned since rebuilding via a signal is not supported.
+func notifyRebuildSignal(c chan os.Signal) bool {
+	return false
+}
+
+// isProcessRunning returns true if a process with the given PID exists. On Windows,
+// FindProcess opens a handle to the process which fails if the process doesn't exist.
+func isProcessRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	p.Release()
+	return true
+}
+
+// processExecutable returns the path to the executable the process with the given PID
+// is running.
+func processExecutable(pid int) (string, error) {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h)
+
+	buf := make([]uint16, windows.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	err = windows.QueryFullProcessImageName(h, 0, &buf[0], &size)
+	if err != nil {
+		return "", err
+	}
+
+	return windows.UTF16ToString(buf[:size]), nil
+}
+
+// startLowPriority starts the command with the below normal priority class. The
+// compilers run by `go build` inherit the priority class.
+func startLowPriority(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= belowNormalPriorityClass
+
+	return cmd.Start()
+}
+
+// setProcessGroup does nothing since killProcessTree() stops child processes on
+// Windows without a process group.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessTree stops the process and all of its child processes. Windows doesn't
+// stop child processes when their parent is killed, so, without this, the compilers
+// run by `go build`, or processes started by the binary, would be left running and
+// holding files or ports open. taskkill is used since it is included with Windows.
+// If taskkill fails, i.e. the process already exited, the process alone is killed.
+func killProcessTree(p *os.Process) error {
+	cmd := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid))
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	err := cmd.Run()
+	if err != nil {
+		return p.Kill()
+	}
+
 	return nil
 }
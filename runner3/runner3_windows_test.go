@@ -0,0 +1,86 @@
+//go:build windows
+
+package runner3
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestKillProcessTree(t *testing.T) {
+	//cmd runs ping as a child process, ping is what keeps running.
+	cmd := exec.Command("cmd", "/c", "ping", "-n", "30", "127.0.0.1")
+	err := cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Wait for the child process to start.
+	time.Sleep(500 * time.Millisecond)
+
+	err = killProcessTree(cmd.Process)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	//Wait only returns once ping has exited since ping holds cmd's output open.
+	exited := make(chan bool)
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Process tree was not killed.")
+		return
+	}
+
+	if isProcessRunning(cmd.Process.Pid) {
+		t.Fatal("Process should not be running.")
+		return
+	}
+}
+
+func TestIsProcessRunning(t *testing.T) {
+	if !isProcessRunning(os.Getpid()) {
+		t.Fatal("Test process should be running.")
+		return
+	}
+}
+
+func TestSetRLimit(t *testing.T) {
+	current, err := setRLimit(10000)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if current != 10000 {
+		t.Fatal("Requested limit should be returned.", current)
+		return
+	}
+}
+
+func TestStartLowPriority(t *testing.T) {
+	cmd := exec.Command("cmd", "/c", "exit", "0")
+	err := startLowPriority(cmd)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if cmd.SysProcAttr.CreationFlags&belowNormalPriorityClass == 0 {
+		t.Fatal("Below normal priority class not set.")
+		return
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+}
@@ -0,0 +1,58 @@
+package runner3
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/c9845/fresher/config"
+)
+
+// serveStatic starts an HTTP server that serves the files in StaticDirectory. This is
+// only started if StaticAddress is set in the config. This is meant for development,
+// so front end work can be done without the binary running; it should never be used
+// in production.
+//
+// Directories without an index.html are listed, as http.FileServer does by default.
+func serveStatic() {
+	addr := config.Data().StaticAddress
+	if addr == "" {
+		return
+	}
+
+	dir := config.Data().StaticDirectory
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(config.Data().WorkingDir, dir)
+	}
+
+	//Serving anyway if the directory doesn't exist, it may be created later by an
+	//AssetCommand.
+	if _, err := os.Stat(dir); err != nil {
+		warn.Printf("Static directory %s does not exist", dir)
+	}
+
+	fileServer := http.FileServer(http.Dir(dir))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//Disable caching so changed files are always reloaded.
+		w.Header().Set("Cache-Control", "no-store, must-revalidate")
+		w.Header().Set("Expires", "0")
+
+		//Remove conditional request headers so http.FileServer always sends the
+		//file, not a 304 Not Modified.
+		r.Header.Del("If-Modified-Since")
+		r.Header.Del("If-None-Match")
+
+		fileServer.ServeHTTP(w, r)
+	})
+
+	events.Printf("Serving %s at http://%s/", dir, addr)
+
+	go func() {
+		err := http.ListenAndServe(addr, handler)
+		if err != nil {
+			//Not exiting on error since static files are not required for building
+			//and running the binary.
+			errs.Printf("Static server error %s", err)
+		}
+	}()
+}
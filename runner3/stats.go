@@ -0,0 +1,172 @@
+package runner3
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// buildStats tracks statistics about building and running the binary for the life
+// of fresher. This is used to give the user some visibility into how long builds
+// are taking, and if builds are getting slower over time, without having to time
+// builds by hand.
+type buildStats struct {
+	mu sync.Mutex
+
+	builds    int //total number of builds attempted, including killed builds.
+	successes int
+	failures  int
+	killed    int //builds killed due to a newer file change event.
+	restarts  int //number of times the binary was stopped and rerun.
+
+	//Durations are only tracked for builds that ran to completion, successfully or
+	//not, since a killed build's duration doesn't tell us anything useful.
+	lastDuration    time.Duration
+	totalDuration   time.Duration
+	fastestDuration time.Duration
+	slowestDuration time.Duration
+
+	//durationBuckets counts completed builds by duration, for use as a histogram in
+	//the metrics endpoint. Each index matches the upper bound in
+	//buildDurationBuckets, with an extra final index for builds that took longer
+	//than the last bucket (+Inf).
+	durationBuckets [len(buildDurationBuckets) + 1]int
+
+	//binarySize is the size, in bytes, of the binary from the most recent successful
+	//build. This is used to show how much the binary grew, or shrank, with each
+	//build.
+	binarySize int64
+
+	//watchedDirectories is the number of directories being watched for file changes.
+	watchedDirectories int
+
+	startedAt time.Time
+}
+
+// buildDurationBuckets are the upper bounds, in seconds, for grouping build durations.
+var buildDurationBuckets = [...]float64{0.5, 1, 2, 5, 10, 30, 60}
+
+// stats is the package level build statistics. This is updated in start() and read
+// when logging after each build and when fresher exits.
+var stats = buildStats{startedAt: time.Now()}
+
+// recordBuild saves the result of a build. The error should be the error returned
+// from build().
+func (s *buildStats) recordBuild(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.builds++
+
+	if err == errBuildKilled {
+		s.killed++
+		return
+	}
+
+	if err != nil {
+		s.failures++
+	} else {
+		s.successes++
+	}
+
+	s.lastDuration = d
+	s.totalDuration += d
+	if s.fastestDuration == 0 || d < s.fastestDuration {
+		s.fastestDuration = d
+	}
+	if d > s.slowestDuration {
+		s.slowestDuration = d
+	}
+
+	bucket := len(buildDurationBuckets)
+	for i, upperBound := range buildDurationBuckets {
+		if d.Seconds() <= upperBound {
+			bucket = i
+			break
+		}
+	}
+	s.durationBuckets[bucket]++
+}
+
+// recordBinarySize saves the size of the binary from a successful build and returns
+// the change in size from the previous successful build. The change is 0 for the
+// first build.
+func (s *buildStats) recordBinarySize(size int64) (delta int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.binarySize > 0 {
+		delta = size - s.binarySize
+	}
+	s.binarySize = size
+	return
+}
+
+// recordWatchedDirectories saves the number of directories being watched.
+func (s *buildStats) recordWatchedDirectories(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watchedDirectories = n
+}
+
+// recordRestart notes that the running binary was stopped and rerun.
+func (s *buildStats) recordRestart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.restarts++
+}
+
+// counts returns the number of builds attempted and the number of times the binary
+// was rerun.
+func (s *buildStats) counts() (builds, restarts int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.builds, s.restarts
+}
+
+// averageDuration returns the average duration of all completed builds. This must
+// be called with the lock held.
+func (s *buildStats) averageDuration() time.Duration {
+	completed := s.successes + s.failures
+	if completed == 0 {
+		return 0
+	}
+
+	return s.totalDuration / time.Duration(completed)
+}
+
+// summary returns a short, one line, description of the most recent build. This is
+// logged after each build, i.e.: "build #42, 1.8s, avg 2.1s".
+func (s *buildStats) summary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return fmt.Sprintf(
+		"build #%d, %s, avg %s",
+		s.builds,
+		s.lastDuration.Round(time.Millisecond),
+		s.averageDuration().Round(time.Millisecond),
+	)
+}
+
+// report logs out all the statistics gathered. This is called when fresher exits.
+func (s *buildStats) report() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	events.Printf("Ran for %s", time.Since(s.startedAt).Round(time.Second))
+	events.Printf("Builds: %d (%d successful, %d failed, %d killed)", s.builds, s.successes, s.failures, s.killed)
+	events.Printf("Restarts: %d", s.restarts)
+
+	if s.successes+s.failures > 0 {
+		events.Printf(
+			"Build time: avg %s, fastest %s, slowest %s",
+			s.averageDuration().Round(time.Millisecond),
+			s.fastestDuration.Round(time.Millisecond),
+			s.slowestDuration.Round(time.Millisecond),
+		)
+	}
+}
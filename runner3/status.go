@@ -0,0 +1,229 @@
+package runner3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runnerStatus tracks the current state of fresher for reporting to other tools, for
+// example an editor plugin or a tmux status line, via the control API.
+//
+// The fields are exported, with json tags, so that the status can be output as JSON.
+type runnerStatus struct {
+	mu sync.Mutex
+
+	//Building is true while `go build` is running.
+	Building bool `json:"building"`
+
+	//FetchingModules is true while `go build` is downloading modules.
+	FetchingModules bool `json:"fetchingModules"`
+
+	//Paused is true while handling file changes is paused, see watchPause.
+	Paused bool `json:"paused"`
+
+	//Running is true once the binary has been started, until it exits on its own.
+	Running bool `json:"running"`
+
+	//Exited is true when the binary exited on its own, i.e. it crashed, rather than
+	//being stopped to be rerun.
+	Exited bool `json:"exited"`
+
+	//BinaryStartedAt is when the binary was last started or restarted.
+	BinaryStartedAt time.Time `json:"binaryStartedAt"`
+
+	//LastBuildFailed is true when the most recent build failed. The previously built
+	//binary, if any, is still running.
+	LastBuildFailed bool `json:"lastBuildFailed"`
+
+	//LastBuildAt is when the most recent build completed and LastBuildSeconds is
+	//how long it took. Killed builds are not included.
+	LastBuildAt      time.Time `json:"lastBuildAt"`
+	LastBuildSeconds float64   `json:"lastBuildSeconds,omitempty"`
+
+	//LastError is the error from the most recent failed build.
+	LastError string `json:"lastError,omitempty"`
+
+	//LastBuildErrors are the errors parsed from the most recent failed build.
+	LastBuildErrors []buildError `json:"lastBuildErrors,omitempty"`
+
+	//ChangedFiles are the files changed since the last successful build, with the
+	//number of times each file was changed. This helps keep track of what was edited
+	//while builds are failing.
+	ChangedFiles map[string]int `json:"changedFiles,omitempty"`
+
+	//LastChangedFile is the most recently changed file.
+	LastChangedFile string `json:"lastChangedFile,omitempty"`
+
+	//buildStartedAt is when the current, or most recent, build started.
+	buildStartedAt time.Time
+}
+
+// status is the package level status. This is updated in start() and read by the
+// control API.
+var status runnerStatus
+
+// setBuilding notes that a build has started.
+func (s *runnerStatus) setBuilding() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Building = true
+	s.buildStartedAt = time.Now()
+}
+
+// setBuildResult notes that a build has completed. The error should be the error
+// returned from build().
+func (s *runnerStatus) setBuildResult(err error, buildErrs []buildError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Building = false
+	s.FetchingModules = false
+
+	//A killed build will just be rebuilt, so the result of the previous completed
+	//build is still the most relevant.
+	if err == errBuildKilled {
+		return
+	}
+
+	s.LastBuildAt = time.Now()
+	s.LastBuildSeconds = s.LastBuildAt.Sub(s.buildStartedAt).Seconds()
+
+	if err != nil {
+		s.LastBuildFailed = true
+		s.LastError = err.Error()
+		s.LastBuildErrors = buildErrs
+		return
+	}
+
+	s.LastBuildFailed = false
+	s.LastError = ""
+	s.LastBuildErrors = nil
+	s.ChangedFiles = nil
+}
+
+// setFetchingModules notes that the build is downloading modules. True is returned the
+// first time this is called for a build so that it is only logged once.
+func (s *runnerStatus) setFetchingModules() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.Building || s.FetchingModules {
+		return false
+	}
+
+	s.FetchingModules = true
+	return true
+}
+
+// recordChange notes that a file was changed. Changes are accumulated until the next
+// successful build.
+func (s *runnerStatus) recordChange(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ChangedFiles == nil {
+		s.ChangedFiles = map[string]int{}
+	}
+	s.ChangedFiles[path]++
+	s.LastChangedFile = path
+}
+
+// changesSummary returns the files changed since the last successful build, sorted,
+// with the number of times each was changed, i.e.: "main.go (3), web/api.go (1)". A
+// blank string is returned if no files were changed.
+func (s *runnerStatus) changesSummary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes := []string{}
+	for path, n := range s.ChangedFiles {
+		changes = append(changes, fmt.Sprintf("%s (%d)", path, n))
+	}
+	sort.Strings(changes)
+
+	return strings.Join(changes, ", ")
+}
+
+// setPaused notes if handling file changes is paused.
+func (s *runnerStatus) setPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Paused = paused
+}
+
+// setRunning notes that the binary was started.
+func (s *runnerStatus) setRunning() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Running = true
+	s.Exited = false
+	s.BinaryStartedAt = time.Now()
+}
+
+// setExited notes that the binary exited on its own.
+func (s *runnerStatus) setExited() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Running = false
+	s.Exited = true
+}
+
+// state returns a single word describing fresher's state: "waiting", "fetching",
+// "building", "paused", "failed", "exited", or "running". A failed build takes precedence over
+// the binary running since the running binary doesn't include the latest changes.
+func (s *runnerStatus) state() string {
+	switch {
+	case s.Building && s.FetchingModules:
+		return "fetching"
+	case s.Building:
+		return "building"
+	case s.Paused:
+		return "paused"
+	case s.LastBuildFailed:
+		return "failed"
+	case s.Exited:
+		return "exited"
+	case s.Running:
+		return "running"
+	default:
+		return "waiting"
+	}
+}
+
+// snapshot returns a copy of the status that is safe to read without holding the
+// lock, for example when encoding to JSON.
+func (s *runnerStatus) snapshot() runnerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changedFiles map[string]int
+	if len(s.ChangedFiles) > 0 {
+		changedFiles = make(map[string]int, len(s.ChangedFiles))
+		for path, n := range s.ChangedFiles {
+			changedFiles[path] = n
+		}
+	}
+
+	return runnerStatus{
+		Building:         s.Building,
+		FetchingModules:  s.FetchingModules,
+		Paused:           s.Paused,
+		Running:          s.Running,
+		Exited:           s.Exited,
+		BinaryStartedAt:  s.BinaryStartedAt,
+		LastBuildFailed:  s.LastBuildFailed,
+		LastBuildAt:      s.LastBuildAt,
+		LastBuildSeconds: s.LastBuildSeconds,
+		LastError:        s.LastError,
+		LastBuildErrors:  s.LastBuildErrors,
+		ChangedFiles:     changedFiles,
+		LastChangedFile:  s.LastChangedFile,
+	}
+}
@@ -0,0 +1,125 @@
+package runner3
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// statusFileInterval is how often the status is checked for changes to save to the
+// status file.
+const statusFileInterval = 250 * time.Millisecond
+
+// statusFileData is the data saved to the status file. This is the same status
+// returned by the control API's /status endpoint, plus a single word state so that
+// simple scripts don't need to check multiple fields.
+type statusFileData struct {
+	State   string `json:"state"`
+	Fresher int    `json:"fresher"`
+
+	//UpdatedAt is when the status last changed, not when the file was last written.
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	*runnerStatus
+}
+
+// statusFile protects writing the status file so that the file isn't rewritten after
+// it is deleted when fresher exits.
+var statusFile struct {
+	mu      sync.Mutex
+	removed bool
+}
+
+// getStatusFilePath returns the path to the status file, or a blank string if the
+// status file is disabled.
+func getStatusFilePath() string {
+	if config.Data().StatusFile == "" {
+		return ""
+	}
+
+	return filepath.Join(config.Data().TempDir, config.Data().StatusFile)
+}
+
+// startStatusFile keeps the status file up to date with fresher's status, if the
+// status file is enabled. The status is checked periodically, rather than the file
+// being written each time the status changes, so that quick successive changes, i.e.
+// a build starting and failing right away, don't each cause a write.
+func startStatusFile() {
+	path := getStatusFilePath()
+	if path == "" {
+		return
+	}
+
+	go func() {
+		var last []byte
+		for {
+			last = updateStatusFile(path, last)
+			time.Sleep(statusFileInterval)
+		}
+	}()
+}
+
+// updateStatusFile writes the status file if the status changed since the last time
+// the file was written. The status, without UpdatedAt, is returned to compare against
+// next time.
+//
+// The status is written to a temporary file and then renamed so that tools reading
+// the status file never see a partially written file.
+func updateStatusFile(path string, last []byte) []byte {
+	snap := status.snapshot()
+	d := statusFileData{
+		State:        snap.state(),
+		Fresher:      os.Getpid(),
+		runnerStatus: &snap,
+	}
+
+	current, err := json.Marshal(d)
+	if err != nil || bytes.Equal(current, last) {
+		return last
+	}
+
+	d.UpdatedAt = time.Now()
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return last
+	}
+
+	statusFile.mu.Lock()
+	defer statusFile.mu.Unlock()
+	if statusFile.removed {
+		return last
+	}
+
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, append(b, '\n'), 0644)
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		errs.Verbosef("Could not write status file %s", err)
+		os.Remove(tmp)
+		return last
+	}
+
+	return current
+}
+
+// removeStatusFile deletes the status file when fresher exits so that tools reading
+// the status file can tell fresher isn't running.
+func removeStatusFile() {
+	path := getStatusFilePath()
+	if path == "" {
+		return
+	}
+
+	statusFile.mu.Lock()
+	defer statusFile.mu.Unlock()
+
+	statusFile.removed = true
+	os.Remove(path)
+}
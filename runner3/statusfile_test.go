@@ -0,0 +1,58 @@
+package runner3
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateStatusFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	status = runnerStatus{}
+
+	//statusFileData can't be unmarshalled into since runnerStatus isn't exported.
+	type fileData struct {
+		State     string    `json:"state"`
+		Fresher   int       `json:"fresher"`
+		UpdatedAt time.Time `json:"updatedAt"`
+		Building  bool      `json:"building"`
+	}
+	read := func() (d fileData) {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		err = json.Unmarshal(b, &d)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		return
+	}
+
+	last := updateStatusFile(path, nil)
+	first := read()
+	if first.State != "waiting" || first.Fresher != os.Getpid() || first.UpdatedAt.IsZero() {
+		t.Fatal("Unexpected status.", first.State, first.Fresher, first.UpdatedAt)
+		return
+	}
+
+	//The file shouldn't be rewritten if the status didn't change.
+	last = updateStatusFile(path, last)
+	if d := read(); !d.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Fatal("Status file rewritten without a change.")
+		return
+	}
+
+	status.setBuilding()
+	updateStatusFile(path, last)
+	if d := read(); d.State != "building" || !d.Building {
+		t.Fatal("Status file not updated.", d.State)
+		return
+	}
+
+	status = runnerStatus{}
+}
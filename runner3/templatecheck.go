@@ -0,0 +1,71 @@
+package runner3
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template/parse"
+
+	"github.com/c9845/fresher/config"
+)
+
+// checkTemplate checks a changed file that doesn't require a rebuild, i.e. an .html
+// template, before the binary is restarted. The file is parsed if it matches the
+// TemplateCheckPaths, and the TemplateCheckCommand is run if set. An error is returned
+// if the file is broken, meaning the binary shouldn't be restarted since it would
+// likely fail when using the template.
+//
+// Events that aren't a file change, i.e. a restart request, aren't checked.
+func checkTemplate(eventName string) error {
+	switch eventName {
+	case restartEventName, autoRestartEventName, initialRunEventName:
+		return nil
+	}
+
+	if config.Data().IsTemplateCheckPath(eventName) {
+		err := parseTemplateFile(eventName)
+		if err != nil {
+			return err
+		}
+	}
+
+	command := config.Data().TemplateCheckCommand
+	if command == "" {
+		return nil
+	}
+
+	events.Verbosef("Checking template... %s", command)
+	fields := strings.Fields(command)
+	cmd := exec.Command(fields[0], fields[1:]...)
+	cmd.Dir = config.Data().WorkingDir
+	cmd.Env = append(os.Environ(), "FRESHER_FILE="+eventName)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("TemplateCheckCommand %s failed %w", command, err)
+	}
+
+	return nil
+}
+
+// parseTemplateFile parses the file using Go's template syntax, the same syntax used
+// by html/template and text/template. Functions aren't checked since the functions
+// the binary provides, via Funcs(), aren't known.
+func parseTemplateFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return parseTemplate(path, string(b))
+}
+
+// parseTemplate parses text using Go's template syntax, see parseTemplateFile().
+func parseTemplate(name, text string) error {
+	t := parse.New(name)
+	t.Mode = parse.SkipFuncCheck | parse.ParseComments
+	_, err := t.Parse(text, "", "", map[string]*parse.Tree{})
+	return err
+}
@@ -0,0 +1,53 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestCheckTemplate(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.html")
+	bad := filepath.Join(dir, "bad.html")
+	other := filepath.Join(dir, "bad.txt")
+	for path, text := range map[string]string{
+		good:  `{{define "page"}}<p>{{.Name | title}}</p>{{/* custom func */}}{{end}}`,
+		bad:   `<p>{{if .Name}}{{.Name}}</p>`,
+		other: `{{if}}`,
+	} {
+		err := os.WriteFile(path, []byte(text), 0644)
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	cfg := config.Defaults()
+	cfg.WorkingDir = dir
+	cfg.TemplateCheckPaths = []string{"*.html"}
+	err := config.Use(cfg)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if err := checkTemplate(good); err != nil {
+		t.Fatal("Template should have parsed.", err)
+		return
+	}
+	if err := checkTemplate(bad); err == nil {
+		t.Fatal("Template should not have parsed.")
+		return
+	}
+	if err := checkTemplate(other); err != nil {
+		t.Fatal("File not matching TemplateCheckPaths should not be checked.", err)
+		return
+	}
+	if err := checkTemplate(restartEventName); err != nil {
+		t.Fatal("Restart request should not be checked.", err)
+		return
+	}
+}
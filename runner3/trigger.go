@@ -0,0 +1,59 @@
+package runner3
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/c9845/fresher/config"
+)
+
+// watchRebuildSignal rebuilds the binary when fresher receives a signal requesting a
+// rebuild (SIGUSR1, i.e. `kill -USR1 <pid>`). This lets other tools, such as git
+// hooks or code generators, request a rebuild without faking a file save. This is
+// not supported on Windows since Windows doesn't have SIGUSR1.
+func watchRebuildSignal() {
+	sig := make(chan os.Signal, 1)
+	if !notifyRebuildSignal(sig) {
+		return
+	}
+
+	go func() {
+		for range sig {
+			events.Printf("Rebuild requested via signal")
+			requestEvent(rebuildEventName)
+		}
+	}()
+}
+
+// getTriggerFilePath returns the absolute path to the trigger file, or a blank
+// string if TriggerFile is not set. An absolute path is used so that it can be
+// compared against the paths from file change events reliably.
+func getTriggerFilePath() string {
+	triggerFile := config.Data().TriggerFile
+	if triggerFile == "" {
+		return ""
+	}
+
+	p, err := filepath.Abs(filepath.Join(config.Data().WorkingDir, triggerFile))
+	if err != nil {
+		return ""
+	}
+
+	return p
+}
+
+// isTriggerFile returns true if the path is the TriggerFile. Touching the trigger
+// file forces a rebuild regardless of the file's extension.
+func isTriggerFile(path string) bool {
+	triggerFilePath := getTriggerFilePath()
+	if triggerFilePath == "" {
+		return false
+	}
+
+	p, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	return p == triggerFilePath
+}
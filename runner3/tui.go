@@ -0,0 +1,186 @@
+package runner3
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+	"github.com/mattn/go-isatty"
+)
+
+// tuiRefreshInterval is how often the status bar is checked for changes, including
+// the terminal being resized.
+const tuiRefreshInterval = 250 * time.Millisecond
+
+// tui shows a status bar on the last line of the terminal with the binary's output,
+// and fresher's logging, scrolling above it. The status bar is kept out of the
+// scrolling output using the terminal's scrolling region, so no terminal UI library
+// is needed and output is written to the terminal the same as in plain log mode.
+var tui struct {
+	mu      sync.Mutex
+	enabled bool
+	rows    int
+	last    string
+}
+
+// EnableTUI shows a status bar at the bottom of the terminal with fresher's current
+// state, the last build's duration, and the last changed file. An error is returned
+// if fresher's output isn't a terminal, in which case plain logging is used.
+func EnableTUI() (err error) {
+	if !isatty.IsTerminal(os.Stdout.Fd()) || !isatty.IsTerminal(os.Stderr.Fd()) {
+		return errors.New("output is not a terminal")
+	}
+
+	err = enableTerminalSequences()
+	if err != nil {
+		return
+	}
+
+	rows, _, err := terminalSize()
+	if err != nil {
+		return
+	}
+	if rows < 3 {
+		return fmt.Errorf("terminal is too short, %d rows", rows)
+	}
+
+	//Move the existing output up a line so that it isn't covered by the status
+	//bar, then move back into the scrolling region.
+	tui.mu.Lock()
+	tui.enabled = true
+	tui.rows = rows
+	os.Stdout.WriteString("\n" + scrollRegion(rows) + "\033[1A")
+	tui.mu.Unlock()
+
+	go func() {
+		for {
+			drawTUI()
+			time.Sleep(tuiRefreshInterval)
+		}
+	}()
+
+	return nil
+}
+
+// drawTUI redraws the status bar, and the scrolling region if the terminal was
+// resized, if anything changed.
+func drawTUI() {
+	rows, cols, err := terminalSize()
+	if err != nil || rows < 3 {
+		return
+	}
+
+	tui.mu.Lock()
+	defer tui.mu.Unlock()
+	if !tui.enabled {
+		return
+	}
+
+	snap := status.snapshot()
+	line := tuiStatusLine(&snap, cols, time.Now())
+	if rows == tui.rows && line == tui.last {
+		return
+	}
+
+	//Everything is written at once, with the cursor saved and restored, so that the
+	//status bar is never drawn in the middle of output from the binary.
+	var b strings.Builder
+	if rows != tui.rows {
+		b.WriteString(scrollRegion(rows))
+	}
+	fmt.Fprintf(&b, "\0337\033[%d;1H\033[2K%s\0338", rows, line)
+	os.Stdout.WriteString(b.String())
+
+	tui.rows = rows
+	tui.last = line
+}
+
+// stopTUI removes the status bar and resets the scrolling region so that the terminal
+// works as usual once fresher exits.
+func stopTUI() {
+	tui.mu.Lock()
+	defer tui.mu.Unlock()
+	if !tui.enabled {
+		return
+	}
+
+	tui.enabled = false
+	fmt.Fprintf(os.Stdout, "\0337\033[r\033[%d;1H\033[2K\0338", tui.rows)
+}
+
+// scrollRegion returns the escape sequence to limit scrolling to every line but the
+// last, leaving the last line for the status bar. Setting the scrolling region moves
+// the cursor so the cursor is saved and restored.
+func scrollRegion(rows int) string {
+	return fmt.Sprintf("\0337\033[1;%dr\0338", rows-1)
+}
+
+// tuiStatusLine returns the status bar's text, fit to the terminal's width, i.e.:
+// " running | built in 534ms at 15:04:05 | changed main.go".
+func tuiStatusLine(s *runnerStatus, cols int, now time.Time) string {
+	state := s.state()
+	color := map[string]string{
+		"waiting":  "blue",
+		"fetching": "yellow",
+		"building": "yellow",
+		"paused":   "magenta",
+		"failed":   "red",
+		"exited":   "red",
+		"running":  "green",
+	}[state]
+
+	parts := []string{" " + state}
+	if !s.LastBuildAt.IsZero() {
+		parts = append(parts, fmt.Sprintf("built in %s at %s", time.Duration(s.LastBuildSeconds*float64(time.Second)).Round(time.Millisecond), s.LastBuildAt.Format("15:04:05")))
+	}
+	if s.Running && !s.BinaryStartedAt.IsZero() {
+		parts = append(parts, "up "+now.Sub(s.BinaryStartedAt).Truncate(time.Second).String())
+	}
+	if s.LastChangedFile != "" {
+		parts = append(parts, "changed "+filepath.ToSlash(s.LastChangedFile))
+	}
+	text := strings.Join(parts, " | ")
+
+	//Fit the text to the terminal's width so that the status bar never wraps.
+	if r := []rune(text); len(r) > cols {
+		text = string(r[:cols])
+	} else {
+		text += strings.Repeat(" ", cols-len(r))
+	}
+
+	if !config.Data().UseColors() {
+		return "\033[7m" + text + resetColorCode
+	}
+
+	//Color the state and show the rest of the bar in reverse video.
+	if len(state)+1 < len(text) {
+		return getColorCode(color) + "\033[7m" + text[:len(state)+1] + resetColorCode + "\033[7m" + text[len(state)+1:] + resetColorCode
+	}
+	return getColorCode(color) + "\033[7m" + text + resetColorCode
+}
+
+// clearScreen clears the terminal, and its scrollback, when ClearScreenOnRebuild is
+// set so that output from previous runs of the binary isn't confused with output from
+// the new run. Nothing is done if fresher's output isn't a terminal, i.e. piped to a
+// file, since the escape sequences would just be noise.
+func clearScreen() {
+	if !config.Data().ClearScreenOnRebuild || !isatty.IsTerminal(os.Stdout.Fd()) {
+		return
+	}
+	if enableTerminalSequences() != nil {
+		return
+	}
+
+	tui.mu.Lock()
+	defer tui.mu.Unlock()
+
+	os.Stdout.WriteString("\033[2J\033[3J\033[H")
+
+	//The status bar was cleared too, force it to be redrawn.
+	tui.last = ""
+}
@@ -0,0 +1,41 @@
+package runner3
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+func TestTUIStatusLine(t *testing.T) {
+	config.UseDefaults()
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	s := &runnerStatus{
+		Running:          true,
+		BinaryStartedAt:  now.Add(-90 * time.Second),
+		LastBuildAt:      now.Add(-time.Minute),
+		LastBuildSeconds: 0.5344,
+		LastChangedFile:  "web/main.go",
+	}
+
+	line := colorCodes.ReplaceAllString(tuiStatusLine(s, 80, now), "")
+	expected := " running | built in 534ms at 15:03:05 | up 1m30s | changed web/main.go"
+	if strings.TrimRight(line, " ") != expected {
+		t.Fatal("Unexpected status line.", line)
+		return
+	}
+	if len(line) != 80 {
+		t.Fatal("Status line should fill the terminal's width.", len(line))
+		return
+	}
+
+	//The status line should never wrap.
+	s = &runnerStatus{Building: true, LastChangedFile: "main.go"}
+	line = colorCodes.ReplaceAllString(tuiStatusLine(s, 10, now), "")
+	if line != " building " {
+		t.Fatal("Status line should be cut to the terminal's width.", line)
+		return
+	}
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package runner3
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// enableTerminalSequences does nothing since terminals on Unix-like OSes always
+// handle escape sequences.
+func enableTerminalSequences() error {
+	return nil
+}
+
+// terminalSize returns the number of rows and columns of the terminal stdout is
+// connected to.
+func terminalSize() (rows, cols int, err error) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return
+	}
+
+	return int(ws.Row), int(ws.Col), nil
+}
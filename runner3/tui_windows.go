@@ -0,0 +1,35 @@
+//go:build windows
+
+package runner3
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableTerminalSequences turns on handling of escape sequences, needed to set the
+// scrolling region and draw the status bar, in the Windows console.
+func enableTerminalSequences() error {
+	h := windows.Handle(os.Stdout.Fd())
+
+	var mode uint32
+	err := windows.GetConsoleMode(h, &mode)
+	if err != nil {
+		return err
+	}
+
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+}
+
+// terminalSize returns the number of rows and columns of the visible part of the
+// console stdout is connected to.
+func terminalSize() (rows, cols int, err error) {
+	var info windows.ConsoleScreenBufferInfo
+	err = windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info)
+	if err != nil {
+		return
+	}
+
+	return int(info.Window.Bottom-info.Window.Top) + 1, int(info.Window.Right-info.Window.Left) + 1, nil
+}
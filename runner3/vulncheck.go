@@ -0,0 +1,184 @@
+package runner3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// vulnChecker runs govulncheck when go.mod or go.sum changes. govulncheck runs in
+// its own goroutine since it can take a while and shouldn't delay rebuilding.
+type vulnChecker struct {
+	//trigger is sent on when a dependency file changes. This is buffered so that
+	//queuing never blocks the watcher and changes while govulncheck is running
+	//cause only one more run.
+	trigger chan struct{}
+}
+
+// vulnCheck is the package level vulnerability checker.
+var vulnCheck = &vulnChecker{trigger: make(chan struct{}, 1)}
+
+// govulncheckMessage is a message from the output of `govulncheck -json`. Only the
+// fields used to report vulnerabilities are decoded.
+type govulncheckMessage struct {
+	OSV *struct {
+		ID      string `json:"id"`
+		Summary string `json:"summary"`
+	} `json:"osv"`
+
+	Finding *struct {
+		OSV          string `json:"osv"`
+		FixedVersion string `json:"fixed_version"`
+		Trace        []struct {
+			Module   string `json:"module"`
+			Version  string `json:"version"`
+			Function string `json:"function"`
+		} `json:"trace"`
+	} `json:"finding"`
+}
+
+// vulnerability is a vulnerability found by govulncheck in code the module calls.
+type vulnerability struct {
+	ID           string
+	Summary      string
+	Module       string
+	Version      string
+	FixedVersion string
+}
+
+// startVulnCheck starts running govulncheck as dependency files change. This does
+// nothing if VulnCheck is not set in the config file.
+func startVulnCheck() {
+	if !config.Data().VulnCheck {
+		return
+	}
+
+	go func() {
+		for range vulnCheck.trigger {
+			//Wait a short while since go.mod and go.sum are usually changed
+			//together, i.e. by `go get`, so govulncheck is only run once.
+			time.Sleep(500 * time.Millisecond)
+			runVulnCheck()
+		}
+	}()
+}
+
+// queue causes govulncheck to be run if path is the module's go.mod or go.sum.
+func (v *vulnChecker) queue(path string) {
+	if !config.Data().VulnCheck || !isDependencyFile(path) {
+		return
+	}
+
+	select {
+	case v.trigger <- struct{}{}:
+	default:
+		//Already triggered.
+	}
+}
+
+// isDependencyFile returns true if path is the go.mod or go.sum file in the
+// WorkingDir.
+func isDependencyFile(path string) bool {
+	path = filepath.Clean(path)
+	workingDir := config.Data().WorkingDir
+	return path == filepath.Join(workingDir, "go.mod") || path == filepath.Join(workingDir, "go.sum")
+}
+
+// runVulnCheck runs govulncheck and logs the vulnerabilities found.
+func runVulnCheck() {
+	events.Printf("Dependencies changed, checking for vulnerabilities...")
+	start := time.Now()
+
+	cmd := exec.Command("govulncheck", "-json", "./...")
+	cmd.Dir = config.Data().WorkingDir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	//govulncheck exits with an error if vulnerabilities were found, in older
+	//versions, so output is parsed regardless of the error. An error with no
+	//output means govulncheck didn't run at all.
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		warn.Printf("govulncheck not found, install with `go install golang.org/x/vuln/cmd/govulncheck@latest`.")
+		return
+	} else if err != nil && stdout.Len() == 0 {
+		warn.Printf("govulncheck failed %s %s", err, bytes.TrimSpace(stderr.Bytes()))
+		return
+	}
+
+	vulns, err := parseGovulncheck(&stdout)
+	if err != nil {
+		warn.Printf("Could not parse govulncheck output %s", err)
+		return
+	}
+
+	if len(vulns) == 0 {
+		events.Printf("No vulnerabilities found (took %s).", time.Since(start).Round(time.Millisecond))
+		return
+	}
+
+	warn.Printf("Vulnerabilities found in code your module calls: %d (took %s)", len(vulns), time.Since(start).Round(time.Millisecond))
+	for _, v := range vulns {
+		fixed := "no fix available"
+		if v.FixedVersion != "" {
+			fixed = "fixed in " + v.FixedVersion
+		}
+		warn.Printf("  %s %s@%s, %s: %s", v.ID, v.Module, v.Version, fixed, v.Summary)
+	}
+}
+
+// parseGovulncheck returns the vulnerabilities found in the output of `govulncheck
+// -json`. Only vulnerabilities in functions the module calls are returned, the same
+// as govulncheck's text output, since vulnerabilities in imported but uncalled code
+// don't affect the module.
+func parseGovulncheck(r io.Reader) (vulns []vulnerability, err error) {
+	summaries := map[string]string{}
+	found := map[string]*vulnerability{}
+
+	//Messages are a stream of JSON objects, not a JSON array.
+	dec := json.NewDecoder(r)
+	for {
+		var msg govulncheckMessage
+		err = dec.Decode(&msg)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if msg.OSV != nil {
+			summaries[msg.OSV.ID] = msg.OSV.Summary
+		}
+
+		//The first frame of a finding's trace is the vulnerable symbol. The
+		//function is only set if the symbol is called.
+		f := msg.Finding
+		if f == nil || len(f.Trace) == 0 || f.Trace[0].Function == "" || found[f.OSV] != nil {
+			continue
+		}
+		found[f.OSV] = &vulnerability{
+			ID:           f.OSV,
+			Module:       f.Trace[0].Module,
+			Version:      f.Trace[0].Version,
+			FixedVersion: f.FixedVersion,
+		}
+	}
+
+	for id, v := range found {
+		v.Summary = summaries[id]
+		vulns = append(vulns, *v)
+	}
+	sort.Slice(vulns, func(i, j int) bool {
+		return vulns[i].ID < vulns[j].ID
+	})
+
+	return vulns, nil
+}
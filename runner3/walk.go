@@ -0,0 +1,160 @@
+package runner3
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+)
+
+// walkWorkers is the number of directories read at once when walking the directory
+// tree. Reading directories is mostly waiting on the filesystem, so more workers than
+// CPUs are used.
+var walkWorkers = runtime.NumCPU() * 4
+
+// walkDirectories walks the directory tree starting at root, calling visit for each
+// directory, root included. If visit returns false, the directory's subdirectories
+// are not walked (similar to returning fs.SkipDir from a fs.WalkDirFunc).
+//
+// Unlike filepath.WalkDir, directories are read in parallel. This makes walking huge
+// repos, with tens of thousands of directories, much faster. Therefore, visit is
+// called concurrently and in no particular order.
+//
+// Symlinked directories are only walked if FollowSymlinks is set in the config file.
+// Each directory is only walked once, based on its real path, so that a symlink to a
+// parent directory doesn't cause an endless loop.
+//
+// Walking stops at the first error returned from visit or from reading a directory.
+func walkDirectories(root string, visit func(path string) (descend bool, err error)) error {
+	q := &walkQueue{paths: []string{root}, pending: 1, visited: map[string]bool{}}
+	q.cond = sync.NewCond(&q.mu)
+
+	var wg sync.WaitGroup
+	for i := 0; i < walkWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(visit)
+		}()
+	}
+	wg.Wait()
+
+	return q.err
+}
+
+// walkQueue is the list of directories left to walk, shared between the workers in
+// walkDirectories(). A queue, rather than a goroutine per directory, is used so that
+// the number of goroutines stays small on huge repos.
+type walkQueue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	paths []string
+
+	//pending is the number of directories queued or being walked. Walking is done
+	//when this reaches 0.
+	pending int
+
+	//visited is the real path of each directory walked, used to detect cycles when
+	//following symlinks.
+	visited map[string]bool
+
+	err error
+}
+
+// work walks directories from the queue until no directories are left.
+func (q *walkQueue) work(visit func(path string) (descend bool, err error)) {
+	for {
+		//Get the next directory, waiting for other workers to queue subdirectories
+		//if the queue is empty but directories are still being walked.
+		q.mu.Lock()
+		for len(q.paths) == 0 && q.pending > 0 {
+			q.cond.Wait()
+		}
+		if len(q.paths) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		path := q.paths[len(q.paths)-1]
+		q.paths = q.paths[:len(q.paths)-1]
+		q.mu.Unlock()
+
+		subdirectories, err := q.walkDirectory(path, visit)
+
+		q.mu.Lock()
+		if err != nil && q.err == nil {
+			//Stop walking by dropping everything that is queued.
+			q.err = err
+			q.pending -= len(q.paths)
+			q.paths = nil
+		}
+		if q.err == nil {
+			q.paths = append(q.paths, subdirectories...)
+			q.pending += len(subdirectories)
+		}
+		q.pending--
+		q.cond.Broadcast()
+		q.mu.Unlock()
+	}
+}
+
+// walkDirectory visits a single directory and returns the subdirectories to walk.
+func (q *walkQueue) walkDirectory(path string, visit func(path string) (descend bool, err error)) (subdirectories []string, err error) {
+	followSymlinks := config.Data().FollowSymlinks
+	if followSymlinks && !q.markVisited(path) {
+		warn.Tracef("IGNORING %s (already watched, symlink cycle)", path)
+		return
+	}
+
+	descend, err := visit(path)
+	if err != nil || !descend {
+		return
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		switch {
+		case e.IsDir():
+			subdirectories = append(subdirectories, filepath.Join(path, e.Name()))
+
+		case followSymlinks && e.Type()&fs.ModeSymlink != 0:
+			//Stat, unlike the DirEntry, follows the symlink to see if the target is
+			//a directory. Broken symlinks are skipped.
+			p := filepath.Join(path, e.Name())
+			fi, err := os.Stat(p)
+			if err == nil && fi.IsDir() {
+				subdirectories = append(subdirectories, p)
+			}
+		}
+	}
+
+	return
+}
+
+// markVisited notes that a directory is being walked and returns false if the
+// directory, based on its real path with symlinks resolved, was already walked.
+func (q *walkQueue) markVisited(path string) bool {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		//Walk the directory anyway, reading the directory will most likely fail and
+		//return a more useful error.
+		return true
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.visited[realPath] {
+		return false
+	}
+
+	q.visited[realPath] = true
+	return true
+}
@@ -0,0 +1,114 @@
+package runner3
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/c9845/fresher/config"
+)
+
+// warming is the `go build` or `go vet` command warming the build cache, if it is
+// running. This is used to stop warming when a build starts.
+var warming struct {
+	mu sync.Mutex
+
+	//started prevents warming more than once.
+	started bool
+
+	//cmd is the running command, nil once it exits.
+	cmd *exec.Cmd
+
+	//stopped is used to tell if the command was stopped, or if it completed.
+	stopped bool
+}
+
+// getWarmArgs returns the arguments passed to "go" to warm the build cache. The same
+// flags used to build the binary are used since the build cache is keyed by them.
+func getWarmArgs() []string {
+	if config.Data().WarmBuildCache == config.WarmBuildCacheVet {
+		args := []string{"vet"}
+		if tags := config.Data().BuildTags(); len(tags) > 0 {
+			args = append(args, "-tags", tags)
+		}
+		if config.Data().GoTrimpath {
+			args = append(args, "-trimpath")
+		}
+		if p := config.Data().BuildParallelism; p > 0 {
+			args = append(args, "-p", strconv.Itoa(p))
+		}
+		return append(args, "./...")
+	}
+
+	args := []string{"build", "-o", os.DevNull}
+	args = append(args, getBuildFlags(config.Data())...)
+	return append(args, "./...")
+}
+
+// startWarmingBuildCache warms Go's build cache for every package in the module, in
+// the background, so that the first build after a change to a package the binary
+// hasn't been built with yet is fast. This is only done once, when fresher starts,
+// and does nothing if WarmBuildCache is "off".
+func startWarmingBuildCache() {
+	if config.Data().WarmBuildCache == config.WarmBuildCacheOff {
+		return
+	}
+
+	warming.mu.Lock()
+	defer warming.mu.Unlock()
+	if warming.started {
+		return
+	}
+	warming.started = true
+
+	args := getWarmArgs()
+	cmd := exec.Command("go", args...)
+	cmd.Dir = config.Data().WorkingDir
+	cmd.Env = getBuildEnv(config.Data())
+	events.Verbosef("Warming build cache... %s", formatCommand("go", args))
+
+	//Output isn't shown since errors in packages that aren't part of the binary are
+	//unrelated to what the user is working on.
+	start := time.Now()
+	err := startBuildCommand(cmd)
+	if err != nil {
+		warn.Printf("Could not warm build cache %s", err)
+		return
+	}
+	warming.cmd = cmd
+
+	go func() {
+		err := cmd.Wait()
+
+		warming.mu.Lock()
+		stopped := warming.stopped
+		warming.cmd = nil
+		warming.mu.Unlock()
+
+		switch {
+		case stopped:
+			events.Verbosef("Warming build cache...stopped, building.")
+		case err != nil:
+			//Packages that failed to build or vet are still cached as far as
+			//they got, the rest of the module is warmed.
+			events.Printf("Warmed build cache in %s, with errors (%s).", time.Since(start).Round(time.Millisecond), err)
+		default:
+			events.Printf("Warmed build cache in %s.", time.Since(start).Round(time.Millisecond))
+		}
+	}()
+}
+
+// stopWarmingBuildCache stops warming the build cache, if it is still running, so
+// that it doesn't slow down building the binary.
+func stopWarmingBuildCache() {
+	warming.mu.Lock()
+	defer warming.mu.Unlock()
+	if warming.cmd == nil {
+		return
+	}
+
+	warming.stopped = true
+	killProcessTree(warming.cmd.Process)
+}
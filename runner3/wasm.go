@@ -0,0 +1,176 @@
+package runner3
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/c9845/fresher/config"
+)
+
+// wasmExecFilename is the name of the JavaScript support file, shipped with Go, that
+// is needed to run a Go WebAssembly binary in the browser.
+const wasmExecFilename = "wasm_exec.js"
+
+// wasmIndex is the page served at the root of WASMAddress. This loads the WebAssembly
+// binary and reloads the page when the binary is rebuilt.
+const wasmIndex = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<script src="/wasm_exec.js"></script>
+<script>
+const go = new Go();
+WebAssembly.instantiateStreaming(fetch("/app.wasm"), go.importObject).then((result) => {
+	go.run(result.instance);
+});
+new EventSource("/reload").onmessage = () => location.reload();
+</script>
+</head>
+<body></body>
+</html>
+`
+
+// wasmReloaders are the browser pages waiting to be told to reload. Each page has a
+// channel that is sent on, via reloadWASM(), after each successful build.
+var wasmReloaders = struct {
+	mu    sync.Mutex
+	pages map[chan bool]bool
+}{pages: map[chan bool]bool{}}
+
+// usingWASM returns true if the binary is built as a WebAssembly frontend and served,
+// rather than run. See the WASMAddress field in the config file.
+func usingWASM() bool {
+	return config.Data().WASMAddress != ""
+}
+
+// wasmBuildEnv returns the environment `go build` is run with to build a WebAssembly
+// binary.
+func wasmBuildEnv() []string {
+	return append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+}
+
+// serveWASM copies wasm_exec.js to the TempDir and starts an HTTP server that serves
+// it, the built WebAssembly binary, and an index page. This is only started if
+// WASMAddress is set in the config.
+//
+// wasm_exec.js is copied, rather than served from the Go installation, since the
+// file must match the Go version used to build and copying it once at start up makes
+// sure a mismatched file isn't served if Go is upgraded while fresher is running.
+func serveWASM() (err error) {
+	addr := config.Data().WASMAddress
+	if addr == "" {
+		return
+	}
+
+	err = copyWASMExec()
+	if err != nil {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != "/index.html" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, wasmIndex)
+	})
+	mux.HandleFunc("/wasm_exec.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeFile(w, r, filepath.Join(config.Data().TempDir, wasmExecFilename))
+	})
+	mux.HandleFunc("/app.wasm", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/wasm")
+		http.ServeFile(w, r, getPathToBuiltBinary(config.Data()))
+	})
+	mux.HandleFunc("/reload", handleWASMReload)
+
+	events.Printf("Serving WebAssembly at http://%s/", addr)
+
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		if err != nil {
+			errs.Printf("WebAssembly server error %s", err)
+		}
+	}()
+
+	return
+}
+
+// copyWASMExec copies wasm_exec.js from the Go installation to the TempDir. The file
+// was moved from misc/wasm to lib/wasm in Go 1.24, so both are checked.
+func copyWASMExec() (err error) {
+	out, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return fmt.Errorf("could not find GOROOT for %s %w", wasmExecFilename, err)
+	}
+	goroot := strings.TrimSpace(string(out))
+
+	for _, dir := range []string{"lib", "misc"} {
+		b, err := os.ReadFile(filepath.Join(goroot, dir, "wasm", wasmExecFilename))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		return os.WriteFile(filepath.Join(config.Data().TempDir, wasmExecFilename), b, 0644)
+	}
+
+	return fmt.Errorf("could not find %s in %s", wasmExecFilename, goroot)
+}
+
+// handleWASMReload streams a server-sent event to the page each time the WebAssembly
+// binary is rebuilt so that the page reloads.
+func handleWASMReload(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	flusher.Flush()
+
+	reload := make(chan bool, 1)
+	wasmReloaders.mu.Lock()
+	wasmReloaders.pages[reload] = true
+	wasmReloaders.mu.Unlock()
+
+	defer func() {
+		wasmReloaders.mu.Lock()
+		delete(wasmReloaders.pages, reload)
+		wasmReloaders.mu.Unlock()
+	}()
+
+	select {
+	case <-reload:
+		fmt.Fprint(w, "data: reload\n\n")
+		flusher.Flush()
+	case <-r.Context().Done():
+	}
+}
+
+// reloadWASM tells each open page to reload since the WebAssembly binary was rebuilt.
+func reloadWASM() {
+	wasmReloaders.mu.Lock()
+	defer wasmReloaders.mu.Unlock()
+
+	events.Printf("Reloading %d WebAssembly page(s)...", len(wasmReloaders.pages))
+	for reload := range wasmReloaders.pages {
+		select {
+		case reload <- true:
+		default:
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package runner3
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// watcherStats tracks the directories added to, or skipped from, the watcher. This
+// is used to diagnose watching too many directories, or the wrong directories, in
+// big repos where the OS's limit on watches can be hit.
+//
+// The fields are exported, with json tags, so that the stats can be output as JSON.
+type watcherStats struct {
+	mu sync.Mutex
+
+	//Watched is the number of directories added to the watcher.
+	Watched int `json:"watched"`
+
+	//Ignored is the number of directories skipped, keyed by the reason the directory
+	//was skipped. See ignoreDirectoryReason().
+	Ignored map[string]int `json:"ignored"`
+
+	//Limit is the OS's limit on the number of watches, if known. This is only known
+	//on Linux where each watched directory uses one inotify watch.
+	Limit int `json:"limit,omitempty"`
+}
+
+// watching is the package level watcher stats. This is populated in Watch().
+var watching = watcherStats{Ignored: map[string]int{}}
+
+// recordWatched notes a directory was added to the watcher.
+func (w *watcherStats) recordWatched() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.Watched++
+}
+
+// recordIgnored notes a directory was skipped for the given reason.
+func (w *watcherStats) recordIgnored(reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.Ignored[reason]++
+}
+
+// setLimit saves the OS's limit on the number of watches.
+func (w *watcherStats) setLimit(limit int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.Limit = limit
+}
+
+// snapshot returns a copy of the stats that is safe to read without holding the lock,
+// for example when encoding to JSON.
+func (w *watcherStats) snapshot() watcherStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ignored := make(map[string]int, len(w.Ignored))
+	for reason, n := range w.Ignored {
+		ignored[reason] = n
+	}
+
+	return watcherStats{
+		Watched: w.Watched,
+		Ignored: ignored,
+		Limit:   w.Limit,
+	}
+}
+
+// summary returns a one line description of the stats, i.e.: "Watching 120
+// directories, ignored 4 (DirectoriesToIgnore: 3, TempDir: 1), using 1.5% of inotify
+// limit 8192".
+func (w *watcherStats) summary() string {
+	s := w.snapshot()
+
+	total := 0
+	reasons := []string{}
+	for reason, n := range s.Ignored {
+		total += n
+		reasons = append(reasons, fmt.Sprintf("%s: %d", reason, n))
+	}
+	sort.Strings(reasons)
+
+	summary := fmt.Sprintf("Watching %d directories, ignored %d", s.Watched, total)
+	if total > 0 {
+		summary += " (" + strings.Join(reasons, ", ") + ")"
+	}
+
+	//The limit is per-user, not per-process, so other apps watching files (i.e.:
+	//editors) count against it as well. Hence this is just an estimate.
+	if s.Limit > 0 {
+		summary += fmt.Sprintf(", using about %.1f%% of inotify limit %d", float64(s.Watched)/float64(s.Limit)*100, s.Limit)
+	}
+
+	return summary
+}
+
+// watchKeypresses handles commands typed in the terminal fresher is running in, each
+// followed by enter:
+//   - "w" prints the watcher stats.
+//   - "r" restarts the binary, which is the only way the binary is restarted when
+//     RestartPolicy is "manual".
+//   - "p" pauses handling file changes, or resumes if paused, see watchPause.
+//   - "y" confirms rebuilding after many files changed at once, anything else
+//     skips the rebuild, see rebuildConfirmation.
+//   - "b" switches to the next build config, or "b name" to the named build config,
+//     and rebuilds, see BuildConfigs.
+//
+// Input is read line by line since reading single keypresses would require putting
+// the terminal into raw mode.
+//
+// The binary is not given fresher's stdin, so reading stdin here doesn't take input
+// away from the binary. If stdin is not a terminal, i.e.: /dev/null, reading just
+// stops.
+func watchKeypresses() {
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			input := strings.TrimSpace(scanner.Text())
+
+			//Answer a rebuild confirmation, see ConfirmRebuildFiles, before handling
+			//the input as a command.
+			if event, ok := confirming.answer(input); ok {
+				if event != nil {
+					sendEvent(*event)
+				}
+				continue
+			}
+
+			//Switch build configs, by name if one was given.
+			if input == "b" || strings.HasPrefix(input, "b ") {
+				err := switchBuildConfig(strings.TrimSpace(strings.TrimPrefix(input, "b")))
+				if err != nil {
+					errs.Printf("Could not switch build config. %s", err)
+				}
+				continue
+			}
+
+			switch input {
+			case "w":
+				events.Printf("%s", watching.summary())
+			case "r":
+				requestEvent(restartEventName)
+			case "p":
+				togglePause()
+			}
+		}
+	}()
+}
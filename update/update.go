@@ -0,0 +1,279 @@
+// Package update checks for newer releases of fresher and replaces the running
+// executable with the newest release.
+//
+// Releases are read from GitHub. Each release must include a binary for each
+// platform, named fresher_GOOS_GOARCH (with .exe on Windows), and a checksums.txt
+// file listing the SHA-256 checksum of each binary in the format output by
+// `sha256sum`.
+package update
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/c9845/fresher/version"
+)
+
+// releasesURL is the GitHub API endpoint for the newest release. This is a variable
+// so that it can be changed in tests.
+var releasesURL = "https://api.github.com/repos/c9845/fresher/releases/latest"
+
+// checksumsFilename is the name of the release asset listing each binary's checksum.
+const checksumsFilename = "checksums.txt"
+
+// checkInterval is how often CheckDaily() checks for a new release.
+const checkInterval = 24 * time.Hour
+
+// Release is a published version of fresher.
+type Release struct {
+	Version string  `json:"tag_name"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// ErrNoBinary is returned when a release doesn't include a binary for this platform.
+var ErrNoBinary = errors.New("release has no binary for " + runtime.GOOS + "/" + runtime.GOARCH + ", use go install github.com/c9845/fresher@latest instead")
+
+// Latest returns the newest release.
+func Latest() (r Release, err error) {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(releasesURL)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return r, fmt.Errorf("update: could not get latest release, %s", resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	return
+}
+
+// IsNewer returns true if the release is newer than the running version of fresher.
+func (r Release) IsNewer() bool {
+	return compareVersions(r.Version, version.V) > 0
+}
+
+// asset returns the release asset with the given name.
+func (r Release) asset(name string) (a Asset, ok bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+
+	return Asset{}, false
+}
+
+// binaryName returns the name of the release asset for this platform.
+func binaryName() string {
+	name := "fresher_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	return name
+}
+
+// Upgrade downloads the release's binary for this platform, verifies its checksum,
+// and replaces the executable at path with it. The new binary is written next to the
+// executable and renamed over it so that a failed download never leaves a partial
+// executable.
+func (r Release) Upgrade(path string) (err error) {
+	name := binaryName()
+	binary, ok := r.asset(name)
+	if !ok {
+		return ErrNoBinary
+	}
+	checksums, ok := r.asset(checksumsFilename)
+	if !ok {
+		return fmt.Errorf("update: release has no %s, binary can't be verified", checksumsFilename)
+	}
+
+	client := http.Client{Timeout: 5 * time.Minute}
+
+	expected, err := downloadChecksum(client, checksums.URL, name)
+	if err != nil {
+		return
+	}
+
+	//Download next to the executable so that the rename is on the same filesystem.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fresher-upgrade-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	err = download(client, binary.URL, tmp)
+	tmp.Close()
+	if err != nil {
+		return
+	}
+
+	actual, err := fileChecksum(tmp.Name())
+	if err != nil {
+		return
+	}
+	if actual != expected {
+		return fmt.Errorf("update: checksum mismatch for %s, expected %s, got %s", name, expected, actual)
+	}
+
+	err = os.Chmod(tmp.Name(), 0755)
+	if err != nil {
+		return
+	}
+
+	//Windows doesn't allow replacing a running executable, but does allow renaming
+	//it, so the old executable is moved aside first. The old executable can't be
+	//removed on Windows while it is running so it is removed on the next upgrade.
+	old := path + ".old"
+	os.Remove(old)
+	err = os.Rename(path, old)
+	if err != nil {
+		return
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		os.Rename(old, path)
+		return
+	}
+	os.Remove(old)
+
+	return nil
+}
+
+// download writes the file at url to w.
+func download(client http.Client, url string, w io.Writer) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("update: could not download %s, %s", url, resp.Status)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// downloadChecksum returns the checksum listed for name in the checksums file at url.
+func downloadChecksum(client http.Client, url, name string) (string, error) {
+	var b strings.Builder
+	err := download(client, url, &b)
+	if err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(b.String()))
+	for scanner.Scan() {
+		//Each line is "checksum  filename", the filename may be prefixed with "*"
+		//to denote binary mode.
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+
+	return "", fmt.Errorf("update: %s not listed in %s", name, checksumsFilename)
+}
+
+// fileChecksum returns the hex encoded SHA-256 checksum of the file at path.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CheckDaily returns the newest release if it is newer than the running version of
+// fresher. The check is only done once a day, the time of the last check is saved in
+// the user's cache directory, so nil is returned if a check was done recently.
+func CheckDaily() (newer *Release, err error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return
+	}
+	path := filepath.Join(dir, "fresher", "last-update-check")
+
+	if b, err := os.ReadFile(path); err == nil {
+		last, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		if err == nil && time.Since(time.Unix(last, 0)) < checkInterval {
+			return nil, nil
+		}
+	}
+
+	//Save the time of the check before checking so that a failed check, i.e. when
+	//offline, isn't retried each time fresher is started.
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return
+	}
+	err = os.WriteFile(path, []byte(strconv.FormatInt(time.Now().Unix(), 10)), 0644)
+	if err != nil {
+		return
+	}
+
+	r, err := Latest()
+	if err != nil || !r.IsNewer() {
+		return
+	}
+
+	return &r, nil
+}
+
+// compareVersions returns -1 if version a is older than b, 1 if a is newer, or 0 if
+// they are the same. Versions are in the format 1.2.3, optionally prefixed with "v".
+// Parts that aren't numbers are treated as 0.
+func compareVersions(a, b string) int {
+	aParts := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bParts := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			y, _ = strconv.Atoi(bParts[i])
+		}
+
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,179 @@
+package update
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestServer serves a release with a binary for this platform, whose contents are
+// binary, and a checksums file listing checksum for the binary.
+func newTestServer(t *testing.T, version string, binary []byte, checksum string) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Release{
+			Version: version,
+			Assets: []Asset{
+				{Name: binaryName(), URL: srv.URL + "/binary"},
+				{Name: checksumsFilename, URL: srv.URL + "/checksums"},
+			},
+		})
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	})
+	mux.HandleFunc("/checksums", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0000  fresher_other_arch\n" + checksum + " *" + binaryName() + "\n"))
+	})
+
+	original := releasesURL
+	releasesURL = srv.URL + "/latest"
+	t.Cleanup(func() { releasesURL = original })
+}
+
+// newTestExecutable writes a file to replace during an upgrade.
+func newTestExecutable(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "fresher")
+	err := os.WriteFile(path, []byte("old"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestUpgrade(t *testing.T) {
+	binary := []byte("new")
+	sum := sha256.Sum256(binary)
+	newTestServer(t, "v99.0.0", binary, hex.EncodeToString(sum[:]))
+
+	r, err := Latest()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if !r.IsNewer() {
+		t.Fatal("Release should be newer.", r.Version)
+		return
+	}
+
+	path := newTestExecutable(t)
+	err = r.Upgrade(path)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if string(b) != "new" {
+		t.Fatal("Executable not replaced.", string(b))
+		return
+	}
+
+	//Only the executable should be left, no temporary or old files.
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(entries) != 1 {
+		t.Fatal("Unexpected files left after upgrading.", len(entries))
+		return
+	}
+}
+
+func TestUpgradeChecksumMismatch(t *testing.T) {
+	newTestServer(t, "v99.0.0", []byte("new"), "badc0ffee")
+
+	r, err := Latest()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	path := newTestExecutable(t)
+	err = r.Upgrade(path)
+	if err == nil {
+		t.Fatal("Error about checksum mismatch should have been returned.")
+		return
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if string(b) != "old" {
+		t.Fatal("Executable should not have been replaced.", string(b))
+		return
+	}
+}
+
+func TestUpgradeNoBinary(t *testing.T) {
+	r := Release{Version: "v99.0.0"}
+	err := r.Upgrade(newTestExecutable(t))
+	if err != ErrNoBinary {
+		t.Fatal("ErrNoBinary should have been returned.", err)
+		return
+	}
+}
+
+func TestCheckDaily(t *testing.T) {
+	newTestServer(t, "v99.0.0", nil, "")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LocalAppData", t.TempDir())
+
+	r, err := CheckDaily()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if r == nil || r.Version != "v99.0.0" {
+		t.Fatal("Newer release should have been returned.", r)
+		return
+	}
+
+	//A second check on the same day should be skipped.
+	r, err = CheckDaily()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if r != nil {
+		t.Fatal("Check should have been skipped.")
+		return
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"v1.1.2", "1.1.2", 0},
+		{"v1.2.0", "1.1.2", 1},
+		{"1.1.2", "v1.10.0", -1},
+		{"v2", "1.9.9", 1},
+		{"1.1", "1.1.0", 0},
+	}
+
+	for _, tt := range tests {
+		if c := compareVersions(tt.a, tt.b); c != tt.expected {
+			t.Fatal("Unexpected comparison.", tt.a, tt.b, c)
+			return
+		}
+	}
+}